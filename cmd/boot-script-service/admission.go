@@ -0,0 +1,135 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Admission control for boot script requests.
+//
+// When many nodes power on at once, every boot script request may fetch a
+// SPIRE join token and presign S3 URLs. A global cap, and optional caps per
+// HSM role, bound how many requests do that work at the same time. Requests
+// over a cap are told to sleep and chain back, the same way nodes are delayed
+// while HSM state is refreshed.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	bootscriptMaxConcurrent = uint(0) // Boot script requests served at once, 0 for no limit
+	bootscriptRoleLimits    = ""      // Comma separated role=limit pairs
+	throttleDelay           = uint(5) // Seconds a throttled node sleeps before chaining back
+
+	bootscriptAdmission = newAdmission(0, nil)
+)
+
+// admission is a set of non-blocking semaphores, one global and one per role.
+type admission struct {
+	global chan struct{}
+	roles  map[string]chan struct{}
+}
+
+func newAdmission(max uint, roleLimits map[string]uint) *admission {
+	a := &admission{roles: make(map[string]chan struct{})}
+	if max > 0 {
+		a.global = make(chan struct{}, max)
+	}
+	for role, limit := range roleLimits {
+		a.roles[strings.ToLower(role)] = make(chan struct{}, limit)
+	}
+	return a
+}
+
+// tryAcquire admits a request for a node with the given role if neither the
+// global cap nor the role's cap has been reached. The returned function must
+// be called when the request is done.
+func (a *admission) tryAcquire(role string) (release func(), ok bool) {
+	roleSem := a.roles[strings.ToLower(role)]
+	if !trySend(a.global) {
+		return nil, false
+	}
+	if !trySend(roleSem) {
+		receive(a.global)
+		return nil, false
+	}
+	return func() {
+		receive(roleSem)
+		receive(a.global)
+	}, true
+}
+
+func trySend(sem chan struct{}) bool {
+	if sem == nil {
+		return true
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func receive(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
+
+// parseRoleLimits parses "Compute=500,Application=50" into a map.  Roles, and
+// the xnames of per-node limits, are matched case-insensitively, so they are
+// lower-cased.
+func parseRoleLimits(s string) (map[string]uint, error) {
+	limits := make(map[string]uint)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		role, value, found := strings.Cut(pair, "=")
+		role = strings.TrimSpace(role)
+		if !found || role == "" {
+			return nil, fmt.Errorf("%q is not name=limit", pair)
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil || limit == 0 {
+			return nil, fmt.Errorf("limit for %s must be a positive integer, not %q", role, value)
+		}
+		limits[strings.ToLower(role)] = uint(limit)
+	}
+	return limits, nil
+}
+
+// throttledBootScript tells a node to retry the same request after a delay.
+func throttledBootScript(r *http.Request) string {
+	configMutex.RLock()
+	delay := throttleDelay
+	configMutex.RUnlock()
+	chain := "chain " + chainProtocol(r.Context()) + "://" + ipxeServer + gwURI + r.URL.Path
+	if r.URL.RawQuery != "" {
+		chain += "?" + r.URL.RawQuery
+	}
+	return fmt.Sprintf("#!ipxe\nsleep %d\n%s\n", delay, chain)
+}
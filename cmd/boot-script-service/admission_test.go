@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdmission(t *testing.T) {
+	a := newAdmission(3, map[string]uint{"Application": 1})
+
+	releaseApp, ok := a.tryAcquire("application")
+	if !ok {
+		t.Fatalf("First Application request was not admitted")
+	}
+	if _, ok = a.tryAcquire("Application"); ok {
+		t.Errorf("Second Application request was admitted over the role limit")
+	}
+	releaseA, ok := a.tryAcquire("Compute")
+	if !ok {
+		t.Fatalf("Compute request was not admitted")
+	}
+	releaseB, ok := a.tryAcquire("")
+	if !ok {
+		t.Fatalf("Request without a role was not admitted")
+	}
+	if _, ok = a.tryAcquire("Compute"); ok {
+		t.Errorf("Request was admitted over the global limit")
+	}
+
+	releaseApp()
+	releaseA()
+	releaseB()
+	if _, ok = a.tryAcquire("Application"); !ok {
+		t.Errorf("Application request was not admitted after release")
+	}
+
+	unlimited := newAdmission(0, nil)
+	for i := 0; i < 10; i++ {
+		if _, ok = unlimited.tryAcquire("Compute"); !ok {
+			t.Fatalf("Request was refused without limits")
+		}
+	}
+}
+
+func TestParseRoleLimits(t *testing.T) {
+	limits, err := parseRoleLimits(" Compute=500, Application = 50 ,")
+	if err != nil {
+		t.Fatalf("parseRoleLimits returned error: %v", err)
+	}
+	if len(limits) != 2 || limits["compute"] != 500 || limits["application"] != 50 {
+		t.Errorf("parseRoleLimits returned %v", limits)
+	}
+	for _, bad := range []string{"Compute", "=5", "Compute=0", "Compute=many"} {
+		if _, err = parseRoleLimits(bad); err == nil {
+			t.Errorf("parseRoleLimits accepted %q", bad)
+		}
+	}
+}
+
+func TestBootscriptGetThrottled(t *testing.T) {
+	saved := bootscriptAdmission
+	defer func() { bootscriptAdmission = saved }()
+	bootscriptAdmission = newAdmission(1, nil)
+	release, _ := bootscriptAdmission.tryAcquire("")
+	defer release()
+
+	req := httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?mac=00:11:22:33:44:55&arch=x86_64", nil)
+	rr := httptest.NewRecorder()
+	BootscriptGet(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Throttled request returned %d, expected %d", rr.Code, http.StatusOK)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "sleep 5\n") || !strings.Contains(body, "/boot/v1/bootscript?mac=00:11:22:33:44:55&arch=x86_64") {
+		t.Errorf("Throttled request did not chain back to itself: %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	BootscriptGet(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?mac=00:11:22:33:44:55&json=1", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Throttled JSON request returned %d with Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,275 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// Authentication for the BSS API.
+//
+// Three authenticators are supported: mutual TLS client certificates, static
+// API keys, and JWTs verified against a JWKS.  Each of them produces an
+// authPrincipal which is then subject to the same authorization checks.
+//
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/jwtauth/v5"
+	openchami_authenticator "github.com/openchami/chi-middleware/auth"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	apiKeyHeader     = "X-API-Key"
+	apiKeyAuthScheme = "ApiKey "
+	apiKeyHashPrefix = "sha256:"
+)
+
+// apiKeyEntry is one entry of the API key file.  Only the SHA-256 hash of the
+// key is stored, never the key itself.
+type apiKeyEntry struct {
+	Name   string   `yaml:"name"`
+	Hash   string   `yaml:"hash"`
+	Role   string   `yaml:"role,omitempty"`
+	Scopes []string `yaml:"scopes,omitempty"`
+	sum    []byte
+}
+
+// certRoleEntry maps a client certificate to a role.  A certificate matches
+// if its full subject (RFC 2253 form, e.g. "CN=bss-admin,O=Example") equals
+// Subject, or if its common name equals CommonName.
+type certRoleEntry struct {
+	Subject    string   `yaml:"subject,omitempty"`
+	CommonName string   `yaml:"common-name,omitempty"`
+	Role       string   `yaml:"role,omitempty"`
+	Scopes     []string `yaml:"scopes,omitempty"`
+}
+
+var (
+	apiKeysFile         = ""
+	clientCertRolesFile = ""
+	apiKeys             []apiKeyEntry
+	certRoles           []certRoleEntry
+)
+
+// roleScopes expands a role name into the scopes it grants.
+func roleScopes(role string) ([]string, error) {
+	switch strings.ToLower(role) {
+	case "":
+		return nil, nil
+	case "read":
+		return []string{authReadScope}, nil
+	case "write":
+		return []string{authWriteScope}, nil
+	case "admin":
+		return []string{authAdminScope}, nil
+	}
+	return nil, fmt.Errorf("unknown role %q (expected read, write, or admin)", role)
+}
+
+func hashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// loadAPIKeys reads the API key file.  Each key hash must be given as
+// "sha256:" followed by the hex encoded hash, e.g. the output of
+// `printf '%s' "$KEY" | sha256sum`.
+func loadAPIKeys(path string) ([]apiKeyEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read API key file: %v", err)
+	}
+	var keys []apiKeyEntry
+	if err = yaml.UnmarshalStrict(data, &keys); err != nil {
+		return nil, fmt.Errorf("Could not parse API key file %s: %v", path, err)
+	}
+	for i := range keys {
+		k := &keys[i]
+		if k.Name == "" {
+			return nil, fmt.Errorf("API key %d in %s has no name", i, path)
+		}
+		if !strings.HasPrefix(k.Hash, apiKeyHashPrefix) {
+			return nil, fmt.Errorf("API key %q: hash must start with %q", k.Name, apiKeyHashPrefix)
+		}
+		k.sum, err = hex.DecodeString(strings.TrimPrefix(k.Hash, apiKeyHashPrefix))
+		if err != nil || len(k.sum) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: invalid SHA-256 hash", k.Name)
+		}
+		scopes, err := roleScopes(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("API key %q: %v", k.Name, err)
+		}
+		k.Scopes = append(k.Scopes, scopes...)
+	}
+	return keys, nil
+}
+
+// loadCertRoles reads the file mapping client certificate subjects to roles.
+func loadCertRoles(path string) ([]certRoleEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read client certificate role file: %v", err)
+	}
+	var roles []certRoleEntry
+	if err = yaml.UnmarshalStrict(data, &roles); err != nil {
+		return nil, fmt.Errorf("Could not parse client certificate role file %s: %v", path, err)
+	}
+	for i := range roles {
+		cr := &roles[i]
+		if cr.Subject == "" && cr.CommonName == "" {
+			return nil, fmt.Errorf("client certificate role %d in %s needs a subject or common-name", i, path)
+		}
+		scopes, err := roleScopes(cr.Role)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate role %d in %s: %v", i, path, err)
+		}
+		cr.Scopes = append(cr.Scopes, scopes...)
+	}
+	return roles, nil
+}
+
+// loadAuthenticators loads the configured API key and client certificate
+// files.
+func loadAuthenticators() (err error) {
+	if apiKeysFile != "" {
+		apiKeys, err = loadAPIKeys(apiKeysFile)
+		if err != nil {
+			return err
+		}
+		log.Printf("Loaded %d API key(s) from %s", len(apiKeys), apiKeysFile)
+	}
+	if clientCertRolesFile != "" {
+		certRoles, err = loadCertRoles(clientCertRolesFile)
+		if err != nil {
+			return err
+		}
+		log.Printf("Loaded %d client certificate role(s) from %s", len(certRoles), clientCertRolesFile)
+	}
+	return nil
+}
+
+// authEnabled reports whether any authenticator is configured.
+func authEnabled() bool {
+	return jwksURL != "" || len(apiKeys) > 0 || len(certRoles) > 0
+}
+
+// certPrincipal returns a principal for a verified client certificate that
+// matches an entry of the role file, or nil.  Certificates are only verified
+// when BSS terminates TLS itself and a client CA is configured.
+func certPrincipal(r *http.Request) *authPrincipal {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	subject := cert.Subject.String()
+	for _, cr := range certRoles {
+		if (cr.Subject != "" && cr.Subject == subject) ||
+			(cr.CommonName != "" && cr.CommonName == cert.Subject.CommonName) {
+			return &authPrincipal{Subject: subject, Method: "mtls", Scopes: cr.Scopes}
+		}
+	}
+	return nil
+}
+
+// apiKeyFromRequest returns the API key presented in the X-API-Key header or
+// an "Authorization: ApiKey <key>" header.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+	authz := r.Header.Get("Authorization")
+	if len(authz) > len(apiKeyAuthScheme) && strings.EqualFold(authz[:len(apiKeyAuthScheme)], apiKeyAuthScheme) {
+		return strings.TrimSpace(authz[len(apiKeyAuthScheme):])
+	}
+	return ""
+}
+
+func apiKeyPrincipal(key string) (*authPrincipal, error) {
+	sum := hashAPIKey(key)
+	for _, k := range apiKeys {
+		if subtle.ConstantTimeCompare(sum, k.sum) == 1 {
+			return &authPrincipal{Subject: k.Name, Method: "apikey", Scopes: k.Scopes}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid API key")
+}
+
+// jwtPrincipal is middleware which converts the claims of an already verified
+// JWT into an authPrincipal.  It must run after jwtauth.Verifier and the
+// authenticator.
+func jwtPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		p := &authPrincipal{Method: "jwt"}
+		if sub, ok := claims["sub"].(string); ok {
+			p.Subject = sub
+		}
+		p.Scopes = scopesFromClaim(claims[authScopeClaim])
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+// authenticate is middleware that establishes the principal for a request
+// using, in order, a mapped client certificate, an API key, or a JWT.
+// Requests without acceptable credentials are rejected.
+func authenticate(next http.Handler) http.Handler {
+	var jwtChain http.Handler
+	if tokenAuth != nil {
+		jwtChain = jwtauth.Verifier(tokenAuth)(
+			openchami_authenticator.AuthenticatorWithRequiredClaims(tokenAuth, []string{"sub", "iss", "aud"})(
+				jwtPrincipal(next)))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := certPrincipal(r); p != nil {
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+			return
+		}
+		if key := apiKeyFromRequest(r); key != "" && len(apiKeys) > 0 {
+			p, err := apiKeyPrincipal(key)
+			if err != nil {
+				log.Printf("Denied %s %s: %v", r.Method, r.URL.Path, err)
+				base.SendProblemDetailsGeneric(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+			return
+		}
+		if jwtChain != nil {
+			jwtChain.ServeHTTP(w, r)
+			return
+		}
+		base.SendProblemDetailsGeneric(w, http.StatusUnauthorized, "Unauthorized")
+	})
+}
@@ -0,0 +1,100 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "keys.yaml")
+	sum := hex.EncodeToString(hashAPIKey("secret"))
+	err := os.WriteFile(good, []byte("- name: ci\n  hash: sha256:"+sum+"\n  role: write\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadAPIKeys(good)
+	if err != nil {
+		t.Fatalf("loadAPIKeys returned error: %v", err)
+	}
+	if len(keys) != 1 || len(keys[0].Scopes) != 1 || keys[0].Scopes[0] != authWriteScope {
+		t.Errorf("loadAPIKeys returned %+v, expected one key with scope %s", keys, authWriteScope)
+	}
+
+	bad := []string{
+		"- name: ci\n  hash: " + sum + "\n",
+		"- name: ci\n  hash: sha256:abcd\n",
+		"- name: ci\n  hash: sha256:" + sum + "\n  role: root\n",
+		"- hash: sha256:" + sum + "\n",
+		"- name: ci\n  hash: sha256:" + sum + "\n  unknown: x\n",
+	}
+	for i, content := range bad {
+		path := filepath.Join(dir, "bad.yaml")
+		if err = os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = loadAPIKeys(path); err == nil {
+			t.Errorf("loadAPIKeys accepted bad file %d:\n%s", i, content)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	apiKeys = []apiKeyEntry{
+		{Name: "reader", sum: hashAPIKey("read-key"), Scopes: []string{authReadScope}},
+		{Name: "writer", sum: hashAPIKey("write-key"), Scopes: []string{authWriteScope}},
+	}
+	defer func() { apiKeys = nil }()
+
+	handler := authenticate(requireScopeByMethod(authReadScope, authWriteScope)(http.HandlerFunc(Index)))
+	tests := []struct {
+		method   string
+		header   string
+		value    string
+		expected int
+	}{
+		{http.MethodGet, apiKeyHeader, "read-key", http.StatusOK},
+		{http.MethodGet, "Authorization", "ApiKey read-key", http.StatusOK},
+		{http.MethodPut, apiKeyHeader, "read-key", http.StatusForbidden},
+		{http.MethodPut, "Authorization", "apikey write-key", http.StatusOK},
+		{http.MethodGet, apiKeyHeader, "wrong-key", http.StatusUnauthorized},
+		{http.MethodGet, "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/boot/v1/bootparameters", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.expected {
+			t.Errorf("%s with %s %q returned %d, expected %d", tt.method, tt.header, tt.value, rr.Code, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// Scope-based authorization for the BSS API.
+//
+// Authentication middleware establishes who the caller is and stores an
+// authPrincipal in the request context. The middleware in this file then
+// decides whether that principal may use a particular group of routes based
+// on the scopes it carries.
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+type authContextKey struct{}
+
+// authPrincipal describes an authenticated caller.
+type authPrincipal struct {
+	Subject string   // Who the caller is, e.g. the JWT "sub" claim
+	Method  string   // How the caller was authenticated
+	Scopes  []string // Scopes granted to the caller
+}
+
+var (
+	authScopesEnabled = false      // Enforce scopes on protected routes
+	authProtectReads  = false      // Require authentication for read-only informational routes
+	authScopeClaim    = "scope"    // JWT claim holding the caller's scopes
+	authReadScope     = "bss:read" // Scope needed to read boot data
+	authWriteScope    = "bss:write"
+	authAdminScope    = "bss:admin"
+)
+
+// withPrincipal returns a copy of ctx carrying the principal p.
+func withPrincipal(ctx context.Context, p *authPrincipal) context.Context {
+	return context.WithValue(ctx, authContextKey{}, p)
+}
+
+// principalFromContext returns the principal stored in ctx, or nil if the
+// request was not authenticated.
+func principalFromContext(ctx context.Context) *authPrincipal {
+	p, _ := ctx.Value(authContextKey{}).(*authPrincipal)
+	return p
+}
+
+// scopesFromClaim converts the value of a scope claim into a list of scopes.
+// OAuth2 servers commonly use a space separated string ("scope"), while others
+// use an array of strings ("scp", "roles", "groups").
+func scopesFromClaim(v interface{}) []string {
+	switch c := v.(type) {
+	case string:
+		return strings.Fields(c)
+	case []string:
+		return c
+	case []interface{}:
+		var scopes []string
+		for _, s := range c {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+		return scopes
+	}
+	return nil
+}
+
+// hasScope reports whether the principal was granted the required scope.  The
+// admin scope implies every other scope, and the write scope implies the read
+// scope.
+func (p *authPrincipal) hasScope(required string) bool {
+	if p == nil {
+		return false
+	}
+	for _, s := range p.Scopes {
+		switch {
+		case s == required:
+			return true
+		case authAdminScope != "" && s == authAdminScope:
+			return true
+		case required == authReadScope && authWriteScope != "" && s == authWriteScope:
+			return true
+		}
+	}
+	return false
+}
+
+// authorize checks the principal in the request context against the required
+// scope.  If scope enforcement is disabled, any valid JWT is accepted as it
+// always has been.  API keys and client certificates are always limited to the
+// role they were configured with.
+func authorize(r *http.Request, required string) error {
+	p := principalFromContext(r.Context())
+	if p == nil {
+		return fmt.Errorf("request is not authenticated")
+	}
+	if required == "" || (!authScopesEnabled && p.Method == "jwt") {
+		return nil
+	}
+	if !p.hasScope(required) {
+		return fmt.Errorf("%s %q lacks required scope %q", p.Method, p.Subject, required)
+	}
+	return nil
+}
+
+func sendForbidden(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Denied %s %s: %v", r.Method, r.URL.Path, err)
+	if principalFromContext(r.Context()) == nil {
+		base.SendProblemDetailsGeneric(w, http.StatusUnauthorized, "Unauthorized")
+	} else {
+		base.SendProblemDetailsGeneric(w, http.StatusForbidden, "Forbidden: insufficient scope")
+	}
+}
+
+// requireScope returns middleware that rejects requests whose principal does
+// not hold the given scope.
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return requireScopeByMethod(scope, scope)
+}
+
+// requireScopeByMethod returns middleware that requires readScope for GET and
+// HEAD requests, and writeScope for all other methods.
+func requireScopeByMethod(readScope, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				required = readScope
+			}
+			if err := authorize(r, required); err != nil {
+				sendForbidden(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
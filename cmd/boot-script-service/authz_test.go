@@ -0,0 +1,82 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestScopesFromClaim(t *testing.T) {
+	tests := []struct {
+		claim    interface{}
+		expected []string
+	}{
+		{"bss:read bss:write", []string{"bss:read", "bss:write"}},
+		{[]interface{}{"bss:admin", 5}, []string{"bss:admin"}},
+		{[]string{"bss:read"}, []string{"bss:read"}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		scopes := scopesFromClaim(tt.claim)
+		if !reflect.DeepEqual(scopes, tt.expected) {
+			t.Errorf("scopesFromClaim(%v) returned %v, expected %v", tt.claim, scopes, tt.expected)
+		}
+	}
+}
+
+func TestRequireScopeByMethod(t *testing.T) {
+	authScopesEnabled = true
+	defer func() { authScopesEnabled = false }()
+
+	handler := requireScopeByMethod(authReadScope, authWriteScope)(http.HandlerFunc(Index))
+	tests := []struct {
+		method   string
+		scopes   []string
+		noAuth   bool
+		expected int
+	}{
+		{http.MethodGet, []string{authReadScope}, false, http.StatusOK},
+		{http.MethodGet, []string{authWriteScope}, false, http.StatusOK},
+		{http.MethodGet, []string{authAdminScope}, false, http.StatusOK},
+		{http.MethodGet, []string{"other"}, false, http.StatusForbidden},
+		{http.MethodPut, []string{authReadScope}, false, http.StatusForbidden},
+		{http.MethodPut, []string{authWriteScope}, false, http.StatusOK},
+		{http.MethodDelete, []string{authAdminScope}, false, http.StatusOK},
+		{http.MethodGet, nil, true, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/boot/v1/bootparameters", nil)
+		if !tt.noAuth {
+			p := &authPrincipal{Subject: "test", Method: "jwt", Scopes: tt.scopes}
+			req = req.WithContext(withPrincipal(req.Context(), p))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.expected {
+			t.Errorf("%s with scopes %v returned %d, expected %d", tt.method, tt.scopes, rr.Code, tt.expected)
+		}
+	}
+}
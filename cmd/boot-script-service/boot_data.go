@@ -76,6 +76,7 @@ type BootData struct {
 	Initrd        ImageData          `json:"initrd,omitempty"`
 	CloudInit     bssTypes.CloudInit `json:"-"`
 	ReferralToken string             `json:"-"`
+	Default       bool               `json:"-"` // Neither the node nor its role has data
 }
 
 const DefaultTag = "Default"
@@ -237,13 +238,16 @@ func nidName(nid int) string {
 
 func Remove(bp bssTypes.BootParams) error {
 	debugf("Remove(): Ready to remove %v\n", bp)
+	defer bootConfigChanged()
 	var err error
 	if useSQL {
 		var (
 			nodesDeleted []string
 			bcsDeleted   []string
 		)
+		start := time.Now()
 		nodesDeleted, bcsDeleted, err = bssdb.Delete(bp)
+		observeSQL("delete", start, err)
 		if err != nil {
 			return err
 		}
@@ -359,17 +363,23 @@ func SqlGetBootParams(macs, xnames []string, nids []int32) (results []bssTypes.B
 		paramsByName []bssTypes.BootParams
 		paramsByNid  []bssTypes.BootParams
 	)
+	start := time.Now()
 	paramsByMac, err = bssdb.GetBootParamsByMac(macs)
+	observeSQL("get_by_mac", start, err)
 	if err != nil {
 		err = fmt.Errorf("Error getting boot parameters for macs=%v: %v", macs, err)
 		return
 	}
+	start = time.Now()
 	paramsByName, err = bssdb.GetBootParamsByName(xnames)
+	observeSQL("get_by_name", start, err)
 	if err != nil {
 		err = fmt.Errorf("Error getting boot parameters for names=%v: %v", xnames, err)
 		return
 	}
+	start = time.Now()
 	paramsByNid, err = bssdb.GetBootParamsByNid(nids)
+	observeSQL("get_by_nid", start, err)
 	if err != nil {
 		err = fmt.Errorf("Error getting boot parameters for nids=%v: %v", nids, err)
 		return
@@ -484,10 +494,15 @@ func SqlGetBootParams(macs, xnames []string, nids []int32) (results []bssTypes.B
 }
 
 func StoreNew(bp bssTypes.BootParams) (error, string) {
+	defer bootConfigChanged()
+
 	// postgres.Add will handle duplicates.
 	if useSQL {
 		debugf("postgres.Add(%v)\n", bp)
-		if result, err := bssdb.Add(bp); err != nil {
+		start := time.Now()
+		result, err := bssdb.Add(bp)
+		observeSQL("add", start, err)
+		if err != nil {
 			return err, ""
 		} else {
 			debugf("postgres.Add(%v) result: %v\n", bp, result)
@@ -547,10 +562,14 @@ func StoreNew(bp bssTypes.BootParams) (error, string) {
 
 func Store(bp bssTypes.BootParams) (error, string) {
 	debugf("Store(%v)\n", bp)
+	defer bootConfigChanged()
 
 	if useSQL {
 		debugf("postgres.Set(%v)\n", bp)
-		if err := bssdb.Set(bp); err != nil {
+		start := time.Now()
+		err := bssdb.Set(bp)
+		observeSQL("set", start, err)
+		if err != nil {
 			return err, ""
 		} else {
 			return err, uuid.New().String()
@@ -638,11 +657,19 @@ func Store(bp bssTypes.BootParams) (error, string) {
 // The update function will update entries but not NULL out existing entries.
 func Update(bp bssTypes.BootParams) error {
 	debugf("Update(%v)\n", bp)
+	// Cloud-init data, which every phone-home updates, is not part of the
+	// boot script, so only updates of the kernel, initrd, or parameters
+	// invalidate cached boot script lookups.
+	if bp.Params != "" || bp.Kernel != "" || bp.Initrd != "" {
+		defer bootConfigChanged()
+	}
 
 	// Perform postgres.Update() and return if postgres is enabled.
 	if useSQL {
 		debugf("postgres.Update(%v)", bp)
+		start := time.Now()
 		nodesUpdated, err := bssdb.Update(bp)
+		observeSQL("update", start, err)
 		if err != nil {
 			return err
 		}
@@ -790,6 +817,13 @@ func updateCloudData(existing *bssTypes.CloudDataType, merge bssTypes.CloudDataT
 func updateCloudInit(d *bssTypes.CloudInit, p bssTypes.CloudInit) bool {
 	changed := updateCloudData(&d.MetaData, p.MetaData, "MetaData")
 	changed = updateCloudData(&d.UserData, p.UserData, "UserData") || changed
+	changed = updateCloudData(&d.VendorData, p.VendorData, "VendorData") || changed
+	changed = updateCloudData(&d.NetworkConfig, p.NetworkConfig, "NetworkConfig") || changed
+	// If user-data parts are given, take the entire new list.
+	if p.UserDataParts != nil && !reflect.DeepEqual(p.UserDataParts, d.UserDataParts) {
+		d.UserDataParts = p.UserDataParts
+		changed = true
+	}
 	// If the new PhoneHome data has anything set, take the entire new object.
 	if p.PhoneHome.PublicKeyDSA != "" || p.PhoneHome.PublicKeyRSA != "" ||
 		p.PhoneHome.PublicKeyECDSA != "" || p.PhoneHome.PublicKeyED25519 != "" ||
@@ -803,21 +837,69 @@ func updateCloudInit(d *bssTypes.CloudInit, p bssTypes.CloudInit) bool {
 	return changed
 }
 
-func updateEndpointAccessed(name string, accessType bssTypes.EndpointType) {
+// updateEndpointAccessed records an access of an endpoint by the node name
+// from clientIP.  An empty name means the request did not resolve to a node,
+// and the access is recorded under the client IP address instead.  dflt tells
+// whether the client was served default data rather than data of its own.
+func updateEndpointAccessed(name string, accessType bssTypes.EndpointType, clientIP string, dflt bool) {
+	now := time.Now()
+	ea := bssTypes.EndpointAccess{
+		Name:      name,
+		Endpoint:  accessType,
+		LastEpoch: now.Unix(),
+		ClientIP:  clientIP,
+		Default:   dflt,
+	}
+	if name == "" {
+		if clientIP == "" {
+			debugf("Not recording %s access by unknown client", accessType)
+			return
+		}
+		ea.Name = clientIP
+	}
+	full := fullEndpointHistory()
 	if useSQL {
-		err := bssdb.LogEndpointAccess(name, accessType)
+		var err error
+		start := time.Now()
+		if full {
+			err = bssdb.LogEndpointAccess(ea)
+			observeSQL("log_endpoint_access", start, err)
+		} else {
+			err = bssdb.SetEndpointAccess(ea)
+			observeSQL("set_endpoint_access", start, err)
+		}
 		if err != nil {
 			log.Printf("Failed to store last access timestamp for endpoint=%q name=%q to postgres DB: %s",
-				accessType, name, err)
+				accessType, ea.Name, err)
 		}
 	} else {
-		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
-		key := fmt.Sprintf("%s/%s/%s", endpointAccessPfx, name, accessType)
-		if err := kvstore.Store(key, timestamp); err != nil {
-			log.Printf("Failed to store last access timestamp %s to key %s: %s",
-				timestamp, key, err)
+		data, _ := json.Marshal(ea)
+		key := fmt.Sprintf("%s/%s/%s", endpointAccessPfx, ea.Name, accessType)
+		if err := kvstore.Store(key, string(data)); err != nil {
+			log.Printf("Failed to store last access timestamp %d to key %s: %s",
+				ea.LastEpoch, key, err)
 		}
+		if full {
+			key = fmt.Sprintf("%s/%s/%s/%d", endpointHistoryPfx, ea.Name, accessType, now.UnixNano())
+			if err := kvstore.Store(key, string(data)); err != nil {
+				log.Printf("Failed to store access timestamp %d to key %s: %s",
+					ea.LastEpoch, key, err)
+			}
+		}
+	}
+}
+
+// decodeEndpointAccess decodes an endpoint access stored in etcd, which is
+// either JSON or, if it was stored by an older BSS, just the timestamp.
+func decodeEndpointAccess(value string) (ea bssTypes.EndpointAccess, err error) {
+	if ts, e := strconv.ParseInt(value, 0, 64); e == nil {
+		ea.LastEpoch = ts
+		return ea, nil
+	}
+	if err = json.Unmarshal([]byte(value), &ea); err != nil {
+		err = fmt.Errorf("failed to decode endpoint access: %w", err)
 	}
+	return ea, err
 }
 
 func searchKeyspace(prefix string) ([]hmetcd.Kvi_KV, error) {
@@ -847,16 +929,13 @@ func getAccessesForPrefix(prefix string) (accesses []bssTypes.EndpointAccess, er
 		endpoint := endpointParts[len(endpointParts)-1]
 		name := endpointParts[len(endpointParts)-2]
 
-		lastEpoch, err := strconv.ParseInt(kv.Value, 0, 64)
+		newAccess, err := decodeEndpointAccess(kv.Value)
 		if err != nil {
-			err = fmt.Errorf("failed to convert timestamp to int: %w", err)
-		}
-
-		newAccess := bssTypes.EndpointAccess{
-			Name:      name,
-			Endpoint:  bssTypes.EndpointType(endpoint),
-			LastEpoch: lastEpoch,
+			log.Printf("Ignoring endpoint access %s: %v", kv.Key, err)
+			continue
 		}
+		newAccess.Name = name
+		newAccess.Endpoint = bssTypes.EndpointType(endpoint)
 
 		accesses = append(accesses, newAccess)
 	}
@@ -871,15 +950,15 @@ func SearchEndpointAccessed(name string, endpointType bssTypes.EndpointType) (ac
 	} else if name != "" && endpointType == "" {
 		return getAccessesForPrefix(fmt.Sprintf("%s/%s/", endpointAccessPfx, name))
 	} else if name != "" && endpointType != "" {
-		var epoch int64
-		epoch, err = getEndpointAccessed(name, endpointType)
+		var access bssTypes.EndpointAccess
+		access, err = getEndpointAccess(name, endpointType)
 		if err != nil {
 			return
 		}
-		// epoch == 0 means the given name and endpoint combo has never been accessed.
+		// LastEpoch == 0 means the given name and endpoint combo has never been accessed.
 		// A long existing bug/feature of bss has been to return a value in this case with a LastEpoch value of zero.
 		// The following preserves that behavior, but only if the endpoint type is valid.
-		if epoch == 0 {
+		if access.LastEpoch == 0 {
 			hasValidType := false
 			for _, t := range bssTypes.EndpointTypes {
 				if strings.EqualFold(string(endpointType), string(t)) {
@@ -891,11 +970,8 @@ func SearchEndpointAccessed(name string, endpointType bssTypes.EndpointType) (ac
 			}
 		}
 
-		access := bssTypes.EndpointAccess{
-			Name:      name,
-			Endpoint:  endpointType,
-			LastEpoch: epoch,
-		}
+		access.Name = name
+		access.Endpoint = endpointType
 		accesses = append(accesses, access)
 
 		return
@@ -907,24 +983,29 @@ func SearchEndpointAccessed(name string, endpointType bssTypes.EndpointType) (ac
 }
 
 func getEndpointAccessed(name string, endpointType bssTypes.EndpointType) (int64, error) {
+	ea, err := getEndpointAccess(name, endpointType)
+	if err != nil {
+		return -1, err
+	}
+	return ea.LastEpoch, nil
+}
+
+// getEndpointAccess returns the latest access of an endpoint by name, with
+// LastEpoch 0 if it has never been accessed.
+func getEndpointAccess(name string, endpointType bssTypes.EndpointType) (ea bssTypes.EndpointAccess, err error) {
 	key := fmt.Sprintf("%s/%s/%s", endpointAccessPfx, name, endpointType)
-	timestampString, exists, err := kvstore.Get(key)
+	value, exists, err := kvstore.Get(key)
 
 	if err != nil {
-		return -1, fmt.Errorf("failed to retreive last access timestamp at key %s: %w", key, err)
+		return ea, fmt.Errorf("failed to retreive last access timestamp at key %s: %w", key, err)
 	}
 
 	if !exists {
 		// Magic number, 0 meaning never accessed.
-		return 0, nil
-	}
-
-	ts, err := strconv.ParseInt(timestampString, 0, 64)
-	if err != nil {
-		return -1, fmt.Errorf("failed to convert timestamp to int: %w", err)
+		return ea, nil
 	}
 
-	return ts, nil
+	return decodeEndpointAccess(value)
 }
 
 func getTags() ([]hmetcd.Kvi_KV, error) {
@@ -990,7 +1071,8 @@ func lookupHost(name string) (BootDataStore, error) {
 // the default tag.  If boot parameter data is found, it will then convert from
 // storage format to an external format.  This conversion process involves
 // looking up the keys for the kernel and initrd images to their actual values,
-// namely their paths and any associated parameters.
+// namely their paths and any associated parameters.  Default is set in the
+// result when neither the names nor the role have boot parameter data.
 func lookup(name, altName, role, defaultTag string) BootData {
 	bds, err := lookupHost(name)
 	if err != nil && name != altName && altName != "" {
@@ -1004,6 +1086,7 @@ func lookup(name, altName, role, defaultTag string) BootData {
 			err = nil
 		}
 	}
+	dflt := err != nil
 	if err != nil && defaultTag != "" {
 		bds, tmpErr = lookupHost(defaultTag)
 		if tmpErr != nil {
@@ -1017,6 +1100,7 @@ func lookup(name, altName, role, defaultTag string) BootData {
 	if err == nil {
 		bd = bdConvert(bds)
 	}
+	bd.Default = dflt
 	return bd
 }
 
@@ -1077,28 +1161,26 @@ func LookupByRole(role string) (BootData, error) {
 	return bd, err
 }
 
-func LookupGlobalData() (BootData, error) {
-	return LookupByRole(GlobalTag)
+// lookupStoredCloudInit returns the cloud-init data stored under exactly
+// name, be it a node, a boot group, a role, or the global tag, without the
+// fallbacks of LookupByName.  Postgres does not store cloud-init data, so
+// there is never any with it.
+func lookupStoredCloudInit(name string) (bssTypes.CloudInit, error) {
+	if useSQL {
+		return bssTypes.CloudInit{}, fmt.Errorf("No cloud-init data is stored for %s in Postgres", name)
+	}
+	bd, err := LookupByRole(name)
+	return bd.CloudInit, err
 }
 
-func LookupComponentByName(name string) SMComponent {
-	comp, _ := FindSMCompByNameInCache(name)
-	return comp
+// lookupNodeCloudInit returns the cloud-init data stored for the node
+// itself.
+func lookupNodeCloudInit(xname string) (bssTypes.CloudInit, error) {
+	return lookupStoredCloudInit(xname)
 }
 
-func ToBootData(value string, kernelImages map[string]ImageData, initrdImages map[string]ImageData) (BootData, error) {
-	var bds BootDataStore
-	err := json.Unmarshal([]byte(value), &bds)
-	var bd BootData
-	if err != nil {
-		msg := fmt.Sprintf("Error parsing %s: %v", value, err)
-		herr := base.NewHMSError("Storage", msg)
-		herr.AddProblem(base.NewProblemDetailsStatus(msg, http.StatusNotFound))
-		err = herr
-	} else {
-		bd = bdConvertUsingImageCache(bds, kernelImages, initrdImages)
-	}
-	return bd, err
+func LookupGlobalData() (BootData, error) {
+	return LookupByRole(GlobalTag)
 }
 
 func LookupByName(name string) (BootData, SMComponent) {
@@ -1111,7 +1193,9 @@ func LookupByName(name string) (BootData, SMComponent) {
 	}
 	if useSQL {
 		var result BootData
+		start := time.Now()
 		bps, err := bssdb.GetBootParamsByName([]string{name})
+		observeSQL("get_by_name", start, err)
 		if err != nil {
 			err = fmt.Errorf("Could not retrieve boot parameters with name %q: %v", name, err)
 			log.Printf("ERROR: %v", err)
@@ -1119,6 +1203,7 @@ func LookupByName(name string) (BootData, SMComponent) {
 		}
 		if len(bps) == 0 {
 			// Not found.
+			result.Default = true
 			log.Printf("WARNING: Name %q did not return any results.", name)
 			return result, comp
 		} else if len(bps) > 1 {
@@ -1143,7 +1228,9 @@ func LookupByMAC(mac string) (BootData, SMComponent) {
 	}
 	if useSQL {
 		var result BootData
+		start := time.Now()
 		bps, err := bssdb.GetBootParamsByMac([]string{mac})
+		observeSQL("get_by_mac", start, err)
 		if err != nil {
 			err = fmt.Errorf("Could not retrieve boot parameters with mac %q: %v", mac, err)
 			log.Printf("ERROR: %v", err)
@@ -1151,6 +1238,7 @@ func LookupByMAC(mac string) (BootData, SMComponent) {
 		}
 		if len(bps) == 0 {
 			// Not found.
+			result.Default = true
 			log.Printf("WARNING: MAC %q did not return any results.", mac)
 			return result, comp
 		} else if len(bps) > 1 {
@@ -1176,7 +1264,9 @@ func LookupByNid(nid int) (BootData, SMComponent) {
 	}
 	if useSQL {
 		var result BootData
+		start := time.Now()
 		bps, err := bssdb.GetBootParamsByNid([]int32{int32(nid)})
+		observeSQL("get_by_nid", start, err)
 		if err != nil {
 			err = fmt.Errorf("Could not retrieve boot parameters with NID %d: %v", nid, err)
 			log.Printf("ERROR: %v", err)
@@ -1184,6 +1274,7 @@ func LookupByNid(nid int) (BootData, SMComponent) {
 		}
 		if len(bps) == 0 {
 			// Not found.
+			result.Default = true
 			log.Printf("WARNING: NID %d did not return any results.", nid)
 			return result, comp
 		} else if len(bps) > 1 {
@@ -0,0 +1,246 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Cache of boot script lookups.
+//
+// Serving a boot script means looking up the node in HSM state, reading its
+// boot parameters and images from storage, and building the static part of
+// the kernel command line. Those results are cached per lookup key so that a
+// large reboot can be served from memory. Per-request secrets, i.e. SPIRE
+// join tokens and presigned S3 URLs, are never cached; they are filled in
+// for every request.
+//
+// Entries are keyed by the node a request resolves to, i.e. its xname, plus
+// the normalized MAC address the request gave, since that ends up in the
+// kernel parameters.  Requests for nodes not in the inventory are keyed by
+// the normalized identifier they gave.
+//
+// Every change to boot parameters or HSM state bumps the cache revision and
+// empties the cache. Entries carry the revision they were looked up at, so a
+// lookup that raced with a change is not stored. Boot parameter changes made
+// through another BSS replica are learnt of from the config signal, whose
+// revision is checked on every lookup; HSM state changes from the state
+// signal, which refreshes the state.  Entries also expire after
+// bootscriptCacheTTL.
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	bootscriptCacheTTL  = uint(30)    // Seconds a cached lookup is used, 0 to disable the cache
+	bootscriptCacheSize = uint(50000) // Maximum number of cached lookups
+
+	bootCache = newBootScriptCache()
+)
+
+type bootCacheEntry struct {
+	bd       BootData
+	comp     SMComponent
+	params   string // Kernel parameters before per-request substitution
+	revision uint64
+	expires  time.Time
+}
+
+type bootScriptCache struct {
+	mu       sync.RWMutex
+	revision uint64
+	entries  map[string]bootCacheEntry
+	// The config signal revision the entries were looked up at.
+	configRevision int64
+}
+
+func newBootScriptCache() *bootScriptCache {
+	return &bootScriptCache{entries: make(map[string]bootCacheEntry)}
+}
+
+// currentRevision returns the revision to pass to put after a lookup.
+func (c *bootScriptCache) currentRevision() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.revision
+}
+
+func (c *bootScriptCache) get(key string) (bootCacheEntry, bool) {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && (e.revision != c.currentRevision() || time.Now().After(e.expires)) {
+		ok = false
+	}
+	return e, ok
+}
+
+// put stores an entry unless the cache was invalidated since revision was read.
+func (c *bootScriptCache) put(key string, e bootCacheEntry, ttl time.Duration, max int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.revision != c.revision {
+		return
+	}
+	now := time.Now()
+	if len(c.entries) >= max {
+		for k, old := range c.entries {
+			if now.After(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= max {
+			return
+		}
+	}
+	e.expires = now.Add(ttl)
+	c.entries[key] = e
+}
+
+func (c *bootScriptCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revision++
+	c.entries = make(map[string]bootCacheEntry)
+}
+
+// sync empties the cache if the config signal revision changed since the
+// last call, i.e. some instance changed boot parameters.
+func (c *bootScriptCache) sync(configRevision int64) {
+	c.mu.RLock()
+	current := c.configRevision == configRevision
+	c.mu.RUnlock()
+	if current {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.configRevision != configRevision {
+		c.configRevision = configRevision
+		c.revision++
+		c.entries = make(map[string]bootCacheEntry)
+	}
+}
+
+func (c *bootScriptCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// invalidateBootCache is called whenever boot parameters, images, or HSM
+// state change.
+func invalidateBootCache() {
+	bootCache.invalidate()
+}
+
+// bootConfigChanged is called whenever boot parameters or images change.  It
+// also tells other instances to drop their cached lookups.
+func bootConfigChanged() {
+	invalidateBootCache()
+	if configSig == nil {
+		return
+	}
+	if err := configSig.changed(); err != nil {
+		log.Printf("WARNING: failed to record the boot parameter change for other instances, "+
+			"which may serve cached boot scripts until they expire: %v", err)
+	}
+}
+
+// bootCacheKey returns the cache key of a boot script request by MAC, name,
+// or NID, in that order of preference.
+func bootCacheKey(mac, name string, nid int) (string, bool) {
+	switch {
+	case mac != "":
+		mac = normalizeMAC(mac)
+		if comp, ok := FindSMCompByMAC(mac); ok {
+			return "node/" + comp.ID + "/mac/" + mac, true
+		}
+		return "mac/" + mac, true
+	case name != "":
+		if comp, ok := FindSMCompByName(name); ok {
+			return "node/" + comp.ID, true
+		}
+		return "name/" + name, true
+	case nid >= 0:
+		if comp, ok := FindSMCompByNid(nid); ok {
+			return "node/" + comp.ID, true
+		}
+		return "nid/" + strconv.Itoa(nid), true
+	}
+	return "", false
+}
+
+// bootLookup finds the boot data and component for a boot script request by
+// MAC, name, or NID, in that order of preference, along with the static
+// kernel parameters. It returns false if no identifier was given.
+func bootLookup(mac, name string, nid int) (bootCacheEntry, bool) {
+	key, ok := bootCacheKey(mac, name, nid)
+	if !ok {
+		return bootCacheEntry{}, false
+	}
+	if mac != "" {
+		mac = normalizeMAC(mac)
+	}
+
+	configMutex.RLock()
+	ttl := bootscriptCacheTTL
+	configMutex.RUnlock()
+	if ttl > 0 && configSig != nil {
+		// Without the revision, changes made through other instances
+		// could go unnoticed, so the cache is not used.
+		if rev, err := configSig.revision(); err != nil {
+			log.Printf("WARNING: failed to read the boot configuration revision, not using cached boot scripts: %v", err)
+			ttl = 0
+		} else {
+			bootCache.sync(rev)
+		}
+	}
+	if ttl > 0 {
+		if e, ok := bootCache.get(key); ok {
+			bootCacheRequests.WithLabelValues("hit").Inc()
+			return e, true
+		}
+		bootCacheRequests.WithLabelValues("miss").Inc()
+	}
+
+	e := bootCacheEntry{revision: bootCache.currentRevision()}
+	switch {
+	case mac != "":
+		e.bd, e.comp = LookupByMAC(mac)
+	case name != "":
+		e.bd, e.comp = LookupByName(name)
+	default:
+		e.bd, e.comp = LookupByNid(nid)
+	}
+	e.params = staticParams(e.bd, scriptParams{
+		xname:         e.comp.ID,
+		nid:           e.comp.NID.String(),
+		referralToken: e.bd.ReferralToken,
+		mac:           mac,
+	})
+	if ttl > 0 {
+		bootCache.put(key, e, time.Duration(ttl)*time.Second, int(bootscriptCacheSize))
+	}
+	return e, true
+}
@@ -0,0 +1,132 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBootScriptCache(t *testing.T) {
+	c := newBootScriptCache()
+
+	stale := bootCacheEntry{revision: c.currentRevision()}
+	c.invalidate()
+	c.put("mac/a", stale, time.Minute, 10)
+	if _, ok := c.get("mac/a"); ok {
+		t.Errorf("Entry looked up before an invalidation was cached")
+	}
+
+	c.put("mac/a", bootCacheEntry{revision: c.currentRevision()}, time.Minute, 10)
+	if _, ok := c.get("mac/a"); !ok {
+		t.Errorf("Entry was not cached")
+	}
+	c.put("mac/b", bootCacheEntry{revision: c.currentRevision()}, -time.Second, 10)
+	if _, ok := c.get("mac/b"); ok {
+		t.Errorf("Expired entry was returned")
+	}
+
+	c.put("mac/c", bootCacheEntry{revision: c.currentRevision()}, time.Minute, 2)
+	if _, ok := c.get("mac/c"); !ok {
+		t.Errorf("Entry was not cached after evicting an expired one")
+	}
+	c.put("mac/d", bootCacheEntry{revision: c.currentRevision()}, time.Minute, 2)
+	if _, ok := c.get("mac/d"); ok {
+		t.Errorf("Entry was cached beyond the size limit")
+	}
+
+	c.invalidate()
+	if c.len() != 0 {
+		t.Errorf("Cache holds %d entries after invalidation", c.len())
+	}
+}
+
+func TestBootLookupInvalidation(t *testing.T) {
+	const host = "x0c0s18b0n0"
+	if err, _ := Store(bssTypes.BootParams{Hosts: []string{host}, Params: "before", Kernel: "/test/path/vmlinuz"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	defer Remove(bssTypes.BootParams{Hosts: []string{host}})
+
+	hits := testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit"))
+	e, _ := bootLookup("", host, -1)
+	if e.bd.Params != "before" || !strings.Contains(e.params, "xname="+host) {
+		t.Fatalf("bootLookup returned params %q, static params %q", e.bd.Params, e.params)
+	}
+	if _, ok := bootLookup("", host, -1); !ok {
+		t.Fatalf("bootLookup failed")
+	}
+	if testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit")) != hits+1 {
+		t.Errorf("Second lookup was not answered from the cache")
+	}
+
+	phoneHome := bssTypes.CloudInit{PhoneHome: bssTypes.PhoneHome{Hostname: host}}
+	if err := Update(bssTypes.BootParams{Hosts: []string{host}, CloudInit: phoneHome}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	bootLookup("", host, -1)
+	if testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit")) != hits+2 {
+		t.Errorf("Cloud-init update invalidated the cache")
+	}
+
+	if err := Update(bssTypes.BootParams{Hosts: []string{host}, Params: "after"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if e, _ = bootLookup("", host, -1); e.bd.Params != "after" {
+		t.Errorf("bootLookup returned params %q after update, expected \"after\"", e.bd.Params)
+	}
+}
+
+func TestBootLookupKeys(t *testing.T) {
+	const host, mac = "x0c0s1b0n0", "00:1e:67:e3:46:51"
+	if err, _ := Store(bssTypes.BootParams{Hosts: []string{host}, Params: "keys", Kernel: "/test/path/vmlinuz"}); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	defer Remove(bssTypes.BootParams{Hosts: []string{host}})
+
+	hits := testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit"))
+	for _, m := range []string{mac, "00:1E:67:E3:46:51", "001e67e34651"} {
+		if e, _ := bootLookup(m, "", -1); e.comp.ID != host {
+			t.Fatalf("bootLookup(%q) found %q", m, e.comp.ID)
+		}
+	}
+	if got := testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit")) - hits; got != 2 {
+		t.Errorf("Lookups by differently written MAC addresses got %v cache hits, expected 2", got)
+	}
+
+	// A change made through another instance is seen through the config
+	// signal.
+	bootLookup("", host, -1)
+	hits = testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit"))
+	if err := kvstore.Store(configRevisionKey, "1"); err != nil {
+		t.Fatalf("Unable to store the config revision: %v", err)
+	}
+	bootLookup("", host, -1)
+	if testutil.ToFloat64(bootCacheRequests.WithLabelValues("hit")) != hits {
+		t.Errorf("Lookup after a change by another instance was answered from the cache")
+	}
+}
@@ -0,0 +1,285 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Boot session tracking.  A node's boot script, user-data, and phone-home
+// requests are linked into one session per boot attempt, so that nodes whose
+// boots stall or loop can be found.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/internal/postgres"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+const bootSessionPfx = "/bootsessions/"
+
+var (
+	bootSessionsEnabled = false     // Track boot sessions
+	bootDeadline        = uint(900) // Seconds after its boot script within which a node must fetch user-data or phone home
+	bootRetryLimit      = uint(3)   // Retries or restarts without progress after which a node is looping
+
+	bootSessions bootSessionStore
+	// Serializes updates to a node's session made by this instance.
+	bootSessionLocks keyLocks
+)
+
+type bootEvent int
+
+const (
+	bootEventBootscript bootEvent = iota
+	bootEventUserData
+	bootEventPhoneHome
+)
+
+type bootSessionStore interface {
+	get(name string) (bssTypes.BootSession, bool, error)
+	put(bs bssTypes.BootSession) error
+	list() ([]bssTypes.BootSession, error)
+}
+
+// etcdBootSessionStore keeps each node's session as JSON under
+// bootSessionPfx.
+type etcdBootSessionStore struct{}
+
+func (etcdBootSessionStore) get(name string) (bs bssTypes.BootSession, exists bool, err error) {
+	val, exists, err := kvstore.Get(bootSessionPfx + name)
+	if err != nil || !exists {
+		return bs, false, err
+	}
+	err = json.Unmarshal([]byte(val), &bs)
+	return bs, err == nil, err
+}
+
+func (etcdBootSessionStore) put(bs bssTypes.BootSession) error {
+	data, err := json.Marshal(bs)
+	if err != nil {
+		return err
+	}
+	return kvstore.Store(bootSessionPfx+bs.Name, string(data))
+}
+
+func (etcdBootSessionStore) list() ([]bssTypes.BootSession, error) {
+	kvl, err := kvstore.GetRange(bootSessionPfx+keyMin, bootSessionPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]bssTypes.BootSession, 0, len(kvl))
+	for _, kv := range kvl {
+		var bs bssTypes.BootSession
+		if err := json.Unmarshal([]byte(kv.Value), &bs); err != nil {
+			log.Printf("WARNING: ignoring malformed boot session %s: %v", kv.Key, err)
+			continue
+		}
+		sessions = append(sessions, bs)
+	}
+	return sessions, nil
+}
+
+// sqlBootSessionStore keeps sessions in the boot_sessions table.
+type sqlBootSessionStore struct {
+	db postgres.BootDataDatabase
+}
+
+func (s sqlBootSessionStore) get(name string) (bssTypes.BootSession, bool, error) {
+	start := time.Now()
+	bs, exists, err := s.db.GetBootSession(name)
+	observeSQL("get_boot_session", start, err)
+	return bs, exists, err
+}
+
+func (s sqlBootSessionStore) put(bs bssTypes.BootSession) error {
+	start := time.Now()
+	err := s.db.SetBootSession(bs)
+	observeSQL("set_boot_session", start, err)
+	return err
+}
+
+func (s sqlBootSessionStore) list() ([]bssTypes.BootSession, error) {
+	start := time.Now()
+	sessions, err := s.db.GetBootSessions()
+	observeSQL("get_boot_sessions", start, err)
+	return sessions, err
+}
+
+// initBootSessions sets up boot session tracking, if enabled, in the
+// configured datastore.  The datastore must already be open.
+func initBootSessions() {
+	if !bootSessionsEnabled {
+		return
+	}
+	if useSQL {
+		bootSessions = sqlBootSessionStore{db: bssdb}
+	} else if kvstore != nil {
+		bootSessions = etcdBootSessionStore{}
+	}
+	log.Printf("Tracking boot sessions")
+}
+
+// advanceBootSession applies an event that happened at now to the latest
+// session of the node named name.  A boot script request without a retry
+// counter starts a new session; Attempts counts the consecutive sessions that
+// did not get as far as user-data or phone-home.
+func advanceBootSession(bs bssTypes.BootSession, exists bool, name string, ev bootEvent, retry int, now int64) bssTypes.BootSession {
+	if !exists || (ev == bootEventBootscript && retry == 0) {
+		attempts := int32(1)
+		if exists && bs.UserData == 0 && bs.PhoneHome == 0 {
+			attempts = bs.Attempts + 1
+		}
+		bs = bssTypes.BootSession{Name: name, Started: now, Attempts: attempts}
+	}
+	switch ev {
+	case bootEventBootscript:
+		bs.Bootscript = now
+		if int32(retry) > bs.Retries {
+			bs.Retries = int32(retry)
+		}
+	case bootEventUserData:
+		bs.UserData = now
+	case bootEventPhoneHome:
+		bs.PhoneHome = now
+	}
+	return bs
+}
+
+// recordBootEvent adds an event to the latest boot session of a node.  The
+// datastore is updated in the background so that the request is not delayed.
+func recordBootEvent(name string, ev bootEvent, retry int) {
+	if bootSessions == nil || name == "" {
+		return
+	}
+	now := time.Now().Unix()
+	goBackground(func() {
+		defer bootSessionLocks.lock(name)()
+		bs, exists, err := bootSessions.get(name)
+		if err != nil {
+			log.Printf("Failed to read boot session of %s: %v", name, err)
+			return
+		}
+		bs = advanceBootSession(bs, exists, name, ev, retry, now)
+		if err = bootSessions.put(bs); err != nil {
+			log.Printf("Failed to store boot session of %s: %v", name, err)
+		}
+	})
+}
+
+// bootSessionState classifies a session as of now.
+func bootSessionState(bs bssTypes.BootSession, now int64, deadline, retryLimit uint) bssTypes.BootSessionState {
+	switch {
+	case bs.PhoneHome != 0:
+		return bssTypes.BootSessionComplete
+	case bs.UserData != 0:
+		return bssTypes.BootSessionUserData
+	case retryLimit > 0 && (bs.Retries >= int32(retryLimit) || bs.Attempts > int32(retryLimit)):
+		return bssTypes.BootSessionLooping
+	case deadline > 0 && now-bs.Started > int64(deadline):
+		return bssTypes.BootSessionStuck
+	default:
+		return bssTypes.BootSessionBooting
+	}
+}
+
+func bootSessionsGetAPI(w http.ResponseWriter, r *http.Request) {
+	if bootSessions == nil {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, "Boot session tracking is not enabled")
+		return
+	}
+	r.ParseForm() // r.Form is empty until after parsing
+	names := make(map[string]bool)
+	for _, n := range r.Form["name"] {
+		for _, name := range strings.Split(n, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names[name] = true
+			}
+		}
+	}
+	states := make(map[bssTypes.BootSessionState]bool)
+	for _, s := range r.Form["state"] {
+		for _, state := range strings.Split(s, ",") {
+			st := bssTypes.BootSessionState(strings.ToLower(strings.TrimSpace(state)))
+			valid := false
+			for _, v := range bssTypes.BootSessionStates {
+				valid = valid || st == v
+			}
+			if !valid {
+				base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+					fmt.Sprintf("Invalid state %q", state))
+				return
+			}
+			states[st] = true
+		}
+	}
+
+	var (
+		sessions []bssTypes.BootSession
+		err      error
+	)
+	if len(names) == 1 {
+		for name := range names {
+			var bs bssTypes.BootSession
+			var exists bool
+			if bs, exists, err = bootSessions.get(name); exists {
+				sessions = append(sessions, bs)
+			}
+		}
+	} else {
+		sessions, err = bootSessions.list()
+	}
+	if err != nil {
+		log.Printf("Failed to read boot sessions: %v", err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to read boot sessions")
+		return
+	}
+
+	configMutex.RLock()
+	deadline, retryLimit := bootDeadline, bootRetryLimit
+	configMutex.RUnlock()
+	now := time.Now().Unix()
+	// Always make sure to give back at least an empty array instead of `null`.
+	results := []bssTypes.BootSession{}
+	for _, bs := range sessions {
+		if len(names) > 0 && !names[bs.Name] {
+			continue
+		}
+		bs.State = bootSessionState(bs, now, deadline, retryLimit)
+		if len(states) > 0 && !states[bs.State] {
+			continue
+		}
+		results = append(results, bs)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
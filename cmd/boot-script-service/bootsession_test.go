@@ -0,0 +1,128 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+func TestAdvanceBootSession(t *testing.T) {
+	var bs bssTypes.BootSession
+	exists := false
+	step := func(ev bootEvent, retry int, now int64) {
+		bs = advanceBootSession(bs, exists, "x0", ev, retry, now)
+		exists = true
+	}
+
+	step(bootEventBootscript, 0, 100)
+	step(bootEventBootscript, 1, 130)
+	step(bootEventBootscript, 2, 160)
+	if bs.Started != 100 || bs.Bootscript != 160 || bs.Retries != 2 || bs.Attempts != 1 {
+		t.Errorf("Retries did not continue the session: %+v", bs)
+	}
+	// The node restarted without getting any further.
+	step(bootEventBootscript, 0, 500)
+	if bs.Started != 500 || bs.Retries != 0 || bs.Attempts != 2 {
+		t.Errorf("Restart did not start a new attempt: %+v", bs)
+	}
+	step(bootEventUserData, 0, 540)
+	step(bootEventPhoneHome, 0, 560)
+	if bs.Started != 500 || bs.UserData != 540 || bs.PhoneHome != 560 {
+		t.Errorf("Cloud-init events not linked to the session: %+v", bs)
+	}
+	// The next boot follows a successful one.
+	step(bootEventBootscript, 0, 900)
+	if bs.Attempts != 1 || bs.UserData != 0 || bs.PhoneHome != 0 {
+		t.Errorf("New boot after success did not reset the session: %+v", bs)
+	}
+
+	bs = advanceBootSession(bssTypes.BootSession{}, false, "x1", bootEventUserData, 0, 50)
+	if bs.Name != "x1" || bs.Started != 50 || bs.UserData != 50 {
+		t.Errorf("User-data without a boot script did not start a session: %+v", bs)
+	}
+}
+
+func TestBootSessionState(t *testing.T) {
+	tests := []struct {
+		bs    bssTypes.BootSession
+		state bssTypes.BootSessionState
+	}{
+		{bssTypes.BootSession{Started: 950, Attempts: 1}, bssTypes.BootSessionBooting},
+		{bssTypes.BootSession{Started: 800, Attempts: 1}, bssTypes.BootSessionStuck},
+		{bssTypes.BootSession{Started: 950, Attempts: 1, Retries: 3}, bssTypes.BootSessionLooping},
+		{bssTypes.BootSession{Started: 950, Attempts: 4}, bssTypes.BootSessionLooping},
+		{bssTypes.BootSession{Started: 800, Attempts: 4, UserData: 810}, bssTypes.BootSessionUserData},
+		{bssTypes.BootSession{Started: 800, Attempts: 1, UserData: 810, PhoneHome: 820}, bssTypes.BootSessionComplete},
+	}
+	for _, tc := range tests {
+		if state := bootSessionState(tc.bs, 1000, 100, 3); state != tc.state {
+			t.Errorf("%+v: got %s, expected %s", tc.bs, state, tc.state)
+		}
+	}
+	if state := bootSessionState(bssTypes.BootSession{Started: 0, Retries: 9}, 1000, 0, 0); state != bssTypes.BootSessionBooting {
+		t.Errorf("Disabled deadline and retry limit gave %s", state)
+	}
+}
+
+func TestBootSessionsGetAPI(t *testing.T) {
+	saved := bootSessions
+	defer func() { bootSessions = saved }()
+	bootSessions = etcdBootSessionStore{}
+	defer kvstore.Delete(bootSessionPfx + "x9100c0s0b0n0")
+	defer kvstore.Delete(bootSessionPfx + "x9100c0s1b0n0")
+
+	recordBootEvent("x9100c0s0b0n0", bootEventBootscript, 0)
+	recordBootEvent("x9100c0s1b0n0", bootEventBootscript, 0)
+	backgroundTasks.Wait()
+	recordBootEvent("x9100c0s1b0n0", bootEventUserData, 0)
+	backgroundTasks.Wait()
+
+	get := func(query string) (int, []bssTypes.BootSession) {
+		rr := httptest.NewRecorder()
+		bootSessionsGet(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/boot-sessions"+query, nil))
+		var sessions []bssTypes.BootSession
+		json.Unmarshal(rr.Body.Bytes(), &sessions)
+		return rr.Code, sessions
+	}
+	if code, s := get("?name=x9100c0s0b0n0,x9100c0s1b0n0"); code != http.StatusOK || len(s) != 2 ||
+		s[0].State != bssTypes.BootSessionBooting || s[1].State != bssTypes.BootSessionUserData {
+		t.Errorf("Unexpected sessions (%d): %+v", code, s)
+	}
+	if _, s := get("?name=x9100c0s1b0n0"); len(s) != 1 || s[0].UserData == 0 {
+		t.Errorf("Unexpected session: %+v", s)
+	}
+	if _, s := get("?state=user-data"); len(s) != 1 || s[0].Name != "x9100c0s1b0n0" {
+		t.Errorf("State filter returned %+v", s)
+	}
+	if code, _ := get("?state=bogus"); code != http.StatusBadRequest {
+		t.Errorf("Invalid state returned %d", code)
+	}
+	if _, s := get("?name=x9999c0s0b0n0"); s == nil || len(s) != 0 {
+		t.Errorf("Unknown node returned %+v", s)
+	}
+}
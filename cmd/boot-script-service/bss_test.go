@@ -35,7 +35,9 @@ import (
 	"os"
 	"testing"
 
+	base "github.com/Cray-HPE/hms-base"
 	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	"github.com/OpenCHAMI/smd/v2/pkg/sm"
 )
 
 func TestMain(m *testing.M) {
@@ -51,6 +53,7 @@ func TestMain(m *testing.M) {
 		fmt.Fprintf(os.Stderr, "Test SM data decode failed: %v\n", err)
 	} else {
 		SmOpen("mem:", "")
+		initStateSignal()
 		excode = m.Run()
 	}
 	os.Exit(excode)
@@ -200,3 +203,39 @@ func TestStoreAndLookup(t *testing.T) {
 			len(tables), len(bplist))
 	}
 }
+
+func TestSMIndex(t *testing.T) {
+	c, ok := FindSMCompByMAC("00-1E-67-E3-46-52")
+	if !ok || c.ID != "x0c0s1b0n0" {
+		t.Errorf("FindSMCompByMAC did not normalize the MAC address, got %q", c.ID)
+	}
+
+	comps := FindSMCompsByFQDN("X0C0S18B0N0.test.com")
+	if len(comps) != 2 || comps[0].ID != "x0c0s18b0n0" || comps[1].ID != "x0c1s18b0n0" {
+		t.Errorf("FindSMCompsByFQDN returned %v, expected x0c0s18b0n0 and x0c1s18b0n0", comps)
+	}
+
+	// x0c0s1b0n0 was stored by TestStoreAndLookup.
+	rr := httptest.NewRecorder()
+	BootparametersGet(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/bootparameters?mac=00-1E-67-E3-46-51", nil))
+	var bps []bssTypes.BootParams
+	if err := json.Unmarshal(rr.Body.Bytes(), &bps); err != nil || len(bps) != 1 || bps[0].Hosts[0] != "x0c0s1b0n0" {
+		t.Errorf("BootparametersGet by MAC returned %d: %s", rr.Code, rr.Body)
+	}
+
+	idx := makeSmIndex(&SMData{
+		Components: []SMComponent{
+			{Component: base.Component{ID: "x0c0s0b0n0", State: "Empty"}, Mac: []string{"00:11:22:33:44:55"}},
+			{Component: base.Component{ID: "x0c0s0b1n0"}, Mac: []string{"00:11:22:33:44:55"}},
+		},
+		IPAddrs: map[string]sm.CompEthInterfaceV2{
+			"2001:db8:0:0::1": {CompID: "x0c0s0b1n0"},
+		},
+	})
+	if xname := idx.byIP[normalizeIP("2001:db8::1")]; xname != "x0c0s0b1n0" {
+		t.Errorf("IP index returned %q for 2001:db8::1", xname)
+	}
+	if n := len(idx.byMAC["00:11:22:33:44:55"]); n != 2 {
+		t.Errorf("MAC index holds %d components, expected 2", n)
+	}
+}
@@ -26,13 +26,21 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/OpenCHAMI/bss/internal/postgres"
 	"github.com/OpenCHAMI/bss/pkg/bssTypes"
 
 	yaml "gopkg.in/yaml.v2"
@@ -118,12 +126,12 @@ func generateMetaData(xname string, metadata map[string]interface{}) error {
 func mergeMaps(first, second map[string]interface{}) map[string]interface{} {
 	for key, secondVal := range second {
 		if firstVal, present := first[key]; present {
-			switch firstVal.(type) {
-			case map[string]interface{}:
+			firstMap, firstIsMap := firstVal.(map[string]interface{})
+			secondMap, secondIsMap := secondVal.(map[string]interface{})
+			if firstIsMap && secondIsMap {
 				// value is also a map interface, so recurse into it
-				first[key] = mergeMaps(firstVal.(map[string]interface{}), secondVal.(map[string]interface{}))
-				continue
-			default:
+				first[key] = mergeMaps(firstMap, secondMap)
+			} else {
 				first[key] = secondVal
 			}
 		} else {
@@ -134,52 +142,69 @@ func mergeMaps(first, second map[string]interface{}) map[string]interface{} {
 	return first
 }
 
-func metaDataGetAPI(w http.ResponseWriter, r *http.Request) {
-	var respData map[string]interface{}
-	var httpStatus = http.StatusOK
-	var isDefault = false
-
-	remoteaddr := findRemoteAddr(r)
+// cloudInitNode is what the cloud-init endpoints find out about the client
+// asking for its data.
+type cloudInitNode struct {
+	xname    string
+	found    bool
+	bootdata BootData
+	metaData map[string]interface{}
+	roleData bssTypes.CloudInit
+	dflt     bool // The client gets default data rather than its own
+}
 
-	// Get the xname to lookup metadata.
-	xname, found := FindXnameByIP(remoteaddr)
-	if !found {
-		isDefault = true
+// resolveCloudInitNode finds the node the request at remoteaddr comes from,
+// its boot data, its meta-data including what is discovered about it, and
+// the cloud-init data of its shasta-role.  A client that does not resolve to a node
+// gets the default data.
+func resolveCloudInitNode(remoteaddr string) cloudInitNode {
+	var node cloudInitNode
+	node.xname, node.found = FindXnameByIP(remoteaddr)
+	if !node.found {
 		log.Printf("CloudInit -> No XName found for: %s, using default data\n", remoteaddr)
 	}
 
 	// If name is "" here, LookupByName uses the default tag, which is what we want.
-	bootdata, _ := LookupByName(xname)
-	globaldata, _ := LookupGlobalData()
-
-	log.Printf("GET /meta-data, xname: %s ip: %s", xname, remoteaddr)
-	respData = bootdata.CloudInit.MetaData
+	node.bootdata, _ = LookupByName(node.xname)
+	node.metaData = node.bootdata.CloudInit.MetaData
 	// If empty, initialize an empty map
-	if len(respData) == 0 {
-		respData = make(map[string]interface{})
+	if len(node.metaData) == 0 {
+		node.metaData = make(map[string]interface{})
 	}
-
-	if isDefault {
-		respData["instance-id"] = generateInstanceID("")
-	} else {
-		err := generateMetaData(xname, respData)
-		if err != nil {
-			log.Printf("Warning - %s: Some meta data could not be found!\n", xname)
+	if node.found {
+		if err := generateMetaData(node.xname, node.metaData); err != nil {
+			log.Printf("Warning - %s: Some meta data could not be found!\n", node.xname)
 		}
+	} else {
+		node.metaData["instance-id"] = generateInstanceID("")
 	}
 
-	roleData := BootData{}
-	shastaRole := respData["shasta-role"]
-	if shastaRole != nil {
-		roleData, _ = LookupByRole(shastaRole.(string))
+	roleFound := false
+	if shastaRole, ok := node.metaData["shasta-role"].(string); ok && shastaRole != "" {
+		var err error
+		node.roleData, err = lookupStoredCloudInit(shastaRole)
+		roleFound = err == nil
 	}
-	roleInitData := roleData.CloudInit.MetaData
+	node.dflt = !node.found || (node.bootdata.Default && !roleFound)
+	return node
+}
+
+func metaDataGetAPI(w http.ResponseWriter, r *http.Request) {
+	var httpStatus = http.StatusOK
+
+	remoteaddr := findRemoteAddr(r)
+	node := resolveCloudInitNode(remoteaddr)
+	xname := node.xname
+	globaldata, _ := LookupGlobalData()
+
+	log.Printf("GET /meta-data, xname: %s ip: %s", xname, remoteaddr)
+	roleInitData := node.roleData.MetaData
 	if len(roleInitData) == 0 {
 		roleInitData = make(map[string]interface{})
 	}
 
 	// Override any role data from the per node data
-	mergedData := mergeMaps(roleInitData, respData)
+	mergedData := mergeMaps(roleInitData, node.metaData)
 
 	globalRespData := globaldata.CloudInit.MetaData
 	// If empty, initialize an empty map
@@ -208,6 +233,9 @@ func metaDataGetAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(httpStatus)
+
+	// Record the fact this was asked for.
+	updateEndpointAccessed(xname, bssTypes.EndpointTypeMetaData, remoteaddr, node.dflt)
 	return
 
 }
@@ -215,38 +243,12 @@ func metaDataGetAPI(w http.ResponseWriter, r *http.Request) {
 func userDataGetAPI(w http.ResponseWriter, r *http.Request) {
 	var respData map[string]interface{}
 	var httpStatus = http.StatusOK
-	isDefault := false
 
 	remoteaddr := findRemoteAddr(r)
+	node := resolveCloudInitNode(remoteaddr)
+	xname, bootdata, metaData, roleData := node.xname, node.bootdata, node.metaData, node.roleData
 
-	// Get the xname to lookup metadata.
-	xname, found := FindXnameByIP(remoteaddr)
-	if !found {
-		isDefault = true
-		log.Printf("CloudInit -> No XName found for: %s, using default data\n", remoteaddr)
-	}
-
-	// If name is "" here, LookupByName uses the default tag, which is what we want.
-	bootdata, _ := LookupByName(xname)
-	metaData := bootdata.CloudInit.MetaData
-
-	if len(metaData) == 0 {
-		metaData = make(map[string]interface{})
-	}
-
-	if !isDefault {
-		err := generateMetaData(xname, metaData)
-		if err != nil {
-			log.Printf("Warning - %s: Some meta data could not be found!\n", xname)
-		}
-	}
-
-	roleData := BootData{}
-	shastaRole := metaData["shasta-role"]
-	if shastaRole != nil {
-		roleData, _ = LookupByRole(shastaRole.(string))
-	}
-	roleInitData := roleData.CloudInit.UserData
+	roleInitData := roleData.UserData
 	if len(roleInitData) == 0 {
 		roleInitData = make(map[string]interface{})
 	}
@@ -269,17 +271,154 @@ func userDataGetAPI(w http.ResponseWriter, r *http.Request) {
 		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Invalid YAML")
 		return
 	}
+	cloudConfig := "#cloud-config\n" + string(databytes)
 
-	w.Header().Set("Content-Type", "text/yaml")
-	w.WriteHeader(httpStatus)
-	_, _ = fmt.Fprintf(w, "#cloud-config\n%s", string(databytes))
+	globaldata, _ := LookupGlobalData()
+	parts := mergeUserDataParts(globaldata.CloudInit.UserDataParts,
+		roleData.UserDataParts, bootdata.CloudInit.UserDataParts)
+	if len(parts) == 0 {
+		w.Header().Set("Content-Type", "text/yaml")
+		w.WriteHeader(httpStatus)
+		_, _ = fmt.Fprint(w, cloudConfig)
+	} else {
+		body, contentType, err := multipartUserData(cloudConfig, parts)
+		if err != nil {
+			log.Printf("ERROR: %s: failed to build multi-part user-data: %v", xname, err)
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				"Failed to build multi-part user-data")
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(httpStatus)
+		_, _ = w.Write(body)
+	}
 
 	// Record the fact this was asked for.
-	updateEndpointAccessed(xname, bssTypes.EndpointTypeUserData)
+	updateEndpointAccessed(xname, bssTypes.EndpointTypeUserData, remoteaddr, node.dflt)
+	recordBootEvent(xname, bootEventUserData, 0)
 
 	return
 }
 
+// mergeUserDataParts returns the user-data parts of the given levels, least
+// specific first.  A part with the same filename as one of a less specific
+// level replaces it, and a part repeated unchanged is only sent once.
+func mergeUserDataParts(levels ...[]bssTypes.UserDataPart) []bssTypes.UserDataPart {
+	var parts []bssTypes.UserDataPart
+	for _, level := range levels {
+	next:
+		for _, part := range level {
+			for i, p := range parts {
+				if p == part || (part.Filename != "" && p.Filename == part.Filename) {
+					parts[i] = part
+					continue next
+				}
+			}
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// multipartUserData returns user-data made of the cloud-config and the given
+// parts as a MIME multi-part message, as cloud-init expects it, along with
+// its content type.
+func multipartUserData(cloudConfig string, parts []bssTypes.UserDataPart) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	contentType := mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()})
+	fmt.Fprintf(&buf, "Content-Type: %s\r\nMIME-Version: 1.0\r\n\r\n", contentType)
+
+	all := append([]bssTypes.UserDataPart{{
+		ContentType: "text/cloud-config",
+		Filename:    "cloud-config.txt",
+		Content:     cloudConfig,
+	}}, parts...)
+	for i, part := range all {
+		filename := part.Filename
+		if filename == "" {
+			filename = fmt.Sprintf("part-%03d", i)
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.ContentType)
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		content := []byte(part.Content)
+		if isASCII(part.Content) {
+			header.Set("Content-Transfer-Encoding", "7bit")
+		} else {
+			// Non-ASCII content is encoded in lines of at most 76
+			// characters, as MIME requires.
+			header.Set("Content-Transfer-Encoding", "base64")
+			enc := base64.StdEncoding.EncodeToString(content)
+			var wrapped bytes.Buffer
+			for len(enc) > 76 {
+				wrapped.WriteString(enc[:76] + "\r\n")
+				enc = enc[76:]
+			}
+			wrapped.WriteString(enc)
+			content = wrapped.Bytes()
+		}
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = pw.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// vendorDataGetAPI serves cloud-init vendor-data, which holds site-wide
+// configuration kept apart from the user-data of nodes.  Global, role, and
+// node vendor-data are merged in that order, so that the more specific
+// data wins.
+func vendorDataGetAPI(w http.ResponseWriter, r *http.Request) {
+	remoteaddr := findRemoteAddr(r)
+
+	node := resolveCloudInitNode(remoteaddr)
+	xname, bootdata, roleData := node.xname, node.bootdata, node.roleData
+	globaldata, _ := LookupGlobalData()
+
+	log.Printf("GET /vendor-data, xname: %s ip: %s", xname, remoteaddr)
+	mergedData := make(map[string]interface{})
+	for _, data := range []bssTypes.CloudDataType{
+		globaldata.CloudInit.VendorData,
+		roleData.VendorData,
+		bootdata.CloudInit.VendorData,
+	} {
+		if len(data) > 0 {
+			mergedData = mergeMaps(mergedData, data)
+		}
+	}
+
+	databytes, err := yaml.Marshal(mergedData)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Invalid YAML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "#cloud-config\n%s", string(databytes))
+
+	// Record the fact this was asked for.
+	updateEndpointAccessed(xname, bssTypes.EndpointTypeVendorData, remoteaddr, node.dflt)
+}
+
 func endpointHistoryGetAPI(w http.ResponseWriter, r *http.Request) {
 	debugf("endpointHistoryGetAPI(): Received request %v\n", r.URL)
 
@@ -287,21 +426,35 @@ func endpointHistoryGetAPI(w http.ResponseWriter, r *http.Request) {
 	name := strings.Join(r.Form["name"], "")
 	endpoint := strings.Join(r.Form["endpoint"], "")
 
-	var lastAccessTypeStruct bssTypes.EndpointType
-
-	if endpoint != "" {
-		lastAccessTypeStruct = bssTypes.EndpointType(endpoint)
+	filter := postgres.EndpointAccessFilter{
+		Name:     name,
+		Endpoint: bssTypes.EndpointType(endpoint),
+	}
+	var err error
+	for _, p := range []struct {
+		name string
+		val  *int64
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if *p.val, err = getIntParam(r, p.name, 0); err != nil || *p.val < 0 {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Bad Request: %s must be a Unix time", p.name))
+			return
+		}
 	}
-
-	var (
-		accesses []bssTypes.EndpointAccess
-		err      error
-	)
-	if useSQL {
-		accesses, err = bssdb.SearchEndpointAccesses(name, bssTypes.EndpointType(endpoint))
-	} else {
-		accesses, err = SearchEndpointAccessed(name, lastAccessTypeStruct)
+	limit, err := getIntParam(r, "limit", 0)
+	if err != nil || limit < 0 {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Bad Request: limit must be a positive integer")
+		return
+	}
+	filter.Limit = int(limit)
+	if lastOnly := strings.Join(r.Form["last_only"], ""); lastOnly != "" {
+		if filter.LastOnly, err = strconv.ParseBool(lastOnly); err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Bad Request: last_only must be true or false")
+			return
+		}
 	}
+
+	accesses, err := searchEndpointHistory(filter)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to search for name=%q, endpoint=%q: %v", name, endpoint, err)
 		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, errMsg)
@@ -341,12 +494,13 @@ func phoneHomePostAPI(w http.ResponseWriter, r *http.Request) {
 	xname, found := FindXnameByIP(remoteaddr)
 	if !found {
 		debugf("CloudInit -> Phone Home called for unknown xname, ip: %s", remoteaddr)
+		updateEndpointAccessed("", bssTypes.EndpointTypePhoneHome, remoteaddr, true)
 		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
 			fmt.Sprintf("XName not found for IP"))
 		return
 	}
 	hosts = append(hosts, xname)
-	bootdata, _ := LookupByName(xname)
+	bootdata, comp := LookupByName(xname)
 
 	bootdata.CloudInit.PhoneHome = args
 	bp.Hosts = hosts
@@ -360,6 +514,16 @@ func phoneHomePostAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("POST /phone-home, xname: %s ip: %s", xname, remoteaddr)
+	updateEndpointAccessed(xname, bssTypes.EndpointTypePhoneHome, remoteaddr, false)
+	recordBootEvent(xname, bootEventPhoneHome, 0)
+	publishEvent(eventTypePhoneHome, []string{xname}, []string{comp.Role}, bssTypes.PhoneHomeEvent{
+		Time:       time.Now().Unix(),
+		Xname:      xname,
+		IP:         remoteaddr,
+		Hostname:   args.Hostname,
+		FQDN:       args.FQDN,
+		InstanceID: args.InstanceID,
+	})
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
 	out, _ := json.Marshal(bp)
@@ -0,0 +1,307 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	"github.com/OpenCHAMI/smd/v2/pkg/sm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// useInventory replaces the inventory with state for the duration of the
+// test.
+func useInventory(t *testing.T, state *SMData) {
+	smMutex.Lock()
+	saved, savedTime := smData, smTimeStamp
+	setState(state)
+	smTimeStamp = time.Now().Unix()
+	smMutex.Unlock()
+	t.Cleanup(func() {
+		smMutex.Lock()
+		setState(saved)
+		smTimeStamp = savedTime
+		smMutex.Unlock()
+	})
+}
+
+func TestVendorData(t *testing.T) {
+	const xname, subrole, ip = "x9100c0s0b0n0", "vendor-test", "10.91.0.1"
+	useInventory(t, &SMData{
+		Components: []SMComponent{{Component: base.Component{ID: xname, Type: "Node", Role: "Compute", SubRole: subrole}}},
+		IPAddrs:    map[string]sm.CompEthInterfaceV2{ip: {CompID: xname}},
+	})
+
+	configs := []bssTypes.BootParams{
+		{Hosts: []string{GlobalTag}, CloudInit: bssTypes.CloudInit{VendorData: bssTypes.CloudDataType{
+			"timezone": "UTC",
+			"ntp":      map[string]interface{}{"servers": []interface{}{"ntp.example.com"}},
+		}}},
+		{Hosts: []string{subrole}, CloudInit: bssTypes.CloudInit{VendorData: bssTypes.CloudDataType{
+			"ntp":      map[string]interface{}{"enabled": true},
+			"packages": []interface{}{"role"},
+		}}},
+		{Hosts: []string{xname}, CloudInit: bssTypes.CloudInit{
+			UserData:   bssTypes.CloudDataType{"packages": []interface{}{"user"}},
+			VendorData: bssTypes.CloudDataType{"packages": []interface{}{"node"}},
+		}},
+	}
+	for _, bp := range configs {
+		if err, _ := Store(bp); err != nil {
+			t.Fatalf("Unable to store %v: %v", bp.Hosts, err)
+		}
+		defer Remove(bp)
+	}
+
+	get := func() map[string]interface{} {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/vendor-data", nil)
+		req.RemoteAddr = ip + ":40000"
+		vendorDataGet(rr, req)
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.HasPrefix(body, "#cloud-config\n") {
+			t.Fatalf("GET /vendor-data returned %d: %s", rr.Code, body)
+		}
+		var data map[string]interface{}
+		if err := yaml.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+			t.Fatalf("Invalid vendor-data %s: %v", body, err)
+		}
+		return data
+	}
+	data := get()
+	ntp, _ := data["ntp"].(map[interface{}]interface{})
+	if data["timezone"] != "UTC" || ntp["enabled"] != true || ntp["servers"] == nil {
+		t.Errorf("Global and role vendor-data were not merged: %v", data)
+	}
+	if pkgs, _ := data["packages"].([]interface{}); len(pkgs) != 1 || pkgs[0] != "node" {
+		t.Errorf("Node vendor-data did not override the role: %v", data)
+	}
+
+	// Vendor-data is patched like user-data.
+	patch := bssTypes.BootParams{Hosts: []string{xname}, CloudInit: bssTypes.CloudInit{
+		VendorData: bssTypes.CloudDataType{"timezone": "Europe/Oslo"},
+	}}
+	if err := Update(patch); err != nil {
+		t.Fatalf("Unable to patch vendor-data: %v", err)
+	}
+	if data = get(); data["timezone"] != "Europe/Oslo" || data["packages"] == nil {
+		t.Errorf("Patched vendor-data not served: %v", data)
+	}
+
+	ea, err := getEndpointAccess(xname, bssTypes.EndpointTypeVendorData)
+	if err != nil || ea.ClientIP != ip {
+		t.Errorf("Vendor-data access not recorded: %+v, %v", ea, err)
+	}
+}
+
+func TestVendorDataDefault(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/vendor-data", nil)
+	req.RemoteAddr = "192.0.2.99:40000"
+	vendorDataGet(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "#cloud-config\n{}\n" {
+		t.Errorf("GET /vendor-data from an unknown node returned %d: %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	vendorDataGet(rr, httptest.NewRequest(http.MethodPost, "/vendor-data", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /vendor-data returned %d", rr.Code)
+	}
+}
+
+func TestCloudInitRoleData(t *testing.T) {
+	const xname, ip = "x9101c0s0b0n0", "10.91.0.2"
+	useInventory(t, &SMData{
+		Components: []SMComponent{{Component: base.Component{ID: xname, Type: "Node", Role: "Compute"}}},
+		IPAddrs:    map[string]sm.CompEthInterfaceV2{ip: {CompID: xname}},
+	})
+
+	role := bssTypes.BootParams{Hosts: []string{"role-data-test"}, CloudInit: bssTypes.CloudInit{
+		MetaData:   bssTypes.CloudDataType{"role-meta": "yes"},
+		UserData:   bssTypes.CloudDataType{"role-user": "yes"},
+		VendorData: bssTypes.CloudDataType{"role-vendor": "yes"},
+	}}
+	node := bssTypes.BootParams{Hosts: []string{xname}, CloudInit: bssTypes.CloudInit{
+		MetaData: bssTypes.CloudDataType{"shasta-role": "role-data-test"},
+	}}
+	for _, bp := range []bssTypes.BootParams{role, node} {
+		if err, _ := Store(bp); err != nil {
+			t.Fatalf("Unable to store %v: %v", bp.Hosts, err)
+		}
+		defer Remove(bp)
+	}
+
+	get := func(handler http.HandlerFunc, path string) string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":40000"
+		handler(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s returned %d: %s", path, rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+	for _, c := range []struct {
+		handler    http.HandlerFunc
+		path, want string
+	}{
+		{metaDataGetAPI, "/meta-data", `"role-meta":"yes"`},
+		{userDataGetAPI, "/user-data", "role-user: \"yes\""},
+		{vendorDataGetAPI, "/vendor-data", "role-vendor: \"yes\""},
+	} {
+		if body := get(c.handler, c.path); !strings.Contains(body, c.want) {
+			t.Errorf("GET %s did not include the role data: %s", c.path, body)
+		}
+	}
+
+	// A shasta-role that is not a string is ignored rather than fatal.
+	node.CloudInit.MetaData["shasta-role"] = 7
+	if err := Update(node); err != nil {
+		t.Fatalf("Unable to update %s: %v", xname, err)
+	}
+	for _, h := range []http.HandlerFunc{metaDataGetAPI, userDataGetAPI, vendorDataGetAPI} {
+		get(h, "/")
+	}
+}
+
+func TestUserDataParts(t *testing.T) {
+	const xname, subrole, ip = "x9300c0s0b0n0", "parts-test", "10.93.1.1"
+	useInventory(t, &SMData{
+		Components: []SMComponent{{Component: base.Component{ID: xname, Type: "Node", Role: "Compute", SubRole: subrole}}},
+		IPAddrs:    map[string]sm.CompEthInterfaceV2{ip: {CompID: xname}},
+	})
+
+	configs := []bssTypes.BootParams{
+		{Hosts: []string{GlobalTag}, CloudInit: bssTypes.CloudInit{UserDataParts: []bssTypes.UserDataPart{
+			{ContentType: "text/x-shellscript", Filename: "setup.sh", Content: "#!/bin/sh\necho global\n"},
+			{ContentType: "text/x-include-url", Content: "#include\nhttps://config.example.com/site.yaml\n"},
+		}}},
+		{Hosts: []string{subrole}, CloudInit: bssTypes.CloudInit{UserDataParts: []bssTypes.UserDataPart{
+			{ContentType: "text/x-shellscript", Filename: "setup.sh", Content: "#!/bin/sh\necho role\n"},
+		}}},
+		{Hosts: []string{xname}, CloudInit: bssTypes.CloudInit{
+			UserData: bssTypes.CloudDataType{"packages": []interface{}{"vim"}},
+			UserDataParts: []bssTypes.UserDataPart{
+				{ContentType: "text/plain; charset=utf-8", Filename: "motd", Content: "Välkommen\n"},
+			},
+		}},
+	}
+	for _, bp := range configs {
+		if err, _ := Store(bp); err != nil {
+			t.Fatalf("Unable to store %v: %v", bp.Hosts, err)
+		}
+		defer Remove(bp)
+	}
+
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/user-data", nil)
+		req.RemoteAddr = ip + ":40000"
+		userDataGet(rr, req)
+		return rr
+	}
+	rr := get()
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "multipart/mixed") {
+		t.Fatalf("GET /user-data returned %s: %s", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+
+	// cloud-init reads the user-data as a MIME message.
+	msg, err := mail.ReadMessage(bytes.NewReader(rr.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("User-data is not a MIME message: %v\n%s", err, rr.Body.String())
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" || msg.Header.Get("MIME-Version") != "1.0" {
+		t.Fatalf("Unexpected user-data headers %v: %v", msg.Header, err)
+	}
+	expected := []struct{ contentType, filename, content string }{
+		{"text/cloud-config", "cloud-config.txt", "#cloud-config\n"},
+		{"text/x-shellscript", "setup.sh", "#!/bin/sh\necho role\n"},
+		{"text/x-include-url", "part-002", "#include\nhttps://config.example.com/site.yaml\n"},
+		{"text/plain; charset=utf-8", "motd", "Välkommen\n"},
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			if i != len(expected) {
+				t.Errorf("Got %d parts, expected %d", i, len(expected))
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("Invalid part %d: %v", i, err)
+		} else if i >= len(expected) {
+			t.Fatalf("Unexpected part %d: %v", i, part.Header)
+		}
+		content, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Transfer-Encoding") == "base64" {
+			content, _ = base64.StdEncoding.DecodeString(string(content))
+		}
+		e := expected[i]
+		if part.Header.Get("Content-Type") != e.contentType || part.FileName() != e.filename ||
+			!strings.HasPrefix(string(content), e.content) {
+			t.Errorf("Part %d is %v: %q, expected %+v", i, part.Header, content, e)
+		}
+		if i == 0 && !strings.Contains(string(content), "vim") {
+			t.Errorf("Cloud-config part is missing the node's user-data: %q", content)
+		}
+	}
+
+	// Without parts, the cloud-config is sent on its own as before.
+	for _, bp := range configs {
+		bp.CloudInit = bssTypes.CloudInit{UserDataParts: []bssTypes.UserDataPart{}}
+		if err := Update(bp); err != nil {
+			t.Fatalf("Unable to remove the parts of %v: %v", bp.Hosts, err)
+		}
+	}
+	if rr = get(); rr.Header().Get("Content-Type") != "text/yaml" || !strings.HasPrefix(rr.Body.String(), "#cloud-config\n") {
+		t.Errorf("GET /user-data without parts returned %s: %s", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+}
+
+func TestUserDataPartsValidation(t *testing.T) {
+	for _, part := range []string{
+		`{"content": "#!/bin/sh"}`,
+		`{"content-type": "multipart/mixed", "content": ""}`,
+		`{"content-type": "text/x-shellscript", "filename": "a\"b", "content": ""}`,
+	} {
+		body := `{"hosts":["x9300c0s1b0n0"],"cloud-init":{"user-data-parts":[` + part + `]}}`
+		if rr := webhookRequest(t, bootParameters, http.MethodPut, "/boot/v1/bootparameters", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("PUT with user-data part %s returned %d", part, rr.Code)
+		}
+	}
+}
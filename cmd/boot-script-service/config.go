@@ -0,0 +1,481 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// Configuration file support.
+//
+// Every setting can be given in a YAML file named by BSS_CONFIG_FILE or
+// --config.  Keys are the command line flag names, and every setting has a
+// flag, defined by defineFlags.  Values from the file are
+// overridden by environment variables, which are in turn overridden by flags.
+//
+// On SIGHUP the file is read again and the settings marked as reloadable are
+// applied.  Reloadable settings are read under configMutex.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const maskedValue = "********"
+
+var (
+	configFile  = ""    // YAML configuration file
+	printConfig = false // Print the effective configuration and exit
+
+	// Protects the settings which can be changed by a reload
+	configMutex sync.RWMutex
+
+	// Settings given by an environment variable or flag, which the
+	// configuration file must not override on reload
+	configOverrides = map[string]string{}
+)
+
+// configSetting describes one setting.  key is also the name of its command
+// line flag and env that of its environment variable.  ptr points to the
+// global holding its value and determines the type accepted in the
+// configuration file.
+type configSetting struct {
+	key        string
+	env        string
+	ptr        interface{}
+	secret     bool
+	reloadable bool
+}
+
+var configSettings = []configSetting{
+	{key: "service-name", env: "BSS_SERVICE_NAME", ptr: &serviceName},
+	{key: "http-listen", env: "BSS_HTTP_LISTEN", ptr: &httpListen},
+	{key: "http-plain-listen", env: "BSS_HTTP_PLAIN_LISTEN", ptr: &httpPlainListen},
+	{key: "tls-cert", env: "BSS_TLS_CERT_FILE", ptr: &tlsCertFile},
+	{key: "tls-key", env: "BSS_TLS_KEY_FILE", ptr: &tlsKeyFile},
+	{key: "tls-client-ca", env: "BSS_TLS_CLIENT_CA_FILE", ptr: &tlsClientCAFile},
+	{key: "tracing-exporter", env: "BSS_TRACING_EXPORTER", ptr: &tracingExporter},
+	{key: "tracing-file", env: "BSS_TRACING_FILE", ptr: &tracingFile},
+	{key: "ready-checks", env: "BSS_READY_CHECKS", ptr: &readyChecks},
+	{key: "shutdown-delay", env: "BSS_SHUTDOWN_DELAY", ptr: &shutdownDelay},
+	{key: "shutdown-timeout", env: "BSS_SHUTDOWN_TIMEOUT", ptr: &shutdownTimeout},
+	{key: "bootscript-max-concurrent", env: "BSS_BOOTSCRIPT_MAX_CONCURRENT", ptr: &bootscriptMaxConcurrent},
+	{key: "bootscript-role-limits", env: "BSS_BOOTSCRIPT_ROLE_LIMITS", ptr: &bootscriptRoleLimits},
+	{key: "throttle-delay", env: "BSS_THROTTLE_DELAY", ptr: &throttleDelay, reloadable: true},
+	{key: "bootscript-cache-ttl", env: "BSS_BOOTSCRIPT_CACHE_TTL", ptr: &bootscriptCacheTTL, reloadable: true},
+	{key: "bootscript-cache-size", env: "BSS_BOOTSCRIPT_CACHE_SIZE", ptr: &bootscriptCacheSize},
+	{key: "inventory-source", env: "BSS_INVENTORY_SOURCE", ptr: &inventorySource},
+	{key: "inventory-file", env: "BSS_INVENTORY_FILE", ptr: &inventoryFile},
+	{key: "discovery", env: "BSS_DISCOVERY", ptr: &discoveryEnabled},
+	{key: "boot-sessions", env: "BSS_BOOT_SESSIONS", ptr: &bootSessionsEnabled},
+	{key: "boot-deadline", env: "BSS_BOOT_DEADLINE", ptr: &bootDeadline, reloadable: true},
+	{key: "boot-retry-limit", env: "BSS_BOOT_RETRY_LIMIT", ptr: &bootRetryLimit, reloadable: true},
+	{key: "rescue-retries", env: "BSS_RESCUE_RETRIES", ptr: &rescueRetries, reloadable: true},
+	{key: "rescue-node-retries", env: "BSS_RESCUE_NODE_RETRIES", ptr: &rescueNodeRetries, reloadable: true},
+	{key: "rescue-role-retries", env: "BSS_RESCUE_ROLE_RETRIES", ptr: &rescueRoleRetries, reloadable: true},
+	{key: "endpoint-history", env: "BSS_ENDPOINT_HISTORY", ptr: &endpointHistoryMode},
+	{key: "endpoint-history-retention", env: "BSS_ENDPOINT_HISTORY_RETENTION", ptr: &endpointHistoryRetention, reloadable: true},
+	{key: "webhooks", env: "BSS_WEBHOOKS", ptr: &webhooksEnabled},
+	{key: "webhook-queue", env: "BSS_WEBHOOK_QUEUE", ptr: &webhookQueue},
+	{key: "webhook-max-attempts", env: "BSS_WEBHOOK_MAX_ATTEMPTS", ptr: &webhookMaxAttempts, reloadable: true},
+	{key: "event-log-size", env: "BSS_EVENT_LOG_SIZE", ptr: &eventLogSize},
+	{key: "hsm", env: "HSM_URL", ptr: &hsmBase},
+	{key: "nfd", env: "NFD_URL", ptr: &nfdBase},
+	{key: "cloud-init-address", env: "BSS_ADVERTISE_ADDRESS", ptr: &advertiseAddress},
+	{key: "retry-delay", env: "BSS_RETRY_DELAY", ptr: &retryDelay, reloadable: true},
+	{key: "hsm-retrieval-delay", env: "BSS_HSM_RETRIEVAL_DELAY", ptr: &hsmRetrievalDelay, reloadable: true},
+	{key: "hsm-resync-interval", env: "BSS_HSM_RESYNC_INTERVAL", ptr: &hsmResyncInterval, reloadable: true},
+	{key: "blocked-roles", env: "BSS_BLOCKED_ROLES", ptr: &blockedRoles, reloadable: true},
+	{key: "spire-url", env: "SPIRE_TOKEN_URL", ptr: &spireServiceURL},
+	{key: "endpoint-host", env: "BSS_ENDPOINT_HOST", ptr: &notifierURL},
+	{key: "ipxe-server", env: "BSS_IPXE_SERVER", ptr: &ipxeServer},
+	{key: "chain-proto", env: "BSS_CHAIN_PROTO", ptr: &chainProto},
+	{key: "gw-uri", env: "BSS_GW_URI", ptr: &gwURI},
+	{key: "auth-retry-count", env: "BSS_AUTH_RETRY_COUNT", ptr: &authRetryCount},
+	{key: "auth-retry-wait", env: "BSS_AUTH_RETRY_WAIT", ptr: &authRetryWait},
+	{key: "jwks-url", env: "BSS_JWKS_URL", ptr: &jwksURL},
+	{key: "auth-scopes", env: "BSS_AUTH_SCOPES_ENABLED", ptr: &authScopesEnabled},
+	{key: "auth-protect-reads", env: "BSS_AUTH_PROTECT_READS", ptr: &authProtectReads},
+	{key: "auth-scope-claim", env: "BSS_AUTH_SCOPE_CLAIM", ptr: &authScopeClaim},
+	{key: "auth-read-scope", env: "BSS_AUTH_READ_SCOPE", ptr: &authReadScope},
+	{key: "auth-write-scope", env: "BSS_AUTH_WRITE_SCOPE", ptr: &authWriteScope},
+	{key: "auth-admin-scope", env: "BSS_AUTH_ADMIN_SCOPE", ptr: &authAdminScope},
+	{key: "api-keys-file", env: "BSS_API_KEYS_FILE", ptr: &apiKeysFile},
+	{key: "client-cert-roles-file", env: "BSS_CLIENT_CERT_ROLES_FILE", ptr: &clientCertRolesFile},
+	{key: "oauth2-admin-base-url", env: "BSS_OAUTH2_ADMIN_BASE_URL", ptr: &oauth2AdminBaseURL},
+	{key: "oauth2-public-base-url", env: "BSS_OAUTH2_PUBLIC_BASE_URL", ptr: &oauth2PublicBaseURL},
+	{key: "bootscript-notify-url", env: "BSS_BOOTSCRIPT_NOTIFY_URL", ptr: &bootscriptNotifyURL, reloadable: true},
+	{key: "bootscript-notify-urls", env: "BSS_BOOTSCRIPT_NOTIFY_URLS", ptr: &bootscriptNotifyURLs, reloadable: true},
+	{key: "bootscript-notify-secret", env: "BSS_BOOTSCRIPT_NOTIFY_SECRET", ptr: &bootscriptNotifySecret, secret: true, reloadable: true},
+	{key: "bootscript-notify-queue", env: "BSS_BOOTSCRIPT_NOTIFY_QUEUE", ptr: &bootscriptNotifyQueue},
+	{key: "bootscript-notify-max-attempts", env: "BSS_BOOTSCRIPT_NOTIFY_MAX_ATTEMPTS", ptr: &bootscriptNotifyMaxAttempts, reloadable: true},
+	{key: "datastore", env: "DATASTORE_BASE", ptr: &datastoreBase},
+	{key: "etcd-host", env: "ETCD_HOST", ptr: &kvHost},
+	{key: "etcd-port", env: "ETCD_PORT", ptr: &kvPort},
+	{key: "etcd-retry-count", env: "ETCD_RETRY_COUNT", ptr: &kvRetryCount},
+	{key: "etcd-retry-wait", env: "ETCD_RETRY_WAIT", ptr: &kvRetryWait},
+	{key: "insecure", env: "BSS_INSECURE", ptr: &insecure},
+	{key: "debug", env: "BSS_DEBUG", ptr: &debugFlag, reloadable: true},
+	{key: "postgres", env: "BSS_USESQL", ptr: &useSQL},
+	{key: "postgres-host", env: "BSS_DBHOST", ptr: &sqlHost},
+	{key: "postgres-port", env: "BSS_DBPORT", ptr: &sqlPort},
+	{key: "postgres-dbname", env: "BSS_DBNAME", ptr: &bssdbName},
+	{key: "postgres-opts", env: "BSS_DBOPTS", ptr: &sqlDbOpts},
+	{key: "postgres-username", env: "BSS_DBUSER", ptr: &sqlUser},
+	{key: "postgres-password", env: "BSS_DBPASS", ptr: &sqlPass, secret: true},
+	{key: "postgres-retry-count", env: "BSS_SQL_RETRY_COUNT", ptr: &sqlRetryCount},
+	{key: "postgres-retry-wait", env: "BSS_SQL_RETRY_WAIT", ptr: &sqlRetryWait},
+}
+
+func findConfigSetting(key string) *configSetting {
+	for i := range configSettings {
+		if configSettings[i].key == key {
+			return &configSettings[i]
+		}
+	}
+	return nil
+}
+
+// setConfigValue stores a value decoded from YAML in the variable pointed to
+// by ptr, rejecting values of the wrong type.
+func setConfigValue(ptr interface{}, v interface{}) error {
+	switch p := ptr.(type) {
+	case *string:
+		switch s := v.(type) {
+		case string:
+			*p = s
+		case int, float64:
+			*p = fmt.Sprint(s)
+		default:
+			return fmt.Errorf("expected a string, got %T", v)
+		}
+	case *bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected true or false, got %v", v)
+		}
+		*p = b
+	case *uint:
+		n, ok := v.(int)
+		if !ok || n < 0 {
+			return fmt.Errorf("expected a non-negative integer, got %v", v)
+		}
+		*p = uint(n)
+	case *uint64:
+		n, ok := v.(int)
+		if !ok || n < 0 {
+			return fmt.Errorf("expected a non-negative integer, got %v", v)
+		}
+		*p = uint64(n)
+	case *[]string:
+		switch l := v.(type) {
+		case string:
+			*p = strings.Split(l, ",")
+		case []interface{}:
+			list := make([]string, 0, len(l))
+			for _, e := range l {
+				s, ok := e.(string)
+				if !ok {
+					return fmt.Errorf("expected a list of strings, got element %v", e)
+				}
+				list = append(list, s)
+			}
+			*p = list
+		default:
+			return fmt.Errorf("expected a list of strings, got %v", v)
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", ptr)
+	}
+	return nil
+}
+
+// readConfigFile reads and decodes the configuration file, rejecting unknown
+// keys and values of the wrong type.  The values are returned, not applied.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read config file: %v", err)
+	}
+	raw := map[string]interface{}{}
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("Could not parse config file %s: %v", path, err)
+	}
+	var errList []string
+	values := make(map[string]interface{}, len(raw))
+	for key, v := range raw {
+		s := findConfigSetting(key)
+		if s == nil {
+			errList = append(errList, fmt.Sprintf("unknown setting %q", key))
+			continue
+		}
+		tmp := reflect.New(reflect.TypeOf(s.ptr).Elem()).Interface()
+		if err = setConfigValue(tmp, v); err != nil {
+			errList = append(errList, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		values[key] = reflect.ValueOf(tmp).Elem().Interface()
+	}
+	if len(errList) > 0 {
+		sort.Strings(errList)
+		return nil, fmt.Errorf("Invalid config file %s: %s", path, strings.Join(errList, "; "))
+	}
+	return values, nil
+}
+
+// loadConfigFile applies the configuration file.  It runs before the
+// environment and command line are parsed so that they take precedence.
+func loadConfigFile(path string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for key, v := range values {
+		reflect.ValueOf(findConfigSetting(key).ptr).Elem().Set(reflect.ValueOf(v))
+	}
+	log.Printf("Loaded %d setting(s) from %s", len(values), path)
+	return nil
+}
+
+// configFileFromArgs finds the configuration file before the command line is
+// parsed, since flag.Parse would apply the flag defaults over its values.
+func configFileFromArgs(args []string) string {
+	path := os.Getenv("BSS_CONFIG_FILE")
+	for i, a := range args {
+		a = strings.TrimLeft(a, "-")
+		if a == "config" && i+1 < len(args) {
+			path = args[i+1]
+		} else if strings.HasPrefix(a, "config=") {
+			path = strings.TrimPrefix(a, "config=")
+		}
+	}
+	return path
+}
+
+// recordConfigOverrides remembers which settings were given by environment
+// variables or flags.
+func recordConfigOverrides() {
+	for _, s := range configSettings {
+		if os.Getenv(s.env) != "" {
+			configOverrides[s.key] = s.env
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if findConfigSetting(f.Name) != nil {
+			configOverrides[f.Name] = "--" + f.Name
+		}
+	})
+}
+
+// parsedConfig holds settings that validation parses from their string form,
+// for applyParsedConfig to put into effect.
+type parsedConfig struct {
+	bootscriptRoleLimits map[string]uint
+	rescueNodeRetries    map[string]uint
+	rescueRoleRetries    map[string]uint
+}
+
+// validateConfig checks the combined configuration for values that cannot
+// work.
+func validateConfig() (parsedConfig, error) {
+	var parsed parsedConfig
+	var err error
+	var errList []string
+	check := func(ok bool, format string, v ...interface{}) {
+		if !ok {
+			errList = append(errList, fmt.Sprintf(format, v...))
+		}
+	}
+	checkURL := func(key, u string, optional bool) {
+		if u == "" && optional {
+			return
+		}
+		p, err := url.Parse(u)
+		check(err == nil && p.Scheme != "", "%s: %q is not a valid URL", key, u)
+	}
+
+	check(httpListen != "", "http-listen must not be empty")
+	check(chainProto == "http" || chainProto == "https", "chain-proto must be http or https, not %q", chainProto)
+	check(sqlPort > 0 && sqlPort < 65536, "postgres-port %d is out of range", sqlPort)
+	check(tlsCertFile == "" || tlsKeyFile != "", "tls-key is required with tls-cert")
+	check(tlsCertFile != "" || tlsKeyFile == "", "tls-cert is required with tls-key")
+	check(!authProtectReads || jwksURL != "" || apiKeysFile != "" || clientCertRolesFile != "",
+		"auth-protect-reads requires jwks-url, api-keys-file, or client-cert-roles-file")
+	switch strings.ToLower(tracingExporter) {
+	case "", tracingExporterNone, tracingExporterOTLP, tracingExporterStdout, tracingExporterFile:
+	default:
+		check(false, "tracing-exporter must be none, otlp, stdout, or file, not %q", tracingExporter)
+	}
+	if err := parseReadyChecks(); err != nil {
+		check(false, "ready-checks: %v", err)
+	}
+	if parsed.bootscriptRoleLimits, err = parseRoleLimits(bootscriptRoleLimits); err != nil {
+		check(false, "bootscript-role-limits: %v", err)
+	}
+	if parsed.rescueNodeRetries, err = parseRoleLimits(rescueNodeRetries); err != nil {
+		check(false, "rescue-node-retries: %v", err)
+	}
+	if parsed.rescueRoleRetries, err = parseRoleLimits(rescueRoleRetries); err != nil {
+		check(false, "rescue-role-retries: %v", err)
+	}
+	switch strings.ToLower(endpointHistoryMode) {
+	case endpointHistoryModeLast, endpointHistoryModeFull:
+	default:
+		check(false, "endpoint-history must be last or full, not %q", endpointHistoryMode)
+	}
+	check(webhookQueue == "" || bootscriptNotifyQueue == "" || filepath.Clean(webhookQueue) != filepath.Clean(bootscriptNotifyQueue),
+		"webhook-queue and bootscript-notify-queue must be different directories")
+	switch strings.ToLower(inventorySource) {
+	case inventorySourceHSM:
+	case inventorySourceJSON, inventorySourceHosts:
+		check(inventoryFile != "", "inventory-file is required with inventory-source %s", inventorySource)
+	case inventorySourcePostgres:
+		check(useSQL, "inventory-source postgres requires postgres")
+	default:
+		check(false, "inventory-source must be hsm, json, hosts, or postgres, not %q", inventorySource)
+	}
+	checkURL("hsm", hsmBase, false)
+	checkURL("nfd", nfdBase, false)
+	checkURL("jwks-url", jwksURL, true)
+	checkURL("spire-url", spireServiceURL, false)
+	checkURL("bootscript-notify-url", bootscriptNotifyURL, true)
+	for _, u := range bootscriptNotifyURLs {
+		checkURL("bootscript-notify-urls", strings.TrimSpace(u), true)
+	}
+
+	if len(errList) > 0 {
+		return parsed, fmt.Errorf("Invalid configuration: %s", strings.Join(errList, "; "))
+	}
+	return parsed, nil
+}
+
+// applyParsedConfig puts the settings parsed by validateConfig into effect.
+func applyParsedConfig(parsed parsedConfig) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	bootscriptAdmission = newAdmission(bootscriptMaxConcurrent, parsed.bootscriptRoleLimits)
+	rescueNodeLimits = parsed.rescueNodeRetries
+	rescueRoleLimits = parsed.rescueRoleRetries
+}
+
+// printEffectiveConfig writes the configuration in the config file format,
+// with secrets masked.
+func printEffectiveConfig() {
+	var out yaml.MapSlice
+	for _, s := range configSettings {
+		v := reflect.ValueOf(s.ptr).Elem().Interface()
+		if s.secret && v != "" {
+			v = maskedValue
+		}
+		out = append(out, yaml.MapItem{Key: s.key, Value: v})
+	}
+	data, _ := yaml.Marshal(out)
+	fmt.Print(string(data))
+}
+
+// reloadConfig applies the reloadable settings from the configuration file.
+// Changes to other settings are reported but need a restart.
+func reloadConfig() error {
+	values, err := readConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	if u, ok := values["bootscript-notify-url"].(string); ok && u != "" {
+		if p, err := url.Parse(u); err != nil || p.Scheme == "" {
+			return fmt.Errorf("bootscript-notify-url: %q is not a valid URL", u)
+		}
+	}
+	if urls, ok := values["bootscript-notify-urls"].([]string); ok {
+		for _, u := range urls {
+			if p, err := url.Parse(strings.TrimSpace(u)); u != "" && (err != nil || p.Scheme == "") {
+				return fmt.Errorf("bootscript-notify-urls: %q is not a valid URL", u)
+			}
+		}
+	}
+	nodeOverrides, nodeChanged := values["rescue-node-retries"].(string)
+	var nodeLimits map[string]uint
+	if nodeChanged {
+		if nodeLimits, err = parseRoleLimits(nodeOverrides); err != nil {
+			return fmt.Errorf("rescue-node-retries: %v", err)
+		}
+	}
+	rescueOverrides, rescueChanged := values["rescue-role-retries"].(string)
+	var rescueLimits map[string]uint
+	if rescueChanged {
+		if rescueLimits, err = parseRoleLimits(rescueOverrides); err != nil {
+			return fmt.Errorf("rescue-role-retries: %v", err)
+		}
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	for _, s := range configSettings {
+		v, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		cur := reflect.ValueOf(s.ptr).Elem()
+		if reflect.DeepEqual(cur.Interface(), v) {
+			continue
+		}
+		if src, overridden := configOverrides[s.key]; overridden {
+			log.Printf("Config reload: %s is set by %s, ignoring the config file", s.key, src)
+		} else if !s.reloadable {
+			log.Printf("Config reload: changing %s requires a restart", s.key)
+		} else {
+			cur.Set(reflect.ValueOf(v))
+			log.Printf("Config reload: %s changed", s.key)
+		}
+	}
+	if nodeChanged && rescueNodeRetries == nodeOverrides {
+		rescueNodeLimits = nodeLimits
+	}
+	if rescueChanged && rescueRoleRetries == rescueOverrides {
+		rescueRoleLimits = rescueLimits
+	}
+	return nil
+}
+
+// watchConfigReload reloads the configuration file on SIGHUP.
+func watchConfigReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Printf("Received SIGHUP, reloading %s", configFile)
+			if err := reloadConfig(); err != nil {
+				log.Printf("Config reload failed, keeping current settings: %v", err)
+			}
+		}
+	}()
+}
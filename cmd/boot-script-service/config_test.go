@@ -0,0 +1,207 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "bss.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+	values, err := readConfigFile(writeConfig(t, `
+http-listen: ":8080"
+retry-delay: 15
+auth-retry-count: 3
+debug: true
+etcd-port: 2379
+blocked-roles: [Management, Storage]
+`))
+	if err != nil {
+		t.Fatalf("readConfigFile returned error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"http-listen":      ":8080",
+		"retry-delay":      uint(15),
+		"auth-retry-count": uint64(3),
+		"debug":            true,
+		"etcd-port":        "2379",
+		"blocked-roles":    []string{"Management", "Storage"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("readConfigFile returned %v, expected %v", values, expected)
+	}
+
+	bad := []string{
+		"unknown-setting: 1\n",
+		"retry-delay: -1\n",
+		"retry-delay: soon\n",
+		"debug: maybe\n",
+		"blocked-roles: [1, 2]\n",
+		"http-listen: [a]\n",
+	}
+	for _, content := range bad {
+		if _, err = readConfigFile(writeConfig(t, content)); err == nil {
+			t.Errorf("readConfigFile accepted %q", content)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	savedFile, savedDelay, savedRoles, savedListen := configFile, retryDelay, blockedRoles, httpListen
+	savedRescue, savedRescueLimits := rescueRoleRetries, rescueRoleLimits
+	savedRescueNodes, savedRescueNodeLimits := rescueNodeRetries, rescueNodeLimits
+	defer func() {
+		configFile, retryDelay, blockedRoles, httpListen = savedFile, savedDelay, savedRoles, savedListen
+		rescueRoleRetries, rescueRoleLimits = savedRescue, savedRescueLimits
+		rescueNodeRetries, rescueNodeLimits = savedRescueNodes, savedRescueNodeLimits
+		delete(configOverrides, "hsm-retrieval-delay")
+	}()
+
+	hsmDelay := hsmRetrievalDelay
+	configOverrides["hsm-retrieval-delay"] = "BSS_HSM_RETRIEVAL_DELAY"
+	configFile = writeConfig(t, `
+retry-delay: 42
+blocked-roles: [Management]
+hsm-retrieval-delay: 99
+http-listen: ":1"
+rescue-role-retries: Compute=4
+rescue-node-retries: x0c0s1b0n0=6
+`)
+	if err := reloadConfig(); err != nil {
+		t.Fatalf("reloadConfig returned error: %v", err)
+	}
+	if retryDelay != 42 {
+		t.Errorf("retry-delay is %d after reload, expected 42", retryDelay)
+	}
+	if !reflect.DeepEqual(blockedRoles, []string{"Management"}) {
+		t.Errorf("blocked-roles is %v after reload, expected [Management]", blockedRoles)
+	}
+	if hsmRetrievalDelay != hsmDelay {
+		t.Errorf("hsm-retrieval-delay set by the environment was changed by reload")
+	}
+	if httpListen != savedListen {
+		t.Errorf("http-listen was changed by reload")
+	}
+	if limit := rescueRetryLimit(SMComponent{Component: base.Component{Role: "Compute"}}); limit != 4 {
+		t.Errorf("Compute rescue retries are %d after reload, expected 4", limit)
+	}
+	if limit := rescueRetryLimit(SMComponent{Component: base.Component{ID: "x0c0s1b0n0", Role: "Compute"}}); limit != 6 {
+		t.Errorf("x0c0s1b0n0 rescue retries are %d after reload, expected 6", limit)
+	}
+
+	configFile = writeConfig(t, "retry-delay: 7\nbogus: true\n")
+	if err := reloadConfig(); err == nil {
+		t.Errorf("reloadConfig accepted an invalid file")
+	}
+	if retryDelay != 42 {
+		t.Errorf("retry-delay changed by a failed reload")
+	}
+}
+
+func TestConfigSettingFlags(t *testing.T) {
+	fs := flag.NewFlagSet("bss", flag.ContinueOnError)
+	defineFlags(fs)
+	for _, s := range configSettings {
+		f := fs.Lookup(s.key)
+		if f == nil {
+			t.Errorf("Setting %s has no command line flag", s.key)
+		} else if !strings.HasPrefix(f.Usage, "("+s.env+")") {
+			t.Errorf("Usage of --%s does not name %s: %q", s.key, s.env, f.Usage)
+		}
+	}
+
+	savedRoles := blockedRoles
+	defer func() { blockedRoles = savedRoles }()
+	if err := fs.Parse([]string{"--blocked-roles", "Management,Storage"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(blockedRoles, []string{"Management", "Storage"}) {
+		t.Errorf("--blocked-roles set %v", blockedRoles)
+	}
+}
+
+func TestConfigFileFromArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--config", "/etc/bss.yaml"}, "/etc/bss.yaml"},
+		{[]string{"-debug", "-config=/etc/bss.yaml"}, "/etc/bss.yaml"},
+		{[]string{"--debug"}, ""},
+	}
+	for _, tt := range tests {
+		if path := configFileFromArgs(tt.args); path != tt.expected {
+			t.Errorf("configFileFromArgs(%v) returned %q, expected %q", tt.args, path, tt.expected)
+		}
+	}
+}
+
+func TestValidateConfigParsesLimits(t *testing.T) {
+	savedLimits, savedAdmission, savedRescue := bootscriptRoleLimits, bootscriptAdmission, rescueRoleLimits
+	defer func() {
+		bootscriptRoleLimits, bootscriptAdmission, rescueRoleLimits = savedLimits, savedAdmission, savedRescue
+	}()
+
+	bootscriptRoleLimits = "Compute=2"
+	parsed, _ := validateConfig()
+	if bootscriptAdmission != savedAdmission {
+		t.Errorf("validateConfig replaced the boot script admission limits")
+	}
+	if parsed.bootscriptRoleLimits["compute"] != 2 {
+		t.Errorf("validateConfig parsed role limits %v", parsed.bootscriptRoleLimits)
+	}
+	applyParsedConfig(parsed)
+	if _, ok := bootscriptAdmission.roles["compute"]; !ok {
+		t.Errorf("applyParsedConfig did not apply the role limits")
+	}
+}
+
+func TestValidateConfigProtectReads(t *testing.T) {
+	savedProtect, savedJWKS, savedKeys, savedCerts := authProtectReads, jwksURL, apiKeysFile, clientCertRolesFile
+	defer func() {
+		authProtectReads, jwksURL, apiKeysFile, clientCertRolesFile = savedProtect, savedJWKS, savedKeys, savedCerts
+	}()
+
+	authProtectReads, jwksURL, apiKeysFile, clientCertRolesFile = true, "", "", ""
+	if _, err := validateConfig(); err == nil || !strings.Contains(err.Error(), "auth-protect-reads") {
+		t.Errorf("auth-protect-reads without an authentication method was accepted: %v", err)
+	}
+	apiKeysFile = "/etc/bss/api-keys"
+	if _, err := validateConfig(); err != nil && strings.Contains(err.Error(), "auth-protect-reads") {
+		t.Errorf("auth-protect-reads with API keys was rejected: %v", err)
+	}
+}
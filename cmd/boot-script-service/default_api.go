@@ -39,6 +39,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -48,6 +49,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -56,6 +58,7 @@ import (
 	hmetcd "github.com/Cray-HPE/hms-hmetcd"
 	hms_s3 "github.com/Cray-HPE/hms-s3"
 	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -69,9 +72,9 @@ var blockedRoles []string
 // Figure out what server the ipxe boot scripts should reference when chaining
 // to new BSS requests.  This is normally the API gateway.  Allow this to be
 // overridden with the BSS_IPXE_SERVER environment variable.
-var ipxeServer = getEnvVal("BSS_IPXE_SERVER", "api-gw-service-nmn.local")
-var chainProto = getEnvVal("BSS_CHAIN_PROTO", "https")
-var gwURI = getEnvVal("BSS_GW_URI", "/apis/bss")
+var ipxeServer = "api-gw-service-nmn.local"
+var chainProto = "https"
+var gwURI = "/apis/bss"
 
 // Store ptr to S3 client
 var s3Client *hms_s3.S3Client
@@ -90,6 +93,7 @@ type scriptParams struct {
 	nid           string
 	referralToken string
 	mac           string
+	params        string // Static kernel parameters; built from BootData when empty
 }
 
 // Note that we allow an empty string if the env variable is defined as such.
@@ -170,8 +174,13 @@ func checkURL(u string) (string, error) {
 		s3Client.SetBucket(bucket)
 	}
 	if s3Client != nil {
-		return s3Client.GetURL(key, 24*time.Hour)
+		signed, err := s3Client.GetURL(key, 24*time.Hour)
+		if err != nil {
+			s3PresignFailures.Inc()
+		}
+		return signed, err
 	}
+	s3PresignFailures.Inc()
 	return "", err
 }
 
@@ -182,7 +191,9 @@ func BootparametersGetAll(w http.ResponseWriter, r *http.Request) {
 			err   error
 			nodes []string
 		)
+		start := time.Now()
 		results, err = bssdb.GetBootParamsAll()
+		observeSQL("get_all", start, err)
 		if err != nil {
 			log.Printf("Yikes, I couldn't retrieve boot parameters from Postgres: %v\n", err)
 		}
@@ -330,53 +341,46 @@ func BootparametersGet(w http.ResponseWriter, r *http.Request) {
 
 		if len(args.Hosts) > 0 || len(args.Macs) > 0 || len(args.Nids) > 0 {
 
-			nameValues := GetNamesAndValues()
+			// Resolve the requested FQDNs, MACs, and NIDs to the xnames
+			// boot parameters are stored under.
+			names := make(map[string]bool)
+			for _, v := range args.Hosts {
+				for _, c := range FindSMCompsByFQDN(v) {
+					names[c.ID] = true
+				}
+			}
+			for _, v := range args.Macs {
+				for _, c := range FindSMCompsByMAC(v) {
+					names[c.ID] = true
+				}
+			}
+			for _, v := range args.Nids {
+				for _, c := range FindSMCompsByNid(int(v)) {
+					names[c.ID] = true
+				}
+			}
+			sortedNames := make([]string, 0, len(names))
+			for name := range names {
+				sortedNames = append(sortedNames, name)
+			}
+			sort.Strings(sortedNames)
 
 			kernelImages := make(map[string]ImageData)
 			initrdImages := make(map[string]ImageData)
-			for name, value := range nameValues {
-				smc := LookupComponentByName(name)
-				bd, parseErr := ToBootData(value, kernelImages, initrdImages)
-				if parseErr != nil {
-					log.Printf("Failed to parse etcd value for %s: %v\n", name, parseErr)
+			for _, name := range sortedNames {
+				bds, err := lookupHost(name)
+				if err != nil {
+					continue
 				}
-
-				debugf("Found %s: %v | %v\n", name, bd, smc)
+				bd := bdConvertUsingImageCache(bds, kernelImages, initrdImages)
+				debugf("Found %s: %v\n", name, bd)
 				var bp bssTypes.BootParams
-				ok := false
-				for _, v := range args.Hosts {
-					if v == smc.ID || v == smc.Fqdn || v == name {
-						ok = true
-						break
-					}
-				}
-				if !ok {
-				Outer:
-					for _, v := range args.Macs {
-						for _, m := range smc.Mac {
-							if strings.EqualFold(v, m) {
-								ok = true
-								break Outer
-							}
-						}
-					}
-				}
-				if !ok {
-					for _, v := range args.Nids {
-						if nid, err := smc.NID.Int64(); err == nil && int64(v) == nid {
-							ok = true
-							break
-						}
-					}
-				}
-				if ok {
-					bp.Hosts = append(bp.Hosts, name)
-					bp.Params = bd.Params
-					bp.Kernel = bd.Kernel.Path
-					bp.Initrd = bd.Initrd.Path
-					bp.CloudInit = bd.CloudInit
-					results = append(results, bp)
-				}
+				bp.Hosts = append(bp.Hosts, name)
+				bp.Params = bd.Params
+				bp.Kernel = bd.Kernel.Path
+				bp.Initrd = bd.Initrd.Path
+				bp.CloudInit = bd.CloudInit
+				results = append(results, bp)
 			}
 		}
 	}
@@ -451,6 +455,17 @@ func BootparametersPost(w http.ResponseWriter, r *http.Request) {
 			fmt.Sprintf("Bad Request: %s", err))
 		return
 	}
+	// Check that the network-config overrides and user-data parts can be used
+	err = args.CheckNetworkConfig()
+	if err == nil {
+		err = args.CheckUserDataParts()
+	}
+	if err != nil {
+		LogBootParameters(fmt.Sprintf("/bootparameters POST FAILED: %s", err.Error()), args)
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
 	// Check that the xnames are valid
 	err = args.CheckXnames()
 	if err != nil {
@@ -462,8 +477,11 @@ func BootparametersPost(w http.ResponseWriter, r *http.Request) {
 	}
 	// Fields appear to be correct.  Continue with processing.
 	debugf("Received boot parameters: %v\n", args)
+	changes := watchConfigChanges(args, bssTypes.ConfigCreated, bssTypes.ConfigUpdated)
+	defer changes.release()
 	err, referralToken := StoreNew(args)
 	if err == nil {
+		changes.publish()
 		LogBootParameters("/bootparameters POST", args)
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		if referralToken != "" {
@@ -497,9 +515,23 @@ func BootparametersPut(w http.ResponseWriter, r *http.Request) {
 			fmt.Sprintf("Bad Request: %s", err))
 		return
 	}
+	// Check that the network-config overrides and user-data parts can be used
+	err = args.CheckNetworkConfig()
+	if err == nil {
+		err = args.CheckUserDataParts()
+	}
+	if err != nil {
+		LogBootParameters(fmt.Sprintf("/bootparameters PUT FAILED: %s", err.Error()), args)
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
 	debugf("Received boot parameters: %v\n", args)
+	changes := watchConfigChanges(args, bssTypes.ConfigCreated, bssTypes.ConfigUpdated)
+	defer changes.release()
 	err, referralToken := Store(args)
 	if err == nil {
+		changes.publish()
 		LogBootParameters("/bootparameters PUT", args)
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		if referralToken != "" {
@@ -537,13 +569,27 @@ func BootparametersPatch(w http.ResponseWriter, r *http.Request) {
 			fmt.Sprintf("Bad Request: %s", err))
 		return
 	}
+	// Check that the network-config overrides and user-data parts can be used
+	err = args.CheckNetworkConfig()
+	if err == nil {
+		err = args.CheckUserDataParts()
+	}
+	if err != nil {
+		LogBootParameters(fmt.Sprintf("/bootparameters PATCH FAILED: %s", err.Error()), args)
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+			fmt.Sprintf("Bad Request: %s", err))
+		return
+	}
 	debugf("Received boot parameters: %v\n", args)
+	changes := watchConfigChanges(args, bssTypes.ConfigCreated, bssTypes.ConfigUpdated)
+	defer changes.release()
 	err = Update(args)
 	if err != nil {
 		LogBootParameters(fmt.Sprintf("/bootparameters PATCH FAILED: %s", err.Error()), args)
 		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
 			fmt.Sprintf("Not Found: %s", err))
 	} else {
+		changes.publish()
 		LogBootParameters("/bootparameters PATCH", args)
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
@@ -561,6 +607,8 @@ func BootparametersDelete(w http.ResponseWriter, r *http.Request) {
 			fmt.Sprintf("Bad Request: %s", err))
 		return
 	}
+	changes := watchConfigChanges(args, bssTypes.ConfigDeleted)
+	defer changes.release()
 	if err == nil {
 		err = Remove(args)
 	}
@@ -568,6 +616,7 @@ func BootparametersDelete(w http.ResponseWriter, r *http.Request) {
 		LogBootParameters(fmt.Sprintf("/bootparameters DELETE FAILED: %s", err.Error()), args)
 		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
 	} else {
+		changes.publish()
 		LogBootParameters("/bootparameters DELETE", args)
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
@@ -638,18 +687,19 @@ func paramSubstitute(params, pvar string, getVal paramValRetreiver) (string, err
 // BootData and additional parameters provided.  The resultant script is
 // returned as a string.  If an error occurs, a null string is returned along
 // with the error.
-func buildBootScript(bd BootData, sp scriptParams, chain, role, subRole, descr string) (string, error) {
+func buildBootScript(ctx context.Context, bd BootData, sp scriptParams, chain, role, subRole, descr string) (string, error) {
 	debugf("buildBootScript(%v, %v, %v, %v, %v, %v)\n", bd, sp, chain, role, subRole, descr)
 	if bd.Kernel.Path == "" {
 		return "", fmt.Errorf("%s: this host not configured for booting.", descr)
 	}
 
 	script := "#!ipxe\n"
-	params, err := buildParams(bd, sp, role, subRole)
+	params, err := buildParams(ctx, bd, sp, role, subRole)
 	if err != nil {
 		return "", err
 	}
 
+	checkURL := tracedCheckURL(ctx)
 	u := bd.Kernel.Path
 	u, err = checkURL(u)
 	if err == nil {
@@ -665,7 +715,10 @@ func buildBootScript(bd BootData, sp scriptParams, chain, role, subRole, descr s
 		// We could vary the length of the sleep based on retry count or some
 		// other criteria.
 		// For now, just sleep a bit
-		script += fmt.Sprintf("sleep %d\n", retryDelay) + chain + "\n"
+		configMutex.RLock()
+		delay := retryDelay
+		configMutex.RUnlock()
+		script += fmt.Sprintf("sleep %d\n", delay) + chain + "\n"
 	}
 	return script, err
 }
@@ -674,42 +727,28 @@ func buildBootScript(bd BootData, sp scriptParams, chain, role, subRole, descr s
 // BootData and additional parameters provided, accounting for special
 // parameters.  The params are returned as a string.  If an error occurs, an
 // empty string is returned along with the error.
-func buildParams(bd BootData, sp scriptParams, role, subRole string) (string, error) {
+func buildParams(ctx context.Context, bd BootData, sp scriptParams, role, subRole string) (string, error) {
 	debugf("buildParams(%v, %v, %v, %v)", bd, sp, role, subRole)
-	params := bd.Params
-	if bd.Kernel.Params != "" {
-		params += " " + bd.Kernel.Params
+	params := sp.params
+	if params == "" {
+		params = staticParams(bd, sp)
 	}
-	if bd.Initrd.Params != "" {
-		params += " " + bd.Initrd.Params
-	}
-
-	// Check for special boot parameters.
-	params = checkParam(params, "xname=", sp.xname)
-	params = checkParam(params, "nid=", sp.nid)
-	if sp.referralToken != "" {
-		params = checkParam(params, "bss_referral_token=", sp.referralToken)
-	}
-	// Add BOOTIF to params to force 1st mac
-	if sp.mac != "" {
-		bootif := "01-" + strings.ReplaceAll(sp.mac, ":", "-")
-		params = checkParam(params, "BOOTIF=", bootif)
-	}
-
-	// Inject the cloud init address info into the kernel params. If the target
-	// image does not have cloud-init enabled this wont hurt anything.
-	// If it does, it tells it to come back to us for the cloud-init meta-data
-	params = checkParam(params, "ds=", fmt.Sprintf("nocloud-net;s=%s/", advertiseAddress))
 
 	var err error
 	params, err = paramSubstitute(params, joinTokenVarName,
-		func() (string, error) { return getJoinToken(sp.xname, role, subRole) })
+		func() (string, error) {
+			token, err := getJoinToken(ctx, sp.xname, role, subRole)
+			if err != nil {
+				spireTokenFailures.Inc()
+			}
+			return token, err
+		})
 
 	if err != nil {
 		return "", err
 	}
 
-	params, err = replaceS3Params(params, checkURL)
+	params, err = replaceS3Params(params, tracedCheckURL(ctx))
 	if err != nil {
 		log.Printf("Error replacing s3 URIs. error: %v, params:\n%s", err, params)
 		err = nil
@@ -731,15 +770,46 @@ func buildParams(bd BootData, sp scriptParams, role, subRole string) (string, er
 	return params, nil
 }
 
+// staticParams builds the kernel parameters that do not change from request
+// to request: the stored parameters plus the xname, NID, referral token,
+// BOOTIF, and cloud-init data source.
+func staticParams(bd BootData, sp scriptParams) string {
+	params := bd.Params
+	if bd.Kernel.Params != "" {
+		params += " " + bd.Kernel.Params
+	}
+	if bd.Initrd.Params != "" {
+		params += " " + bd.Initrd.Params
+	}
+
+	// Check for special boot parameters.
+	params = checkParam(params, "xname=", sp.xname)
+	params = checkParam(params, "nid=", sp.nid)
+	if sp.referralToken != "" {
+		params = checkParam(params, "bss_referral_token=", sp.referralToken)
+	}
+	// Add BOOTIF to params to force 1st mac
+	if sp.mac != "" {
+		bootif := "01-" + strings.ReplaceAll(sp.mac, ":", "-")
+		params = checkParam(params, "BOOTIF=", bootif)
+	}
+
+	// Inject the cloud init address info into the kernel params. If the target
+	// image does not have cloud-init enabled this wont hurt anything.
+	// If it does, it tells it to come back to us for the cloud-init meta-data
+	params = checkParam(params, "ds=", fmt.Sprintf("nocloud-net;s=%s/", advertiseAddress))
+	return params
+}
+
 // Function unknownBootScript() constructs the boot script for an unknown host
 // or unknown MAC address.  This is done based on the system architecture.  If
 // the architecture is unknown, the returned script is simply a chained request
 // which will allow the requesting node to return the architecture.
-func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, subRole string, descr string) (string, bool, error) {
+func unknownBootScript(ctx context.Context, arch, mac, name string, nid int, ts int64, role string, subRole string, descr string) (string, bool, error) {
 	debugf("unknownBootScript(%s)", arch)
 	var script string
 	var err error
-	chain := "chain " + chainProto + "://" + ipxeServer + gwURI + "/boot/v1/bootscript"
+	chain := "chain " + chainProtocol(ctx) + "://" + ipxeServer + gwURI + "/boot/v1/bootscript"
 	if mac != "" {
 		chain += "?mac=" + mac
 	} else if name != "" {
@@ -763,7 +833,10 @@ func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, s
 			// data.  If retrieving state takes longer than our delay, when the
 			// next request comes in, it will wait for the lock to clear, at
 			// which point the updated state will be there.
-			script += fmt.Sprintf("sleep %d\n", hsmRetrievalDelay)
+			configMutex.RLock()
+			delay := hsmRetrievalDelay
+			configMutex.RUnlock()
+			script += fmt.Sprintf("sleep %d\n", delay)
 		} else if ukeys, e := unknownKeys(); e != nil || len(ukeys) == 0 {
 			err = fmt.Errorf("%s: no configuration available for unknown hosts", descr)
 			log.Printf("%s: no configuration available for unknown hosts", descr)
@@ -773,7 +846,7 @@ func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, s
 		script += chain + "\n"
 	} else {
 		bd := lookup(unknownPrefix+arch, "", "", "")
-		script, err = buildBootScript(bd, scriptParams{}, chain, role, subRole, descr)
+		script, err = buildBootScript(ctx, bd, scriptParams{}, chain, role, subRole, descr)
 	}
 	return script, retrievingState, err
 }
@@ -787,7 +860,10 @@ func unknownBootScript(arch, mac, name string, nid int, ts int64, role string, s
 // configuration.
 func blacklist(comp SMComponent) (err error) {
 	block := false
-	for _, r := range blockedRoles {
+	configMutex.RLock()
+	roles := blockedRoles
+	configMutex.RUnlock()
+	for _, r := range roles {
 		if strings.EqualFold(r, comp.Role) {
 			block = true
 			break
@@ -807,6 +883,20 @@ func blacklist(comp SMComponent) (err error) {
 
 func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 	debugf("BootscriptGet(): Received request %v\n", r.URL)
+	start := time.Now()
+	outcome := outcomeError
+	var ev bssTypes.BootscriptEvent
+	var role string
+	defer func() {
+		observeBootscript(outcome, start)
+		ev.ID, ev.Time, ev.Outcome = newEventID(), time.Now().Unix(), outcome
+		notifyBootscript(ev)
+		if ev.Xname != "" {
+			publishEvent(eventTypeBootscript, []string{ev.Xname}, []string{role}, ev)
+		} else {
+			publishEvent(eventTypeBootscript, nil, nil, ev)
+		}
+	}()
 
 	r.ParseForm() // r.Form is empty until after parsing
 	mac := strings.Join(r.Form["mac"], "")
@@ -819,42 +909,82 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 
 	nid := int(tmp_nid)
 	retry := int(tmp_retry)
+	ev.Mac, ev.Arch, ev.Retry, ev.IP = mac, arch, retry, findRemoteAddr(r)
 
-	var bd BootData
-	var comp SMComponent
 	var descr string
 
+	ctx := r.Context()
+	_, span := tracer.Start(ctx, "bss.lookup")
+	entry, ok := bootLookup(mac, name, nid)
+	if !ok {
+		span.End()
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Need a mac=, name=, or nid= parameter")
+		log.Printf("BSS request failed: bootscript request without mac=, name=, or nid= parameter")
+		return
+	}
+	bd, comp := entry.bd, entry.comp
+	ev.Xname, role = comp.ID, comp.Role
+	ev.ConfigRevision = bootConfigRevision(bd)
+	if n, e := comp.NID.Int64(); e == nil {
+		ev.Nid = int32(n)
+	} else if nid >= 0 {
+		ev.Nid = int32(nid)
+	}
+	if ev.Mac == "" && len(comp.Mac) > 0 {
+		ev.Mac = comp.Mac[0]
+	}
 	if mac != "" {
-		bd, comp = LookupByMAC(mac)
 		descr = fmt.Sprintf("MAC %s", mac)
 		if comp.ID != "" {
 			descr += fmt.Sprintf(" (%s)", comp.ID)
 		}
 	} else if name != "" {
-		bd, comp = LookupByName(name)
 		descr = name
 		if comp.ID != "" && comp.ID != name {
 			descr += fmt.Sprintf(" (%s)", comp.ID)
 		}
-	} else if nid >= 0 {
-		bd, comp = LookupByNid(nid)
+	} else {
 		descr = fmt.Sprintf("NID %d", nid)
 		if comp.ID != "" {
 			descr += fmt.Sprintf(" (%s)", comp.ID)
 		}
-	} else {
-		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Need a mac=, name=, or nid= parameter")
-		log.Printf("BSS request failed: bootscript request without mac=, name=, or nid= parameter")
-		return
 	}
-	sp := scriptParams{comp.ID, comp.NID.String(), bd.ReferralToken, mac}
+	span.SetAttributes(attribute.String("bss.xname", comp.ID))
+	span.End()
+	sp := scriptParams{
+		xname:         comp.ID,
+		nid:           comp.NID.String(),
+		referralToken: bd.ReferralToken,
+		mac:           mac,
+		params:        entry.params,
+	}
 
 	debugf("bd: %v\n", bd)
 	debugf("comp: %v\n", comp)
 
 	is_json, _ := getIntParam(r, "json", 0)
+
+	// Shed load before doing any work that reaches SPIRE or S3.
+	release, admitted := bootscriptAdmission.tryAcquire(comp.Role)
+	if !admitted {
+		outcome = outcomeThrottled
+		log.Printf("BSS request throttled for %s", descr)
+		if is_json != 0 {
+			configMutex.RLock()
+			w.Header().Set("Retry-After", fmt.Sprint(throttleDelay))
+			configMutex.RUnlock()
+			base.SendProblemDetailsGeneric(w, http.StatusServiceUnavailable, "Too many concurrent boot script requests")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "%s\n", throttledBootScript(r))
+		return
+	}
+	defer release()
+
 	if is_json != 0 {
-		params, err := buildParams(bd, sp, comp.Role, comp.SubRole)
+		params, err := buildParams(ctx, bd, sp, comp.Role, comp.SubRole)
 		if err != nil {
 			message := fmt.Sprintf("Failed to build params: %v", err)
 			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, message)
@@ -872,6 +1002,7 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 		w.WriteHeader(http.StatusOK)
 		w.Write(b)
+		outcome = outcomeServed
 		return
 	}
 
@@ -883,7 +1014,11 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 	// either of these cases, we want to boot the discovery kernel.
 	unknown := comp.ID == "" || !comp.EndpointEnabled || bd.Kernel.Path == ""
 	retreivingState := false
+	blocked := false
+	rescued := false
+	servedOutcome := outcomeServed
 	if unknown {
+		servedOutcome = outcomeUnknown
 		debugf("Unknown: comp: %v", comp)
 		if name == "" {
 			name = comp.ID
@@ -907,7 +1042,10 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		if arch != "" {
 			descr += " architecture " + arch
 		}
-		script, retreivingState, err = unknownBootScript(arch, mac, name, nid, ts, comp.Role, comp.SubRole, descr)
+		if comp.ID == "" {
+			recordDiscovered(r, mac, arch)
+		}
+		script, retreivingState, err = unknownBootScript(ctx, arch, mac, name, nid, ts, comp.Role, comp.SubRole, descr)
 		if err != nil {
 			debugf("unknownBootScript returned error: %s", err.Error())
 		}
@@ -917,29 +1055,33 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		// happens when there is no discovery kernel configured.  If this is
 		// a known component, we will then attempt to provide a non-discovery
 		// bootscript.
+		servedOutcome = outcomeServed
 		err = blacklist(comp)
+		blocked = err != nil
 		if err == nil {
 			if mac == "" && comp.Mac != nil {
 				mac = comp.Mac[0]
 			}
-			chain := "chain " + chainProto + "://" + ipxeServer + gwURI + r.URL.Path
+			chain := "chain " + chainProtocol(ctx) + "://" + ipxeServer + gwURI + r.URL.Path
 			if mac != "" {
 				chain += "?mac=" + mac
 			} else {
 				chain += "?name=" + comp.ID
 			}
 			chain += fmt.Sprintf("&retry=%d", retry+1)
-			if useSQL {
-				retreivingState = false
-			} else {
-				retreivingState = checkState(false)
-			}
+			retreivingState = checkState(false)
 			if retreivingState {
 				// We want to respond with a delayed chain response so that the
 				// node will retry in a bit after we have updated our state info
 				script = "#!ipxe\nsleep 10\n" + chain + "\n"
+			} else if limit := rescueRetryLimit(comp); limit > 0 && retry >= int(limit) {
+				script, rescued, err = rescueBootScript(ctx, sp, comp, retry, descr)
+				servedOutcome = outcomeRescue
+				if !rescued {
+					servedOutcome = outcomeAbandoned
+				}
 			} else {
-				script, err = buildBootScript(bd, sp, chain, comp.Role, comp.SubRole, descr)
+				script, err = buildBootScript(ctx, bd, sp, chain, comp.Role, comp.SubRole, descr)
 			}
 		}
 	}
@@ -949,17 +1091,27 @@ func BootscriptGet(w http.ResponseWriter, r *http.Request) {
 		_, err = fmt.Fprintf(w, "%s\n", script)
 		if err == nil {
 			if retreivingState {
+				outcome = outcomeDelayed
 				log.Printf("BSS request delayed for %s while updating state", descr)
 			} else {
+				outcome = servedOutcome
 				log.Printf("BSS request succeeded for %s", descr)
 
 				// Record the fact this was asked for.
-				updateEndpointAccessed(comp.ID, bssTypes.EndpointTypeBootscript)
+				clientIP := findRemoteAddr(r)
+				updateEndpointAccessed(comp.ID, bssTypes.EndpointTypeBootscript, clientIP, bd.Default && !rescued)
+				if rescued {
+					updateEndpointAccessed(comp.ID, bssTypes.EndpointTypeRescue, clientIP, false)
+				}
+				recordBootEvent(comp.ID, bootEventBootscript, retry)
 			}
 		} else {
 			log.Printf("BSS request failed writing response for %s: %s", descr, err.Error())
 		}
 	} else {
+		if blocked {
+			outcome = outcomeBlocked
+		}
 		base.SendProblemDetailsGeneric(w, http.StatusNotFound, err.Error())
 		if strings.HasPrefix(err.Error(), descr) {
 			log.Printf("BSS request failed: %s", err.Error())
@@ -975,7 +1127,8 @@ func HostsGet(w http.ResponseWriter, r *http.Request) {
 	mac := strings.Join(r.Form["mac"], ",")
 	name := strings.Join(r.Form["name"], ",")
 	nid := strings.Join(r.Form["nid"], ",")
-	qparams := mac != "" || name != "" || nid != ""
+	role := strings.Join(r.Form["role"], ",")
+	qparams := mac != "" || name != "" || nid != "" || role != ""
 	state := getState()
 	results := state.Components
 	if qparams {
@@ -1022,6 +1175,18 @@ func HostsGet(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
+		if role != "" {
+			for _, rl := range strings.Split(role, ",") {
+				comps := FindSMCompsByRole(rl, "")
+				if len(comps) > 0 {
+					results = append(results, comps...)
+				} else {
+					base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+						fmt.Sprintf("Not Found - Unknown role '%s'", rl))
+					return
+				}
+			}
+		}
 	}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
@@ -1050,7 +1215,9 @@ func DumpstateGet(w http.ResponseWriter, r *http.Request) {
 	results.Components = state.Components
 	var err error
 	if useSQL {
+		start := time.Now()
 		results.Params, err = bssdb.GetBootParamsAll()
+		observeSQL("get_all", start, err)
 		if err != nil {
 			log.Printf("DumpStateGet(): GetBootParamsAll(): Could not get boot parameters from SQL DB: %v", err)
 			err = fmt.Errorf("Error retrieving boot parameters from database")
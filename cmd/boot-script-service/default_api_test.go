@@ -24,6 +24,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -422,7 +423,7 @@ func TestBuildParams_Success(t *testing.T) {
 
 	for _, tc := range test_cases {
 		// role and subRole are only used when adding spire jopin tokens, which we can't test for this way.
-		output, err := buildParams(tc.bd, tc.sp, "dummy role", "dummy subRole")
+		output, err := buildParams(context.Background(), tc.bd, tc.sp, "dummy role", "dummy subRole")
 		if err != nil {
 			t.Errorf("Failed to build params: %v\n", err)
 		}
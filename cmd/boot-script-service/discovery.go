@@ -0,0 +1,540 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Discovery registry.  When enabled, BSS remembers every node that requests a
+// boot script with a MAC address it does not recognize.  Operators can list
+// these nodes and adopt them under a name, which adds them to the node
+// inventory whatever its source.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/internal/postgres"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+const discoveredPfx = "/discovered/"
+
+var (
+	discoveryEnabled = false // Record unknown nodes that request a boot script
+
+	discovery discoveryStore
+)
+
+type discoveryStore interface {
+	// record adds a newly seen node, or updates the last time it was seen.
+	record(dn bssTypes.DiscoveredNode) error
+	list() ([]bssTypes.DiscoveredNode, error)
+	adopt(a bssTypes.DiscoveryAdoption) (found bool, err error)
+	// remove deletes a node, returning the name it was adopted as, if any.
+	remove(mac string) (name string, found bool, err error)
+}
+
+// etcdDiscoveryStore keeps each node as JSON under discoveredPfx.  Updates
+// of existing nodes are compare-and-swapped, so that a node that keeps asking
+// for a boot script cannot undo an adoption made at the same time, even by
+// another instance.  Updates of a node made by this instance are also
+// serialized, since a node that does not exist yet cannot be compared.
+type etcdDiscoveryStore struct{}
+
+// Maximum number of attempts to update a node that keeps changing
+const discoveryUpdateAttempts = 10
+
+var discoveryLocks keyLocks
+
+// keyLocks serializes read-modify-write updates of datastore entries made by
+// this instance.  Keys share a fixed number of mutexes, so that updates of
+// different entries rarely wait for each other.
+type keyLocks [64]sync.Mutex
+
+func (l *keyLocks) index(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % uint32(len(l))
+}
+
+// lock locks the mutex for key and returns the function that unlocks it.
+func (l *keyLocks) lock(key string) func() {
+	m := &l[l.index(key)]
+	m.Lock()
+	return m.Unlock
+}
+
+// lockAll locks the mutexes for keys, in a fixed order so that callers
+// locking overlapping keys do not deadlock, and returns the function that
+// unlocks them.
+func (l *keyLocks) lockAll(keys []string) func() {
+	var held [len(l)]bool
+	for _, key := range keys {
+		held[l.index(key)] = true
+	}
+	for i := range held {
+		if held[i] {
+			l[i].Lock()
+		}
+	}
+	return func() {
+		for i := range held {
+			if held[i] {
+				l[i].Unlock()
+			}
+		}
+	}
+}
+
+func (etcdDiscoveryStore) get(mac string) (dn bssTypes.DiscoveredNode, exists bool, err error) {
+	val, exists, err := kvstore.Get(discoveredPfx + mac)
+	if err != nil || !exists {
+		return dn, false, err
+	}
+	err = json.Unmarshal([]byte(val), &dn)
+	return dn, err == nil, err
+}
+
+// update applies change to the stored node, retrying if the node changed
+// while it was being updated.  If the node does not exist, it stores create
+// instead, unless create is nil.
+func (etcdDiscoveryStore) update(mac string, create *bssTypes.DiscoveredNode, change func(*bssTypes.DiscoveredNode)) (bool, error) {
+	defer discoveryLocks.lock(mac)()
+	key := discoveredPfx + mac
+	for attempt := 0; attempt < discoveryUpdateAttempts; attempt++ {
+		val, exists, err := kvstore.Get(key)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			if create == nil {
+				return false, nil
+			}
+			data, err := json.Marshal(create)
+			if err != nil {
+				return false, err
+			}
+			return true, kvstore.Store(key, string(data))
+		}
+		var dn bssTypes.DiscoveredNode
+		if err = json.Unmarshal([]byte(val), &dn); err != nil {
+			return false, err
+		}
+		change(&dn)
+		data, err := json.Marshal(dn)
+		if err != nil {
+			return false, err
+		}
+		if swapped, err := kvstore.TAS(key, val, string(data)); err != nil || swapped {
+			return true, err
+		}
+	}
+	return true, fmt.Errorf("discovered node %s changed during %d update attempts", mac, discoveryUpdateAttempts)
+}
+
+func (s etcdDiscoveryStore) record(dn bssTypes.DiscoveredNode) error {
+	_, err := s.update(dn.Mac, &dn, func(cur *bssTypes.DiscoveredNode) {
+		cur.IP = dn.IP
+		if dn.Arch != "" {
+			cur.Arch = dn.Arch
+		}
+		if dn.LastSeen > cur.LastSeen {
+			cur.LastSeen = dn.LastSeen
+		}
+	})
+	return err
+}
+
+func (etcdDiscoveryStore) list() ([]bssTypes.DiscoveredNode, error) {
+	kvl, err := kvstore.GetRange(discoveredPfx+keyMin, discoveredPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]bssTypes.DiscoveredNode, 0, len(kvl))
+	for _, kv := range kvl {
+		var dn bssTypes.DiscoveredNode
+		if err := json.Unmarshal([]byte(kv.Value), &dn); err != nil {
+			log.Printf("WARNING: ignoring malformed discovery entry %s: %v", kv.Key, err)
+			continue
+		}
+		nodes = append(nodes, dn)
+	}
+	return nodes, nil
+}
+
+func (s etcdDiscoveryStore) adopt(a bssTypes.DiscoveryAdoption) (bool, error) {
+	return s.update(a.Mac, nil, func(dn *bssTypes.DiscoveredNode) {
+		dn.Name, dn.Nid, dn.Role, dn.SubRole = a.Name, a.Nid, a.Role, a.SubRole
+	})
+}
+
+func (s etcdDiscoveryStore) remove(mac string) (string, bool, error) {
+	defer discoveryLocks.lock(mac)()
+	dn, exists, err := s.get(mac)
+	if err != nil || !exists {
+		return "", false, err
+	}
+	return dn.Name, true, kvstore.Delete(discoveredPfx + mac)
+}
+
+// sqlDiscoveryStore keeps nodes in the discovered_nodes table.
+type sqlDiscoveryStore struct {
+	db postgres.BootDataDatabase
+}
+
+func (s sqlDiscoveryStore) record(dn bssTypes.DiscoveredNode) error {
+	start := time.Now()
+	err := s.db.RecordDiscoveredNode(dn)
+	observeSQL("record_discovered_node", start, err)
+	return err
+}
+
+func (s sqlDiscoveryStore) list() ([]bssTypes.DiscoveredNode, error) {
+	start := time.Now()
+	nodes, err := s.db.GetDiscoveredNodes()
+	observeSQL("get_discovered_nodes", start, err)
+	return nodes, err
+}
+
+func (s sqlDiscoveryStore) adopt(a bssTypes.DiscoveryAdoption) (bool, error) {
+	start := time.Now()
+	found, err := s.db.AdoptDiscoveredNode(a)
+	observeSQL("adopt_discovered_node", start, err)
+	return found, err
+}
+
+func (s sqlDiscoveryStore) remove(mac string) (string, bool, error) {
+	start := time.Now()
+	name, found, err := s.db.DeleteDiscoveredNode(mac)
+	observeSQL("delete_discovered_node", start, err)
+	return name, found, err
+}
+
+// initDiscovery sets up the discovery registry, if enabled, in the configured
+// datastore.  The datastore must already be open.
+func initDiscovery() {
+	if !discoveryEnabled {
+		return
+	}
+	if useSQL {
+		discovery = sqlDiscoveryStore{db: bssdb}
+	} else if kvstore != nil {
+		discovery = etcdDiscoveryStore{}
+	}
+	log.Printf("Recording unknown nodes that request a boot script")
+}
+
+// recordDiscovered notes that an unknown node with the given MAC address
+// requested a boot script.  The datastore is updated in the background so
+// that the boot script is not delayed.
+func recordDiscovered(r *http.Request, mac, arch string) {
+	if discovery == nil {
+		return
+	}
+	mac = ensureLegalMAC(mac)
+	if mac == badMAC {
+		return
+	}
+	now := time.Now().Unix()
+	dn := bssTypes.DiscoveredNode{
+		Mac:       mac,
+		Arch:      arch,
+		IP:        strings.TrimSpace(findRemoteAddr(r)),
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	goBackground(func() {
+		if err := discovery.record(dn); err != nil {
+			log.Printf("Failed to record discovered node %s: %v", mac, err)
+		}
+	})
+}
+
+// mergeAdopted adds the adopted nodes in the discovery registry to an
+// inventory snapshot.  A node the inventory already has under the adopted
+// name is left alone; one it only knows by MAC address, such as a node added
+// to the Postgres nodes table by boot parameters, is replaced.
+func mergeAdopted(state *SMData) *SMData {
+	if discovery == nil {
+		return state
+	}
+	nodes, err := discovery.list()
+	if err != nil {
+		log.Printf("Failed to read adopted nodes: %v", err)
+		return state
+	}
+	names := make(map[string]bool, len(state.Components))
+	for _, c := range state.Components {
+		names[c.ID] = true
+	}
+	var hosts []inventoryHost
+	adoptedMACs := make(map[string]bool)
+	for _, dn := range nodes {
+		if dn.Name == "" {
+			continue
+		}
+		if names[dn.Name] {
+			debugf("Adopted node %s (%s) is already in the inventory", dn.Name, dn.Mac)
+			continue
+		}
+		names[dn.Name] = true
+		h := inventoryHost{
+			Name:    dn.Name,
+			MACs:    []string{dn.Mac},
+			Role:    dn.Role,
+			SubRole: dn.SubRole,
+		}
+		if dn.IP != "" {
+			h.IPs = []string{dn.IP}
+		}
+		if dn.Nid > 0 {
+			nid := int64(dn.Nid)
+			h.NID = &nid
+		}
+		if strings.HasPrefix(dn.Arch, "arm") {
+			h.Arch = "ARM"
+		}
+		hosts = append(hosts, h)
+		adoptedMACs[normalizeMAC(dn.Mac)] = true
+	}
+	if len(hosts) == 0 {
+		return state
+	}
+	adopted, err := hostsToSMData(hosts)
+	if err != nil {
+		log.Printf("Failed to add adopted nodes to the inventory: %v", err)
+		return state
+	}
+	comps := make([]SMComponent, 0, len(state.Components)+len(adopted.Components))
+	for _, c := range state.Components {
+		replaced := false
+		for _, m := range c.Mac {
+			replaced = replaced || adoptedMACs[normalizeMAC(m)]
+		}
+		if !replaced {
+			comps = append(comps, c)
+		}
+	}
+	state.Components = append(comps, adopted.Components...)
+	if state.IPAddrs == nil {
+		state.IPAddrs = adopted.IPAddrs
+	} else {
+		for ip, e := range adopted.IPAddrs {
+			state.IPAddrs[ip] = e
+		}
+	}
+	return state
+}
+
+// discoveryChanged makes this and every other BSS instance reload the
+// inventory after a node was adopted or an adopted node was removed.
+func discoveryChanged() {
+	refreshState(-1)
+	if stateSig != nil {
+		if err := stateSig.publish(time.Now().Unix()); err != nil {
+			log.Printf("Failed to publish inventory change: %v", err)
+		}
+	}
+}
+
+func discoveryDisabled(w http.ResponseWriter) bool {
+	if discovery == nil {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, "Node discovery is not enabled")
+		return true
+	}
+	return false
+}
+
+func discoveredGetAPI(w http.ResponseWriter, r *http.Request) {
+	if discoveryDisabled(w) {
+		return
+	}
+	var adopted *bool
+	if v := r.URL.Query().Get("adopted"); v != "" {
+		b := strings.EqualFold(v, "true")
+		if !b && !strings.EqualFold(v, "false") {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid value %q for adopted, expected true or false", v))
+			return
+		}
+		adopted = &b
+	}
+	nodes, err := discovery.list()
+	if err != nil {
+		log.Printf("Failed to list discovered nodes: %v", err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to list discovered nodes")
+		return
+	}
+	// Always make sure to give back at least an empty array instead of `null`.
+	results := []bssTypes.DiscoveredNode{}
+	for _, dn := range nodes {
+		if adopted == nil || *adopted == (dn.Name != "") {
+			results = append(results, dn)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Mac < results[j].Mac })
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
+
+// groupBootParams returns the boot parameters of a boot group, i.e. those
+// stored under the group name, so they can be copied to an adopted node.
+func groupBootParams(group string) (bp bssTypes.BootParams, exists bool, err error) {
+	if useSQL {
+		start := time.Now()
+		bp, exists, err = bssdb.GetBootParamsByGroup(group)
+		observeSQL("get_by_group", start, err)
+		return
+	}
+	bds, err := lookupHost(group)
+	if err != nil {
+		// lookupHost does not distinguish a missing key from a failed read.
+		return bp, false, nil
+	}
+	bd := bdConvert(bds)
+	bp = bssTypes.BootParams{
+		Kernel:    bd.Kernel.Path,
+		Initrd:    bd.Initrd.Path,
+		Params:    bd.Params,
+		CloudInit: bd.CloudInit,
+	}
+	return bp, true, nil
+}
+
+func discoveredPostAPI(w http.ResponseWriter, r *http.Request) {
+	if discoveryDisabled(w) {
+		return
+	}
+	var a bssTypes.DiscoveryAdoption
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Bad Request: %v", err))
+		return
+	}
+	mac := ensureLegalMAC(a.Mac)
+	if mac == badMAC {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Invalid MAC address %q", a.Mac))
+		return
+	}
+	a.Mac = mac
+	if a.Name == "" {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "A name is required to adopt a node")
+		return
+	}
+	if c, ok := FindSMCompByName(a.Name); ok && !containsMAC(c.Mac, mac) {
+		base.SendProblemDetailsGeneric(w, http.StatusConflict,
+			fmt.Sprintf("Node %s is already in the inventory", a.Name))
+		return
+	}
+
+	var bp bssTypes.BootParams
+	if a.Group != "" {
+		var exists bool
+		var err error
+		bp, exists, err = groupBootParams(a.Group)
+		if err != nil {
+			log.Printf("Failed to read boot parameters of group %s: %v", a.Group, err)
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Failed to read boot parameters of group %s", a.Group))
+			return
+		} else if !exists {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Boot group %s has no boot parameters", a.Group))
+			return
+		}
+	}
+
+	found, err := discovery.adopt(a)
+	if err != nil {
+		log.Printf("Failed to adopt discovered node %s: %v", mac, err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to adopt node")
+		return
+	} else if !found {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, fmt.Sprintf("No discovered node with MAC address %s", mac))
+		return
+	}
+	log.Printf("Adopted discovered node %s as %s", mac, a.Name)
+
+	if a.Group != "" {
+		// Postgres keys boot parameters by boot MAC address; etcd by name,
+		// which the node is now known by.
+		if useSQL {
+			bp.Macs = []string{mac}
+			err, _ = StoreNew(bp)
+		} else {
+			bp.Hosts = []string{a.Name}
+			err, _ = Store(bp)
+		}
+		if err != nil {
+			log.Printf("Failed to copy boot parameters of group %s to %s: %v", a.Group, a.Name, err)
+			base.SendProblemDetailsGeneric(w, http.StatusInternalServerError,
+				fmt.Sprintf("Node adopted, but copying the boot parameters of group %s failed", a.Group))
+			discoveryChanged()
+			return
+		}
+	}
+	discoveryChanged()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func discoveredDeleteAPI(w http.ResponseWriter, r *http.Request) {
+	if discoveryDisabled(w) {
+		return
+	}
+	mac := ensureLegalMAC(r.URL.Query().Get("mac"))
+	if mac == badMAC {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "A valid mac is required")
+		return
+	}
+	name, found, err := discovery.remove(mac)
+	if err != nil {
+		log.Printf("Failed to remove discovered node %s: %v", mac, err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to remove discovered node")
+		return
+	} else if !found {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, fmt.Sprintf("No discovered node with MAC address %s", mac))
+		return
+	}
+	if name != "" {
+		log.Printf("Removed adopted node %s (%s)", name, mac)
+		discoveryChanged()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func containsMAC(macs []string, mac string) bool {
+	for _, m := range macs {
+		if normalizeMAC(m) == mac {
+			return true
+		}
+	}
+	return false
+}
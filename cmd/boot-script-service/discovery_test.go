@@ -0,0 +1,179 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+func listDiscovered(t *testing.T, query string) []bssTypes.DiscoveredNode {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	discovered(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/discovered"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /discovered%s returned %d: %s", query, rr.Code, rr.Body.String())
+	}
+	var nodes []bssTypes.DiscoveredNode
+	if err := json.Unmarshal(rr.Body.Bytes(), &nodes); err != nil {
+		t.Fatalf("Unable to decode discovered nodes: %v", err)
+	}
+	return nodes
+}
+
+func TestDiscoveryDisabled(t *testing.T) {
+	saved := discovery
+	defer func() { discovery = saved }()
+	discovery = nil
+
+	rr := httptest.NewRecorder()
+	discovered(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/discovered", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET /discovered returned %d with discovery disabled", rr.Code)
+	}
+}
+
+func TestDiscoveryAdopt(t *testing.T) {
+	saved := discovery
+	defer func() { discovery = saved }()
+	discovery = etcdDiscoveryStore{}
+
+	const mac = "02:00:00:00:be:ef"
+	for _, arch := range []string{"", "x86_64"} {
+		req := httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?mac=02-00-00-00-BE-EF&arch="+arch, nil)
+		req.RemoteAddr = "10.1.2.3:4011"
+		BootscriptGet(httptest.NewRecorder(), req)
+		// A request without an architecture refreshes the state in the
+		// background.
+		backgroundTasks.Wait()
+	}
+
+	nodes := listDiscovered(t, "")
+	if len(nodes) != 1 {
+		t.Fatalf("Expected 1 discovered node, got %+v", nodes)
+	}
+	dn := nodes[0]
+	if dn.Mac != mac || dn.Arch != "x86_64" || dn.IP != "10.1.2.3" || dn.FirstSeen == 0 || dn.LastSeen < dn.FirstSeen || dn.Name != "" {
+		t.Errorf("Unexpected discovered node: %+v", dn)
+	}
+	if n := listDiscovered(t, "?adopted=true"); len(n) != 0 {
+		t.Errorf("Unadopted node listed as adopted: %+v", n)
+	}
+
+	err, _ := Store(bssTypes.BootParams{Hosts: []string{"discovery-group"}, Kernel: "/test/path/vmlinuz", Params: "group=1"})
+	if err != nil {
+		t.Fatalf("Unable to store group boot parameters: %v", err)
+	}
+	defer Remove(bssTypes.BootParams{Hosts: []string{"discovery-group", "x9000c0s0b0n0"}})
+
+	post := func(body string) int {
+		rr := httptest.NewRecorder()
+		discovered(rr, httptest.NewRequest(http.MethodPost, "/boot/v1/discovered", bytes.NewBufferString(body)))
+		return rr.Code
+	}
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"mac":"bogus","name":"x9000c0s0b0n0"}`, http.StatusBadRequest},
+		{`{"mac":"` + mac + `"}`, http.StatusBadRequest},
+		{`{"mac":"02:00:00:00:00:01","name":"x9000c0s0b0n0"}`, http.StatusNotFound},
+		{`{"mac":"` + mac + `","name":"x0c0s1b0n0"}`, http.StatusConflict},
+		{`{"mac":"` + mac + `","name":"x9000c0s0b0n0","group":"no-such-group"}`, http.StatusBadRequest},
+		{`{"mac":"` + mac + `","name":"x9000c0s0b0n0","nid":9000,"role":"Application","group":"discovery-group"}`, http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		if code := post(tc.body); code != tc.code {
+			t.Errorf("POST %s returned %d, expected %d", tc.body, code, tc.code)
+		}
+	}
+
+	c, ok := FindSMCompByMAC(mac)
+	if !ok || c.ID != "x9000c0s0b0n0" || c.Role != "Application" || c.NID.String() != "9000" {
+		t.Errorf("Adopted node not in inventory: %+v", c)
+	}
+	if id, ok := FindXnameByIP("10.1.2.3"); !ok || id != "x9000c0s0b0n0" {
+		t.Errorf("Adopted node not found by IP: %q", id)
+	}
+	rr := httptest.NewRecorder()
+	BootscriptGet(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?mac="+mac, nil))
+	if !strings.Contains(rr.Body.String(), "group=1") {
+		t.Errorf("Adopted node did not get the group's boot parameters: %s", rr.Body.String())
+	}
+	if n := listDiscovered(t, "?adopted=true"); len(n) != 1 || n[0].Name != "x9000c0s0b0n0" {
+		t.Errorf("Adopted node not listed as adopted: %+v", n)
+	}
+
+	rr = httptest.NewRecorder()
+	discovered(rr, httptest.NewRequest(http.MethodDelete, "/boot/v1/discovered?mac="+mac, nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d", rr.Code)
+	}
+	if _, ok = FindSMCompByMAC(mac); ok {
+		t.Errorf("Removed node is still in the inventory")
+	}
+	rr = httptest.NewRecorder()
+	discovered(rr, httptest.NewRequest(http.MethodDelete, "/boot/v1/discovered?mac="+mac, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Second DELETE returned %d", rr.Code)
+	}
+}
+
+func TestDiscoveryRecordKeepsAdoption(t *testing.T) {
+	const mac = "02:00:00:00:be:f0"
+	s := etcdDiscoveryStore{}
+	defer s.remove(mac)
+
+	seen := bssTypes.DiscoveredNode{Mac: mac, IP: "10.1.2.4", FirstSeen: 1, LastSeen: 1}
+	if err := s.record(seen); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 2; i < 50; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+			dn := seen
+			dn.LastSeen = ts
+			if err := s.record(dn); err != nil {
+				t.Errorf("record failed: %v", err)
+			}
+		}(int64(i))
+	}
+	if found, err := s.adopt(bssTypes.DiscoveryAdoption{Mac: mac, Name: "x9000c0s0b1n0", Nid: 9001}); !found || err != nil {
+		t.Errorf("adopt returned %t, %v", found, err)
+	}
+	wg.Wait()
+
+	dn, _, err := s.get(mac)
+	if err != nil || dn.Name != "x9000c0s0b1n0" || dn.Nid != 9001 || dn.LastSeen != 49 {
+		t.Errorf("Concurrent records lost the adoption: %+v, %v", dn, err)
+	}
+}
@@ -0,0 +1,215 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Endpoint access history.
+//
+// By default only the latest access of each endpoint by each node is kept,
+// with either storage backend. In full mode every access is kept as well, and
+// a retention period bounds how long accesses are kept in either mode.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenCHAMI/bss/internal/postgres"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+const (
+	endpointHistoryModeLast = "last"
+	endpointHistoryModeFull = "full"
+
+	endpointHistoryPfx = "/endpoint-history"
+
+	endpointHistoryPruneInterval = time.Hour
+)
+
+var (
+	endpointHistoryMode      = endpointHistoryModeLast // last or full
+	endpointHistoryRetention = uint(0)                 // Seconds to keep endpoint accesses, 0 to keep them forever
+)
+
+// fullEndpointHistory reports whether every endpoint access is kept.
+func fullEndpointHistory() bool {
+	return strings.EqualFold(endpointHistoryMode, endpointHistoryModeFull)
+}
+
+// searchEndpointHistory returns the endpoint accesses selected by f, most
+// recent first.
+func searchEndpointHistory(f postgres.EndpointAccessFilter) ([]bssTypes.EndpointAccess, error) {
+	if !fullEndpointHistory() {
+		f.LastOnly = true
+	}
+	if useSQL {
+		start := time.Now()
+		accesses, err := bssdb.SearchEndpointAccesses(f)
+		observeSQL("search_endpoint_accesses", start, err)
+		return accesses, err
+	}
+
+	var (
+		accesses []bssTypes.EndpointAccess
+		err      error
+	)
+	switch {
+	case f.LastOnly && f.Name != "" && f.Endpoint != "":
+		accesses, err = SearchEndpointAccessed(f.Name, f.Endpoint)
+	case f.LastOnly:
+		accesses, err = getAccessesForPrefix(endpointAccessPfx + "/" + nameSubkey(f.Name))
+	default:
+		accesses, err = getHistoryForPrefix(endpointHistoryPfx + "/" + nameSubkey(f.Name))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	selected := accesses[:0]
+	for _, ea := range accesses {
+		if (f.Endpoint == "" || ea.Endpoint == f.Endpoint) &&
+			(f.Since <= 0 || ea.LastEpoch >= f.Since) &&
+			(f.Until <= 0 || ea.LastEpoch <= f.Until) {
+			selected = append(selected, ea)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, b := selected[i], selected[j]
+		if a.LastEpoch != b.LastEpoch {
+			return a.LastEpoch > b.LastEpoch
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Endpoint < b.Endpoint
+	})
+	if f.Limit > 0 && len(selected) > f.Limit {
+		selected = selected[:f.Limit]
+	}
+	return selected, nil
+}
+
+func nameSubkey(name string) string {
+	if name == "" {
+		return ""
+	}
+	return name + "/"
+}
+
+// getHistoryForPrefix returns the accesses stored under
+// /endpoint-history/<name>/<endpoint>/<unix nanoseconds>.
+func getHistoryForPrefix(prefix string) (accesses []bssTypes.EndpointAccess, err error) {
+	kvs, err := searchKeyspace(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keyspace: %w", err)
+	}
+	for _, kv := range kvs {
+		parts := strings.Split(kv.Key, "/")
+		if len(parts) < 4 {
+			continue
+		}
+		ea, err := decodeEndpointAccess(kv.Value)
+		if err != nil {
+			log.Printf("Ignoring endpoint access %s: %v", kv.Key, err)
+			continue
+		}
+		ea.Name = parts[len(parts)-3]
+		ea.Endpoint = bssTypes.EndpointType(parts[len(parts)-2])
+		accesses = append(accesses, ea)
+	}
+	return accesses, nil
+}
+
+// pruneEndpointHistory removes the endpoint accesses older than the
+// retention period, if there is one.
+func pruneEndpointHistory() {
+	configMutex.RLock()
+	retention := endpointHistoryRetention
+	configMutex.RUnlock()
+	if retention == 0 {
+		return
+	}
+	before := time.Now().Unix() - int64(retention)
+
+	var removed int64
+	if useSQL {
+		var err error
+		start := time.Now()
+		removed, err = bssdb.PruneEndpointAccesses(before)
+		observeSQL("prune_endpoint_accesses", start, err)
+		if err != nil {
+			log.Printf("Failed to prune endpoint access history: %v", err)
+			return
+		}
+	} else {
+		for _, prefix := range []string{endpointAccessPfx + "/", endpointHistoryPfx + "/"} {
+			kvs, err := searchKeyspace(prefix)
+			if err != nil {
+				log.Printf("Failed to prune endpoint access history: %v", err)
+				return
+			}
+			for _, kv := range kvs {
+				ea, err := decodeEndpointAccess(kv.Value)
+				if err != nil || ea.LastEpoch >= before {
+					continue
+				}
+				if err = kvstore.Delete(kv.Key); err != nil {
+					log.Printf("Failed to prune endpoint access %s: %v", kv.Key, err)
+					continue
+				}
+				removed++
+			}
+		}
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d endpoint accesses older than %d seconds", removed, retention)
+	}
+}
+
+// startEndpointHistoryPruner prunes the endpoint access history now and
+// every endpointHistoryPruneInterval until the returned function is called.
+func startEndpointHistoryPruner() (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(endpointHistoryPruneInterval)
+		defer ticker.Stop()
+		for {
+			pruneEndpointHistory()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,236 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/internal/postgres"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	"github.com/OpenCHAMI/smd/v2/pkg/sm"
+)
+
+// storeEndpointHistory stores an access at ts in the etcd layout used in full
+// mode, as updateEndpointAccessed would have.
+func storeEndpointHistory(t *testing.T, name string, ep bssTypes.EndpointType, ts int64) {
+	value := strconv.FormatInt(ts, 10)
+	keys := []string{
+		fmt.Sprintf("%s/%s/%s/%d", endpointHistoryPfx, name, ep, ts*int64(time.Second)),
+	}
+	if last, err := getEndpointAccessed(name, ep); err == nil && last < ts {
+		keys = append(keys, fmt.Sprintf("%s/%s/%s", endpointAccessPfx, name, ep))
+	}
+	for _, key := range keys {
+		if err := kvstore.Store(key, value); err != nil {
+			t.Fatalf("Unable to store %s: %v", key, err)
+		}
+	}
+}
+
+func clearEndpointHistory() {
+	for _, prefix := range []string{endpointAccessPfx + "/", endpointHistoryPfx + "/"} {
+		kvs, _ := searchKeyspace(prefix)
+		for _, kv := range kvs {
+			kvstore.Delete(kv.Key)
+		}
+	}
+}
+
+func TestSearchEndpointHistory(t *testing.T) {
+	saved := endpointHistoryMode
+	defer func() { endpointHistoryMode = saved }()
+	clearEndpointHistory()
+	defer clearEndpointHistory()
+
+	storeEndpointHistory(t, "x1", bssTypes.EndpointTypeBootscript, 100)
+	storeEndpointHistory(t, "x1", bssTypes.EndpointTypeUserData, 110)
+	storeEndpointHistory(t, "x1", bssTypes.EndpointTypeBootscript, 200)
+	storeEndpointHistory(t, "x2", bssTypes.EndpointTypeBootscript, 150)
+
+	tests := []struct {
+		mode   string
+		filter postgres.EndpointAccessFilter
+		epochs []int64
+	}{
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{}, []int64{200, 150, 110, 100}},
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{Name: "x1"}, []int64{200, 110, 100}},
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{Endpoint: bssTypes.EndpointTypeBootscript}, []int64{200, 150, 100}},
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{Since: 110, Until: 150}, []int64{150, 110}},
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{Limit: 2}, []int64{200, 150}},
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{LastOnly: true}, []int64{200, 150, 110}},
+		{endpointHistoryModeFull, postgres.EndpointAccessFilter{Name: "x1", Endpoint: bssTypes.EndpointTypeBootscript, LastOnly: true}, []int64{200}},
+		{endpointHistoryModeLast, postgres.EndpointAccessFilter{Name: "x1"}, []int64{200, 110}},
+		{endpointHistoryModeLast, postgres.EndpointAccessFilter{Endpoint: bssTypes.EndpointTypeBootscript, Until: 199}, []int64{150}},
+	}
+	for _, tc := range tests {
+		endpointHistoryMode = tc.mode
+		accesses, err := searchEndpointHistory(tc.filter)
+		if err != nil {
+			t.Errorf("%s %+v: %v", tc.mode, tc.filter, err)
+			continue
+		}
+		var epochs []int64
+		for _, ea := range accesses {
+			epochs = append(epochs, ea.LastEpoch)
+		}
+		if fmt.Sprint(epochs) != fmt.Sprint(tc.epochs) {
+			t.Errorf("%s %+v: got %v, expected %v", tc.mode, tc.filter, epochs, tc.epochs)
+		}
+	}
+}
+
+func TestUpdateEndpointAccessedFull(t *testing.T) {
+	saved := endpointHistoryMode
+	defer func() { endpointHistoryMode = saved }()
+	clearEndpointHistory()
+	defer clearEndpointHistory()
+
+	endpointHistoryMode = endpointHistoryModeLast
+	updateEndpointAccessed("x3", bssTypes.EndpointTypeBootscript, "10.0.0.3", false)
+	endpointHistoryMode = endpointHistoryModeFull
+	updateEndpointAccessed("x3", bssTypes.EndpointTypeBootscript, "10.0.0.3", false)
+	updateEndpointAccessed("x3", bssTypes.EndpointTypeBootscript, "10.0.0.3", false)
+
+	if h, _ := getHistoryForPrefix(endpointHistoryPfx + "/x3/"); len(h) != 2 {
+		t.Errorf("Expected 2 accesses in the full history, got %+v", h)
+	}
+	if l, _ := getAccessesForPrefix(endpointAccessPfx + "/x3/"); len(l) != 1 {
+		t.Errorf("Expected 1 latest access, got %+v", l)
+	}
+}
+
+func TestPruneEndpointHistory(t *testing.T) {
+	saved := endpointHistoryRetention
+	defer func() { endpointHistoryRetention = saved }()
+	clearEndpointHistory()
+	defer clearEndpointHistory()
+
+	now := time.Now().Unix()
+	storeEndpointHistory(t, "x1", bssTypes.EndpointTypeBootscript, now-7200)
+	storeEndpointHistory(t, "x2", bssTypes.EndpointTypeBootscript, now-7200)
+	storeEndpointHistory(t, "x2", bssTypes.EndpointTypeBootscript, now-60)
+
+	endpointHistoryRetention = 0
+	pruneEndpointHistory()
+	if h, _ := getHistoryForPrefix(endpointHistoryPfx + "/"); len(h) != 3 {
+		t.Errorf("Accesses pruned without a retention period: %+v", h)
+	}
+
+	endpointHistoryRetention = 3600
+	pruneEndpointHistory()
+	if h, _ := getHistoryForPrefix(endpointHistoryPfx + "/"); len(h) != 1 || h[0].Name != "x2" {
+		t.Errorf("Unexpected history after pruning: %+v", h)
+	}
+	if l, _ := getAccessesForPrefix(endpointAccessPfx + "/"); len(l) != 1 || l[0].Name != "x2" {
+		t.Errorf("Unexpected latest accesses after pruning: %+v", l)
+	}
+}
+
+func TestEndpointHistoryGetBadRequest(t *testing.T) {
+	for _, query := range []string{"since=yesterday", "until=-1", "limit=-5", "last_only=maybe"} {
+		rr := httptest.NewRecorder()
+		endpointHistoryGetAPI(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/endpoint-history?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s returned %d, expected %d", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEndpointAccessClient(t *testing.T) {
+	clearEndpointHistory()
+	defer clearEndpointHistory()
+
+	updateEndpointAccessed("x4", bssTypes.EndpointTypePhoneHome, "10.0.0.4", false)
+	req := httptest.NewRequest(http.MethodGet, "/meta-data", nil)
+	req.RemoteAddr = "192.0.2.10:40000"
+	metaDataGetAPI(httptest.NewRecorder(), req)
+
+	accesses, err := searchEndpointHistory(postgres.EndpointAccessFilter{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	expected := map[string]bssTypes.EndpointAccess{
+		"x4":         {Name: "x4", Endpoint: bssTypes.EndpointTypePhoneHome, ClientIP: "10.0.0.4"},
+		"192.0.2.10": {Name: "192.0.2.10", Endpoint: bssTypes.EndpointTypeMetaData, ClientIP: "192.0.2.10", Default: true},
+	}
+	if len(accesses) != len(expected) {
+		t.Fatalf("Expected %d accesses, got %+v", len(expected), accesses)
+	}
+	for _, ea := range accesses {
+		e := expected[ea.Name]
+		e.LastEpoch = ea.LastEpoch
+		if ea != e || ea.LastEpoch == 0 {
+			t.Errorf("Got %+v, expected %+v", ea, e)
+		}
+	}
+
+	// Accesses stored by older versions only hold the timestamp.
+	kvstore.Store(endpointAccessPfx+"/x5/user-data", "1700000000")
+	if ea, err := getEndpointAccess("x5", bssTypes.EndpointTypeUserData); err != nil || ea.LastEpoch != 1700000000 {
+		t.Errorf("Unable to read a timestamp-only access: %+v, %v", ea, err)
+	}
+}
+
+func TestEndpointAccessDefault(t *testing.T) {
+	const xname, ip = "x9102c0s0b0n0", "10.91.0.3"
+	useInventory(t, &SMData{
+		Components: []SMComponent{{Component: base.Component{ID: xname, Type: "Node", Role: "Compute"}}},
+		IPAddrs:    map[string]sm.CompEthInterfaceV2{ip: {CompID: xname}},
+	})
+	clearEndpointHistory()
+	defer clearEndpointHistory()
+
+	getMetaData := func() bssTypes.EndpointAccess {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/meta-data", nil)
+		req.RemoteAddr = ip + ":40000"
+		metaDataGetAPI(httptest.NewRecorder(), req)
+		ea, err := getEndpointAccess(xname, bssTypes.EndpointTypeMetaData)
+		if err != nil {
+			t.Fatalf("Meta-data access not recorded: %v", err)
+		}
+		return ea
+	}
+
+	// A known node without data of its own gets the default data.
+	if ea := getMetaData(); !ea.Default || ea.ClientIP != ip {
+		t.Errorf("Access without node data not recorded as default: %+v", ea)
+	}
+
+	bp := bssTypes.BootParams{Hosts: []string{xname}, CloudInit: bssTypes.CloudInit{
+		MetaData: bssTypes.CloudDataType{"site": "test"},
+	}}
+	if err, _ := Store(bp); err != nil {
+		t.Fatalf("Unable to store %s: %v", xname, err)
+	}
+	defer Remove(bp)
+	if ea := getMetaData(); ea.Default {
+		t.Errorf("Access with node data recorded as default: %+v", ea)
+	}
+}
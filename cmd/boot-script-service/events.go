@@ -0,0 +1,307 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Event stream.  Boot parameter changes, boot script requests, phone-homes,
+// and HSM state refreshes are kept in a bounded in-memory log and streamed
+// to clients of /boot/v1/events as Server-Sent Events.  A client that
+// reconnects with the Last-Event-ID header gets the events it missed, as
+// long as they are still in the log.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+const (
+	eventsRoute = baseEndpoint + "/events"
+
+	eventTypeBootParameters = "bootparameters"
+	eventTypeBootscript     = "bootscript"
+	eventTypePhoneHome      = "phone-home"
+	eventTypeHSMRefresh     = "hsm-refresh"
+)
+
+var eventTypes = []string{eventTypeBootParameters, eventTypeBootscript, eventTypePhoneHome, eventTypeHSMRefresh}
+
+var (
+	eventLogSize = uint(1000) // Events kept for clients resuming the event stream, 0 to disable it
+
+	eventHeartbeat = 30 * time.Second // Interval of keep-alive comments on idle streams
+
+	events *eventLog
+)
+
+// streamEvent is one entry of the event log.  nodes and groups are what the
+// event concerns, for filtering.
+type streamEvent struct {
+	id     uint64
+	typ    string
+	nodes  []string
+	groups []string
+	data   []byte
+}
+
+// eventLog keeps the latest events, oldest first.  wake is closed and
+// replaced whenever an event is added, so that streams waiting on it wake up.
+// followers counts the open streams by event type, "" for streams of every
+// type.
+type eventLog struct {
+	mu        sync.Mutex
+	size      int
+	events    []streamEvent
+	last      uint64
+	wake      chan struct{}
+	done      chan struct{}
+	closed    bool
+	followers map[string]int
+}
+
+// newEventLog returns a log of the given size.  Event IDs start from the
+// current time in microseconds, so that they keep increasing across restarts
+// and clients do not resume from an ID that means something else.
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		size:      size,
+		last:      uint64(time.Now().UnixMicro()),
+		wake:      make(chan struct{}),
+		done:      make(chan struct{}),
+		followers: make(map[string]int),
+	}
+}
+
+func initEvents() {
+	if eventLogSize == 0 {
+		return
+	}
+	events = newEventLog(int(eventLogSize))
+	log.Printf("Keeping the last %d events for %s", eventLogSize, eventsRoute)
+}
+
+func (l *eventLog) add(typ string, nodes, groups []string, data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last++
+	if len(l.events) >= l.size {
+		l.events = l.events[len(l.events)-l.size+1:]
+	}
+	l.events = append(l.events, streamEvent{id: l.last, typ: typ, nodes: nodes, groups: groups, data: data})
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// since returns the events after the one with ID id, and a channel that is
+// closed when there are more.
+func (l *eventLog) since(id uint64) ([]streamEvent, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := sort.Search(len(l.events), func(i int) bool { return l.events[i].id > id })
+	return append([]streamEvent(nil), l.events[i:]...), l.wake
+}
+
+func (l *eventLog) lastID() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// follow records a stream of the given event types, all of them if types is
+// empty, and returns the function that forgets it.
+func (l *eventLog) follow(types map[string]bool) func() {
+	keys := []string{""}
+	if len(types) > 0 {
+		keys = keys[:0]
+		for t := range types {
+			keys = append(keys, t)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		l.followers[k]++
+	}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for _, k := range keys {
+			l.followers[k]--
+		}
+	}
+}
+
+// following tells whether a stream wants events of type typ.
+func (l *eventLog) following(typ string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.followers[""] > 0 || l.followers[typ] > 0
+}
+
+// close ends every stream.  It is called on shutdown, as open streams would
+// otherwise keep the server from draining.
+func (l *eventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.done)
+	}
+}
+
+// publishEvent adds an event of the given type about the given nodes and
+// groups to the event log, if it is enabled.  v is sent as JSON.
+func publishEvent(typ string, nodes, groups []string, v interface{}) {
+	l := events
+	if l == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("WARNING: failed to encode %s event: %v", typ, err)
+		return
+	}
+	l.add(typ, nodes, groups, data)
+}
+
+// compGroups returns the boot group of the named node, i.e. its role, if it
+// is in the inventory.
+func compGroups(name string) []string {
+	if c, ok := FindSMCompByName(name); ok && c.Role != "" {
+		return []string{c.Role}
+	}
+	return nil
+}
+
+// eventFilter selects events by type and by the nodes and groups they
+// concern.  An empty set matches everything.
+type eventFilter struct {
+	types, nodes, groups map[string]bool
+}
+
+func csvSet(values []string) map[string]bool {
+	var set map[string]bool
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				if set == nil {
+					set = make(map[string]bool)
+				}
+				set[s] = true
+			}
+		}
+	}
+	return set
+}
+
+func parseEventFilter(r *http.Request) (f eventFilter, err error) {
+	q := r.URL.Query()
+	f.types, f.nodes, f.groups = csvSet(q["type"]), csvSet(q["node"]), csvSet(q["group"])
+	for t := range f.types {
+		known := false
+		for _, et := range eventTypes {
+			known = known || t == et
+		}
+		if !known {
+			return f, fmt.Errorf("Invalid event type %q, expected one of %s", t, strings.Join(eventTypes, ", "))
+		}
+	}
+	return f, nil
+}
+
+func anyIn(set map[string]bool, values []string) bool {
+	for _, v := range values {
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
+
+func (f eventFilter) matches(e streamEvent) bool {
+	return (f.types == nil || f.types[e.typ]) &&
+		(f.nodes == nil || anyIn(f.nodes, e.nodes)) &&
+		(f.groups == nil || anyIn(f.groups, e.groups))
+}
+
+func eventsGetAPI(w http.ResponseWriter, r *http.Request) {
+	l := events
+	if l == nil {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, "The event stream is not enabled")
+		return
+	}
+	f, err := parseEventFilter(r)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Without Last-Event-ID the stream starts with the next event.
+	last := l.lastID()
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if last, err = strconv.ParseUint(v, 10, 64); err != nil {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Invalid Last-Event-ID %q", v))
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	defer l.follow(f.types)()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		evs, wake := l.since(last)
+		for _, e := range evs {
+			last = e.id
+			if f.matches(e) {
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.typ, e.data)
+			}
+		}
+		flusher.Flush()
+		select {
+		case <-wake:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-l.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+// sse is an event read from an event stream.
+type sse struct {
+	id, event, data string
+}
+
+// useEvents enables the event stream with a log of the given size.
+func useEvents(t *testing.T, size int) *eventLog {
+	saved := events
+	l := newEventLog(size)
+	events = l
+	t.Cleanup(func() {
+		backgroundTasks.Wait()
+		l.close()
+		events = saved
+	})
+	return l
+}
+
+// openStream connects to the event stream of server and returns a channel
+// of the events received, which is closed when the stream ends.
+func openStream(t *testing.T, server *httptest.Server, query, lastID string) <-chan sse {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+eventsRoute+query, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unable to open event stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("Event stream returned %d, %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	ch := make(chan sse, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		var ev sse
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev.id != "" {
+					ch <- ev
+				}
+				ev = sse{}
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return ch
+}
+
+func nextEvent(t *testing.T, ch <-chan sse) sse {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatalf("Event stream ended")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatalf("No event received")
+	}
+	return sse{}
+}
+
+func TestEventLog(t *testing.T) {
+	l := newEventLog(3)
+	for i := 0; i < 5; i++ {
+		l.add(eventTypeBootscript, nil, nil, []byte(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+	evs, _ := l.since(0)
+	if len(evs) != 3 || string(evs[0].data) != `{"n":2}` || evs[2].id != l.lastID() {
+		t.Fatalf("Expected the last 3 events, got %+v", evs)
+	}
+	if evs, _ = l.since(evs[1].id); len(evs) != 1 || string(evs[0].data) != `{"n":4}` {
+		t.Errorf("Expected the last event, got %+v", evs)
+	}
+	if evs, _ = l.since(l.lastID()); len(evs) != 0 {
+		t.Errorf("Expected no events, got %+v", evs)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	l := useEvents(t, 10)
+	server := httptest.NewServer(initHandlers())
+	defer server.Close()
+
+	publishEvent(eventTypeBootscript, []string{"x0c0s1b0n0"}, nil, map[string]string{"before": "connect"})
+	ch := openStream(t, server, "?type=bootscript,phone-home&node=x0c0s1b0n0", "")
+	publishEvent(eventTypeBootscript, []string{"x0c0s2b0n0"}, nil, map[string]string{})
+	publishEvent(eventTypeHSMRefresh, nil, nil, bssTypes.StateRefreshEvent{Full: true})
+	BootscriptGet(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?mac=00:1e:67:e3:46:51", nil))
+
+	first := nextEvent(t, ch)
+	var ev bssTypes.BootscriptEvent
+	if err := json.Unmarshal([]byte(first.data), &ev); err != nil || first.event != eventTypeBootscript || ev.Xname != "x0c0s1b0n0" {
+		t.Fatalf("Unexpected event %+v", first)
+	}
+
+	// Resuming from before the boot script request gets it again, as well
+	// as anything after it.
+	id, _ := strconv.ParseUint(first.id, 10, 64)
+	resumed := openStream(t, server, "?node=x0c0s1b0n0", strconv.FormatUint(id-1, 10))
+	if ev := nextEvent(t, resumed); ev.id != first.id {
+		t.Errorf("Resumed stream started with %+v, expected %s", ev, first.id)
+	}
+	publishEvent(eventTypePhoneHome, []string{"x0c0s1b0n0"}, nil, bssTypes.PhoneHomeEvent{Xname: "x0c0s1b0n0"})
+	for _, c := range []<-chan sse{ch, resumed} {
+		if ev := nextEvent(t, c); ev.event != eventTypePhoneHome {
+			t.Errorf("Expected a phone-home event, got %+v", ev)
+		}
+	}
+
+	// Closing the log, as shutdown does, ends the streams.
+	l.close()
+	for _, c := range []<-chan sse{ch, resumed} {
+		select {
+		case ev, ok := <-c:
+			if ok {
+				t.Errorf("Unexpected event %+v", ev)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("Stream did not end")
+		}
+	}
+}
+
+func TestEventsBootParameters(t *testing.T) {
+	l := useEvents(t, 10)
+	last := l.lastID()
+	put := func(params string) {
+		body := `{"hosts":["x0c0s1b0n0"],"params":"` + params + `"}`
+		if rr := webhookRequest(t, bootParameters, http.MethodPut, "/boot/v1/bootparameters", body); rr.Code != http.StatusOK {
+			t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	defer Remove(bssTypes.BootParams{Hosts: []string{"x0c0s1b0n0"}})
+
+	// Changes are only logged while a stream follows them.
+	put("events=0")
+	unfollow := l.follow(map[string]bool{eventTypeBootscript: true})
+	put("events=0.5")
+	unfollow()
+	if evs, _ := l.since(last); len(evs) != 0 {
+		t.Fatalf("Unfollowed changes were logged: %+v", evs)
+	}
+	defer l.follow(nil)()
+	put("events=1")
+
+	evs, _ := l.since(last)
+	if len(evs) != 1 {
+		t.Fatalf("Expected 1 event, got %+v", evs)
+	}
+	e := evs[0]
+	f := eventFilter{groups: map[string]bool{"Management": true}}
+	var ce bssTypes.ConfigEvent
+	if err := json.Unmarshal(e.data, &ce); err != nil || e.typ != eventTypeBootParameters || !f.matches(e) ||
+		ce.Name != "x0c0s1b0n0" || ce.After == nil || ce.After.Params != "events=1" {
+		t.Errorf("Unexpected event %+v: %s", e, e.data)
+	}
+}
+
+func TestEventsBadRequests(t *testing.T) {
+	get := func(query, lastID string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, eventsRoute+query, nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		eventsGet(rr, req)
+		return rr.Code
+	}
+	saved := events
+	events = nil
+	code := get("", "")
+	events = saved
+	if code != http.StatusNotFound {
+		t.Errorf("GET returned %d with the event stream disabled", code)
+	}
+
+	useEvents(t, 10)
+	if code := get("?type=bogus", ""); code != http.StatusBadRequest {
+		t.Errorf("GET with an unknown type returned %d", code)
+	}
+	if code := get("", "abc"); code != http.StatusBadRequest {
+		t.Errorf("GET with a bad Last-Event-ID returned %d", code)
+	}
+}
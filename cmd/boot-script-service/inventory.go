@@ -0,0 +1,452 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Node inventory providers.  BSS loads a complete snapshot of the nodes it
+// boots from the configured provider, and asks the provider to find nodes by
+// MAC address, NID, IP address, xname, FQDN, or role.  The built-in providers
+// answer these lookups from an index of the snapshot.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/internal/postgres"
+	"github.com/OpenCHAMI/smd/v2/pkg/sm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	inventorySourceHSM      = "hsm"
+	inventorySourceJSON     = "json"
+	inventorySourceHosts    = "hosts"
+	inventorySourcePostgres = "postgres"
+
+	defaultInventoryRole = "Compute"
+	defaultInventoryArch = "X86"
+)
+
+var (
+	inventorySource = inventorySourceHSM // Where node inventory comes from: hsm, json, hosts, or postgres
+	inventoryFile   = ""                 // Inventory file for the json and hosts sources
+
+	inventory InventoryProvider
+)
+
+// InventoryProvider is a source of node inventory.
+type InventoryProvider interface {
+	// Name identifies the provider in logs.
+	Name() string
+	// Components returns every node, with its MAC addresses, NID, FQDN,
+	// role, and subrole filled in where known, together with the IP
+	// addresses assigned to the nodes.
+	Components(ctx context.Context) (*SMData, error)
+	InventoryLookups
+}
+
+// InventoryLookups finds nodes in the inventory.  Where several nodes match,
+// they are returned in inventory order.
+type InventoryLookups interface {
+	// ByMAC returns the nodes with a MAC address in any common format.
+	ByMAC(mac string) []SMComponent
+	ByNID(nid int64) []SMComponent
+	ByName(name string) []SMComponent
+	ByFQDN(fqdn string) []SMComponent
+	// ByIP returns the name of the node with an IP address.
+	ByIP(ip string) (string, bool)
+	// ByRole returns the nodes with a role and, unless it is empty, a
+	// subrole.
+	ByRole(role, subRole string) []SMComponent
+}
+
+// componentStateFetcher is implemented by inventory providers that can
+// return the current state of some nodes, without their addresses, so that
+// state change notifications can be applied without a full fetch.
+type componentStateFetcher interface {
+	ComponentStates(ctx context.Context, ids []string) ([]base.Component, error)
+}
+
+// snapshotLookups answers lookups from the index of the current inventory
+// snapshot, loading it first if necessary.
+type snapshotLookups struct{}
+
+func (snapshotLookups) ByMAC(mac string) []SMComponent {
+	return getIndex().byMAC[normalizeMAC(mac)]
+}
+
+func (snapshotLookups) ByNID(nid int64) []SMComponent {
+	return getIndex().byNID[nid]
+}
+
+func (snapshotLookups) ByName(name string) []SMComponent {
+	return getIndex().byID[name]
+}
+
+func (snapshotLookups) ByFQDN(fqdn string) []SMComponent {
+	return getIndex().byFQDN[strings.ToLower(fqdn)]
+}
+
+// ByIP refreshes a snapshot older than ipCacheEvictionTime before the
+// lookup, and an older one if the address is not found, since addresses may
+// change with DHCP leases.
+func (snapshotLookups) ByIP(ip string) (string, bool) {
+	ip = normalizeIP(ip)
+	ts := time.Now().Add(-ipCacheEvictionTime)
+	_, idx := protectedGetState(ts.Unix())
+
+	xname, found := "", false
+	if idx != nil {
+		xname, found = idx.byIP[ip]
+	}
+	if !found {
+		// If we didn't find the IP, try again with a current timestamp
+		// to force getting new state from HSM. In case the hardware came up
+		// within the last cache eviction period.
+		_, idx = protectedGetState(time.Now().Unix())
+		if idx != nil {
+			xname, found = idx.byIP[ip]
+		}
+	}
+	return xname, found
+}
+
+func (snapshotLookups) ByRole(role, subRole string) []SMComponent {
+	comps := getIndex().byRole[strings.ToLower(role)]
+	if subRole == "" {
+		return comps
+	}
+	var ret []SMComponent
+	for _, c := range comps {
+		if strings.EqualFold(c.SubRole, subRole) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// nodeLookups returns the lookups of the configured inventory provider.
+func nodeLookups() InventoryLookups {
+	if inventory == nil {
+		return snapshotLookups{}
+	}
+	return inventory
+}
+
+// hsmInventory reads nodes from the Hardware State Manager.
+type hsmInventory struct {
+	snapshotLookups
+}
+
+func (hsmInventory) Name() string { return "HSM at " + smBaseURL }
+
+func (hsmInventory) Components(ctx context.Context) (*SMData, error) {
+	if data := getStateFromHSM(ctx); data != nil {
+		return data, nil
+	}
+	return nil, fmt.Errorf("unable to retrieve state from %s", smBaseURL)
+}
+
+func (hsmInventory) ComponentStates(ctx context.Context, ids []string) ([]base.Component, error) {
+	return getComponentStatesFromHSM(ctx, ids)
+}
+
+// jsonInventory reads nodes from a file in the format HSM state is cached
+// in, i.e. an SMData object.
+type jsonInventory struct {
+	snapshotLookups
+	path string
+}
+
+func (ji jsonInventory) Name() string { return ji.path }
+
+func (ji jsonInventory) Components(ctx context.Context) (*SMData, error) {
+	data, err := os.ReadFile(ji.path)
+	if err != nil {
+		return nil, err
+	}
+	var comps SMData
+	if err = json.Unmarshal(data, &comps); err != nil {
+		return nil, fmt.Errorf("%s: %v", ji.path, err)
+	}
+	return &comps, nil
+}
+
+// staticInventory always returns the same nodes.  It backs the mem: HSM URL
+// used for testing.
+type staticInventory struct {
+	snapshotLookups
+	data []byte
+}
+
+func (staticInventory) Name() string { return "built-in test data" }
+
+func (si staticInventory) Components(ctx context.Context) (*SMData, error) {
+	var comps SMData
+	if err := json.Unmarshal(si.data, &comps); err != nil {
+		return nil, err
+	}
+	return &comps, nil
+}
+
+func (si staticInventory) ComponentStates(ctx context.Context, ids []string) ([]base.Component, error) {
+	data, err := si.Components(ctx)
+	if err != nil {
+		return nil, err
+	}
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	var ret []base.Component
+	for _, c := range data.Components {
+		if want[c.ID] {
+			ret = append(ret, c.Component)
+		}
+	}
+	return ret, nil
+}
+
+// inventoryHost is one entry of a host list.
+type inventoryHost struct {
+	Name    string   `yaml:"name"`
+	NID     *int64   `yaml:"nid"`
+	MACs    []string `yaml:"macs"`
+	IPs     []string `yaml:"ips"`
+	FQDN    string   `yaml:"fqdn"`
+	Role    string   `yaml:"role"`
+	SubRole string   `yaml:"subrole"`
+	Arch    string   `yaml:"arch"`
+}
+
+// hostListInventory reads nodes from a simple host list, which is either a
+// YAML list of hosts or, if the file name ends in .csv, a CSV file with a
+// header row naming the columns.  In CSV files, multiple MAC or IP addresses
+// are separated by spaces or semicolons.
+type hostListInventory struct {
+	snapshotLookups
+	path string
+}
+
+func (hi hostListInventory) Name() string { return hi.path }
+
+func (hi hostListInventory) Components(ctx context.Context) (*SMData, error) {
+	data, err := os.ReadFile(hi.path)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []inventoryHost
+	if strings.EqualFold(filepath.Ext(hi.path), ".csv") {
+		hosts, err = parseHostCSV(data)
+	} else {
+		err = yaml.UnmarshalStrict(data, &hosts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", hi.path, err)
+	}
+	return hostsToSMData(hosts)
+}
+
+func parseHostCSV(data []byte) ([]inventoryHost, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header row: %v", err)
+	}
+	for i, col := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(col))
+		switch header[i] {
+		case "name", "nid", "macs", "ips", "fqdn", "role", "subrole", "arch":
+		default:
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+	}
+	list := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ' ' })
+	}
+	var hosts []inventoryHost
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var h inventoryHost
+		for i, v := range rec {
+			v = strings.TrimSpace(v)
+			switch header[i] {
+			case "name":
+				h.Name = v
+			case "nid":
+				if v == "" {
+					continue
+				}
+				nid, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("host %q: invalid NID %q", h.Name, v)
+				}
+				h.NID = &nid
+			case "macs":
+				h.MACs = list(v)
+			case "ips":
+				h.IPs = list(v)
+			case "fqdn":
+				h.FQDN = v
+			case "role":
+				h.Role = v
+			case "subrole":
+				h.SubRole = v
+			case "arch":
+				h.Arch = v
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// hostsToSMData converts a host list into nodes that are enabled and ready,
+// with the Compute role and X86 architecture unless the host says otherwise.
+func hostsToSMData(hosts []inventoryHost) (*SMData, error) {
+	data := &SMData{IPAddrs: make(map[string]sm.CompEthInterfaceV2)}
+	seen := make(map[string]bool, len(hosts))
+	for i, h := range hosts {
+		if h.Name == "" {
+			return nil, fmt.Errorf("host %d has no name", i+1)
+		}
+		if seen[h.Name] {
+			return nil, fmt.Errorf("host %q is listed more than once", h.Name)
+		}
+		seen[h.Name] = true
+		enabled := true
+		c := SMComponent{
+			Component: base.Component{
+				ID:      h.Name,
+				Type:    "Node",
+				State:   "Ready",
+				Flag:    "OK",
+				Enabled: &enabled,
+				Role:    h.Role,
+				SubRole: h.SubRole,
+				Arch:    h.Arch,
+			},
+			Fqdn:            h.FQDN,
+			EndpointEnabled: true,
+		}
+		if c.Role == "" {
+			c.Role = defaultInventoryRole
+		}
+		if c.Arch == "" {
+			c.Arch = defaultInventoryArch
+		}
+		if h.NID != nil {
+			c.NID = json.Number(strconv.FormatInt(*h.NID, 10))
+		}
+		for _, m := range h.MACs {
+			mac := ensureLegalMAC(m)
+			if mac == badMAC {
+				return nil, fmt.Errorf("host %q: invalid MAC address %q", h.Name, m)
+			}
+			c.Mac = append(c.Mac, mac)
+		}
+		for _, ip := range h.IPs {
+			e := sm.CompEthInterfaceV2{
+				CompID:  h.Name,
+				Type:    "Node",
+				IPAddrs: []sm.IPAddressMapping{{IPAddr: ip}},
+			}
+			if len(c.Mac) > 0 {
+				e.MACAddr = c.Mac[0]
+			}
+			data.IPAddrs[ip] = e
+		}
+		data.Components = append(data.Components, c)
+	}
+	return data, nil
+}
+
+// postgresInventory reads nodes from the nodes table that boot parameters
+// are assigned to.  The table only holds a boot MAC address, xname, and NID
+// for each node, so all nodes get the default role and architecture.
+type postgresInventory struct {
+	snapshotLookups
+	db postgres.BootDataDatabase
+}
+
+func (postgresInventory) Name() string { return "Postgres nodes table" }
+
+func (pi postgresInventory) Components(ctx context.Context) (*SMData, error) {
+	nodes, err := pi.db.GetNodes()
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]inventoryHost, 0, len(nodes))
+	for _, n := range nodes {
+		h := inventoryHost{Name: n.Xname}
+		if h.Name == "" {
+			h.Name = n.Id
+		}
+		if n.BootMac != "" {
+			h.MACs = []string{n.BootMac}
+		}
+		if n.Nid > 0 {
+			nid := int64(n.Nid)
+			h.NID = &nid
+		}
+		hosts = append(hosts, h)
+	}
+	return hostsToSMData(hosts)
+}
+
+// initInventory selects the inventory provider.  The hsm source is set up by
+// SmOpen, since the HSM URL may also name a JSON file or the built-in test
+// data.  The datastore must already be open.
+func initInventory(svcOpts string) error {
+	switch strings.ToLower(inventorySource) {
+	case inventorySourceJSON:
+		inventory = jsonInventory{path: inventoryFile}
+	case inventorySourceHosts:
+		inventory = hostListInventory{path: inventoryFile}
+	case inventorySourcePostgres:
+		inventory = postgresInventory{db: bssdb}
+	default:
+		if err := SmOpen(hsmBase, svcOpts); err != nil {
+			return fmt.Errorf("Access to SM service %s failed: %v", hsmBase, err)
+		}
+	}
+	log.Printf("Using node inventory from %s", inventory.Name())
+	return nil
+}
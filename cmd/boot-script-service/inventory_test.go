@@ -0,0 +1,164 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+func writeInventoryFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHostListInventory(t *testing.T) {
+	files := map[string]string{
+		"hosts.yaml": `
+- name: nid001
+  nid: 1
+  macs: ["00-1E-67-E3-46-93"]
+  ips: ["10.0.0.1"]
+  fqdn: nid001.cluster
+- name: login01
+  macs: ["00:1e:67:e3:46:94", "00:1e:67:e3:46:95"]
+  role: Application
+  subrole: UAN
+  arch: ARM
+`,
+		"hosts.csv": `# Cluster nodes
+name,nid,macs,ips,fqdn,role,subrole,arch
+nid001,1,00-1E-67-E3-46-93,10.0.0.1,nid001.cluster,,,
+login01,,00:1e:67:e3:46:94;00:1e:67:e3:46:95,,,Application,UAN,ARM
+`,
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			inv := hostListInventory{path: writeInventoryFile(t, name, data)}
+			state, err := inv.Components(context.Background())
+			if err != nil {
+				t.Fatalf("Components() failed: %v", err)
+			}
+			if len(state.Components) != 2 {
+				t.Fatalf("Expected 2 components, got %d", len(state.Components))
+			}
+			idx := makeSmIndex(state)
+			c := idx.byNID[1]
+			if len(c) != 1 || c[0].ID != "nid001" || c[0].Role != "Compute" || c[0].Arch != "X86" ||
+				c[0].Enabled == nil || !*c[0].Enabled || c[0].State != "Ready" {
+				t.Errorf("Unexpected component for NID 1: %+v", c)
+			}
+			if c := idx.byMAC["00:1e:67:e3:46:93"]; len(c) != 1 || c[0].ID != "nid001" {
+				t.Errorf("MAC lookup failed: %+v", c)
+			}
+			if c := idx.byFQDN["nid001.cluster"]; len(c) != 1 {
+				t.Errorf("FQDN lookup failed: %+v", c)
+			}
+			if id := idx.byIP["10.0.0.1"]; id != "nid001" {
+				t.Errorf("IP lookup returned %q", id)
+			}
+			c = idx.byID["login01"]
+			if len(c) != 1 || c[0].Role != "Application" || c[0].SubRole != "UAN" || c[0].Arch != "ARM" ||
+				len(c[0].Mac) != 2 || c[0].NID != "" {
+				t.Errorf("Unexpected component for login01: %+v", c)
+			}
+		})
+	}
+}
+
+func TestHostListInventoryErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing-name.yaml":  "- nid: 1\n",
+		"duplicate.yaml":     "- name: a\n- name: a\n",
+		"bad-mac.yaml":       "- name: a\n  macs: [bogus]\n",
+		"unknown-field.yaml": "- name: a\n  rack: 4\n",
+		"bad-nid.csv":        "name,nid\na,one\n",
+		"unknown-column.csv": "name,rack\na,4\n",
+		"no-header.csv":      "",
+	}
+	for name, data := range tests {
+		inv := hostListInventory{path: writeInventoryFile(t, name, data)}
+		if _, err := inv.Components(context.Background()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestJSONInventory(t *testing.T) {
+	inv := jsonInventory{path: writeInventoryFile(t, "state.json", state_manager_data_temp)}
+	state, err := inv.Components(context.Background())
+	if err != nil {
+		t.Fatalf("Components() failed: %v", err)
+	}
+	if len(state.Components) == 0 {
+		t.Errorf("Expected components")
+	}
+	if _, err = (jsonInventory{path: writeInventoryFile(t, "bad.json", "[")}).Components(context.Background()); err == nil {
+		t.Errorf("Expected an error for malformed JSON")
+	}
+}
+
+// macInventory finds one node by MAC address itself.
+type macInventory struct {
+	staticInventory
+}
+
+func (macInventory) ByMAC(mac string) []SMComponent {
+	if normalizeMAC(mac) == "02:00:00:00:00:aa" {
+		return []SMComponent{{Component: base.Component{ID: "x9000c0s1b0n0"}}}
+	}
+	return nil
+}
+
+func TestInventoryLookups(t *testing.T) {
+	comps := FindSMCompsByRole("system", "")
+	if len(comps) < 2 {
+		t.Errorf("Expected System nodes, got %+v", comps)
+	}
+	for _, c := range comps {
+		if c.Role != "System" {
+			t.Errorf("Node %s with role %q found by role System", c.ID, c.Role)
+		}
+	}
+	if comps = FindSMCompsByRole("System", "UAN"); len(comps) != 0 {
+		t.Errorf("Expected no System UAN nodes, got %+v", comps)
+	}
+
+	saved := inventory
+	defer func() { inventory = saved }()
+	inventory = macInventory{staticInventory{data: []byte(state_manager_data_temp)}}
+	if c, ok := FindSMCompByMAC("02-00-00-00-00-AA"); !ok || c.ID != "x9000c0s1b0n0" {
+		t.Errorf("Lookup by MAC was not answered by the provider: %+v", c)
+	}
+	if c, ok := FindSMCompByName("x0c0s1b0n0"); !ok || c.ID != "x0c0s1b0n0" {
+		t.Errorf("Lookup by name was not answered from the snapshot: %+v", c)
+	}
+}
@@ -34,6 +34,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -44,6 +45,8 @@ import (
 	"strings"
 
 	base "github.com/Cray-HPE/hms-base"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type spireRespType struct {
@@ -63,6 +66,7 @@ func spireTokenServiceInit(urlBase, opts string) error {
 	if err != nil {
 		return fmt.Errorf("URL parse error %s, URL: %s", err, urlBase)
 	}
+	spireTokensBaseURL = urlBase
 	https := u.Scheme == "https"
 	insecure := false
 	for _, opt := range strings.Split(opts, ",") {
@@ -80,10 +84,14 @@ func spireTokenServiceInit(urlBase, opts string) error {
 		spireTokenClient.Transport = trans
 		log.Printf("WARNING: insecure https connection to spire token service\n")
 	}
+	spireTokenClient.Transport = tracedTransport(spireTokenClient.Transport)
 	return nil
 }
 
-func getJoinToken(xname, role, subRole string) (string, error) {
+func getJoinToken(ctx context.Context, xname, role, subRole string) (token string, err error) {
+	ctx, span := tracer.Start(ctx, "spire.getJoinToken", trace.WithAttributes(attribute.String("bss.xname", xname)))
+	defer func() { endSpan(span, err) }()
+
 	spireType := ""
 	if strings.EqualFold(role, "Compute") {
 		spireType = "type=compute&"
@@ -93,7 +101,7 @@ func getJoinToken(xname, role, subRole string) (string, error) {
 	debugf("Get Join Token: xname: %s, role: %s, subRole: %s, spireType: '%s'", xname, role, subRole, spireType)
 
 	url := spireTokensBaseURL + "/api/token"
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(spireType+"xname="+xname)))
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer([]byte(spireType+"xname="+xname)))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	base.SetHTTPUserAgent(req, serviceName)
 	req.Close = true
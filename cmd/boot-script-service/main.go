@@ -39,6 +39,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -46,6 +47,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	hmetcd "github.com/Cray-HPE/hms-hmetcd"
@@ -121,6 +123,8 @@ func parseEnv(evar string, v interface{}) (ret error) {
 			if ret == nil {
 				*vp = uint(temp)
 			}
+		case *uint64:
+			*vp, ret = strconv.ParseUint(val, 0, 64)
 		case *string:
 			*vp = val
 		case *bool:
@@ -142,7 +146,10 @@ func parseEnv(evar string, v interface{}) (ret error) {
 }
 
 func debugf(format string, v ...interface{}) {
-	if debugFlag {
+	configMutex.RLock()
+	debug := debugFlag
+	configMutex.RUnlock()
+	if debug {
 		log.Printf("DEBUG: "+format, v...)
 	}
 }
@@ -188,6 +195,10 @@ func kvOpen(url, opts string, retryCount, retryWait uint64) (err error) {
 		if err != nil {
 			log.Println("ERROR opening connection to ETCD (attempt ", ix, "):", err)
 		} else {
+			kvstore = metricsKvi{kvstore}
+			if strings.HasPrefix(url, "mem:") {
+				kvstore = &memKvi{Kvi: kvstore}
+			}
 			break
 		}
 
@@ -201,6 +212,49 @@ func kvOpen(url, opts string, retryCount, retryWait uint64) (err error) {
 	return err
 }
 
+// memKvi locks the in-memory key-value store, which hmetcd does not lock for
+// range reads, against concurrent writes.
+type memKvi struct {
+	hmetcd.Kvi
+	mu sync.RWMutex
+}
+
+func (m *memKvi) GetRange(keystart, keyend string) ([]hmetcd.Kvi_KV, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Kvi.GetRange(keystart, keyend)
+}
+
+func (m *memKvi) Store(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Kvi.Store(key, value)
+}
+
+func (m *memKvi) TempKey(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Kvi.TempKey(key)
+}
+
+func (m *memKvi) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Kvi.Delete(key)
+}
+
+func (m *memKvi) Transaction(key, op, value, thenkey, thenval, elsekey, elseval string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Kvi.Transaction(key, op, value, thenkey, thenval, elsekey, elseval)
+}
+
+func (m *memKvi) TAS(key, testval, setval string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Kvi.TAS(key, testval, setval)
+}
+
 func sqlOpen(host string, port uint, user, password, extraDbOpts string, ssl bool, retryCount, retryWait uint64) (postgres.BootDataDatabase, error) {
 	var (
 		err  error
@@ -230,7 +284,7 @@ func sqlOpen(host string, port uint, user, password, extraDbOpts string, ssl boo
 func sqlClose() {
 	err := bssdb.Close()
 	if err != nil {
-		log.Fatalf("Error attempting tp close connection to Postgres: %v", err)
+		log.Printf("WARNING: failed to close connection to Postgres: %v", err)
 	}
 }
 
@@ -276,6 +330,138 @@ func parseEnvVars() error {
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("BSS_HTTP_LISTEN: %q", parseErr))
 	}
+	parseErr = parseEnv("BSS_HTTP_PLAIN_LISTEN", &httpPlainListen)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_HTTP_PLAIN_LISTEN: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_TLS_CERT_FILE", &tlsCertFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_TLS_CERT_FILE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_TLS_KEY_FILE", &tlsKeyFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_TLS_KEY_FILE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_TLS_CLIENT_CA_FILE", &tlsClientCAFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_TLS_CLIENT_CA_FILE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_TRACING_EXPORTER", &tracingExporter)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_TRACING_EXPORTER: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_TRACING_FILE", &tracingFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_TRACING_FILE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_READY_CHECKS", &readyChecks)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_READY_CHECKS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_SHUTDOWN_DELAY", &shutdownDelay)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_SHUTDOWN_DELAY: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_SHUTDOWN_TIMEOUT", &shutdownTimeout)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_SHUTDOWN_TIMEOUT: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_MAX_CONCURRENT", &bootscriptMaxConcurrent)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_MAX_CONCURRENT: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_ROLE_LIMITS", &bootscriptRoleLimits)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_ROLE_LIMITS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_THROTTLE_DELAY", &throttleDelay)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_THROTTLE_DELAY: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_CACHE_TTL", &bootscriptCacheTTL)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_CACHE_TTL: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_CACHE_SIZE", &bootscriptCacheSize)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_CACHE_SIZE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_INVENTORY_SOURCE", &inventorySource)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_INVENTORY_SOURCE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_INVENTORY_FILE", &inventoryFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_INVENTORY_FILE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_DISCOVERY", &discoveryEnabled)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_DISCOVERY: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOT_SESSIONS", &bootSessionsEnabled)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOT_SESSIONS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOT_DEADLINE", &bootDeadline)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOT_DEADLINE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOT_RETRY_LIMIT", &bootRetryLimit)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOT_RETRY_LIMIT: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_RESCUE_RETRIES", &rescueRetries)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_RESCUE_RETRIES: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_RESCUE_NODE_RETRIES", &rescueNodeRetries)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_RESCUE_NODE_RETRIES: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_RESCUE_ROLE_RETRIES", &rescueRoleRetries)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_RESCUE_ROLE_RETRIES: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_ENDPOINT_HISTORY", &endpointHistoryMode)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_ENDPOINT_HISTORY: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_ENDPOINT_HISTORY_RETENTION", &endpointHistoryRetention)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_ENDPOINT_HISTORY_RETENTION: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_NOTIFY_URLS", &bootscriptNotifyURLs)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_NOTIFY_URLS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_NOTIFY_SECRET", &bootscriptNotifySecret)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_NOTIFY_SECRET: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_NOTIFY_QUEUE", &bootscriptNotifyQueue)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_NOTIFY_QUEUE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_BOOTSCRIPT_NOTIFY_MAX_ATTEMPTS", &bootscriptNotifyMaxAttempts)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_NOTIFY_MAX_ATTEMPTS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_WEBHOOKS", &webhooksEnabled)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_WEBHOOKS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_WEBHOOK_QUEUE", &webhookQueue)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_WEBHOOK_QUEUE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_WEBHOOK_MAX_ATTEMPTS", &webhookMaxAttempts)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_WEBHOOK_MAX_ATTEMPTS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_EVENT_LOG_SIZE", &eventLogSize)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_EVENT_LOG_SIZE: %q", parseErr))
+	}
 	parseErr = parseEnv("HSM_URL", &hsmBase)
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("HSM_URL: %q", parseErr))
@@ -296,6 +482,10 @@ func parseEnvVars() error {
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("BSS_HSM_RETRIEVAL_DELAY: %q", parseErr))
 	}
+	parseErr = parseEnv("BSS_HSM_RESYNC_INTERVAL", &hsmResyncInterval)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_HSM_RESYNC_INTERVAL: %q", parseErr))
+	}
 	parseErr = parseEnv("SPIRE_TOKEN_URL", &spireServiceURL)
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("SPIRE_TOKEN_URL: %q", parseErr))
@@ -316,6 +506,38 @@ func parseEnvVars() error {
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("BSS_JWKS_URL: %q", parseErr))
 	}
+	parseErr = parseEnv("BSS_AUTH_SCOPES_ENABLED", &authScopesEnabled)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_AUTH_SCOPES_ENABLED: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_AUTH_PROTECT_READS", &authProtectReads)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_AUTH_PROTECT_READS: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_AUTH_SCOPE_CLAIM", &authScopeClaim)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_AUTH_SCOPE_CLAIM: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_AUTH_READ_SCOPE", &authReadScope)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_AUTH_READ_SCOPE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_AUTH_WRITE_SCOPE", &authWriteScope)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_AUTH_WRITE_SCOPE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_AUTH_ADMIN_SCOPE", &authAdminScope)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_AUTH_ADMIN_SCOPE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_API_KEYS_FILE", &apiKeysFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_API_KEYS_FILE: %q", parseErr))
+	}
+	parseErr = parseEnv("BSS_CLIENT_CERT_ROLES_FILE", &clientCertRolesFile)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_CLIENT_CERT_ROLES_FILE: %q", parseErr))
+	}
 	parseErr = parseEnv("BSS_OAUTH2_ADMIN_BASE_URL", &oauth2AdminBaseURL)
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("BSS_OAUTH2_ADMIN_BASE_URL: %q", parseErr))
@@ -328,6 +550,14 @@ func parseEnvVars() error {
 	if parseErr != nil {
 		errList = append(errList, fmt.Errorf("BSS_BOOTSCRIPT_NOTIFY_URL: %q", parseErr))
 	}
+	parseErr = parseEnv("BSS_BLOCKED_ROLES", &blockedRoles)
+	if parseErr != nil {
+		errList = append(errList, fmt.Errorf("BSS_BLOCKED_ROLES: %q", parseErr))
+	}
+	// These may be set to an empty string.
+	ipxeServer = getEnvVal("BSS_IPXE_SERVER", ipxeServer)
+	chainProto = getEnvVal("BSS_CHAIN_PROTO", chainProto)
+	gwURI = getEnvVal("BSS_GW_URI", gwURI)
 
 	//
 	// Etcd environment variables
@@ -410,43 +640,133 @@ func parseEnvVars() error {
 	return err
 }
 
+// defineFlags defines the command line flags on fs.  Each setting of the
+// configuration file has a flag of the same name.
+func defineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&httpListen, "http-listen", httpListen, "(BSS_HTTP_LISTEN) HTTP server IP + port binding")
+	fs.StringVar(&httpPlainListen, "http-plain-listen", httpPlainListen, "(BSS_HTTP_PLAIN_LISTEN) Plain HTTP IP + port binding for boot script and cloud-init requests when serving HTTPS")
+	fs.StringVar(&tlsCertFile, "tls-cert", tlsCertFile, "(BSS_TLS_CERT_FILE) PEM certificate file; serve HTTPS on --http-listen when set")
+	fs.StringVar(&tlsKeyFile, "tls-key", tlsKeyFile, "(BSS_TLS_KEY_FILE) PEM private key file for --tls-cert")
+	fs.StringVar(&tlsClientCAFile, "tls-client-ca", tlsClientCAFile, "(BSS_TLS_CLIENT_CA_FILE) PEM CA bundle used to verify TLS client certificates")
+	fs.StringVar(&tracingExporter, "tracing-exporter", tracingExporter, "(BSS_TRACING_EXPORTER) OpenTelemetry trace exporter: none, otlp, stdout, or file")
+	fs.StringVar(&tracingFile, "tracing-file", tracingFile, "(BSS_TRACING_FILE) Output file for the file trace exporter")
+	fs.StringVar(&readyChecks, "ready-checks", readyChecks, "(BSS_READY_CHECKS) Comma separated readiness checks for /readyz: storage, hsm, jwks, or none")
+	fs.StringVar(&bootscriptRoleLimits, "bootscript-role-limits", bootscriptRoleLimits, "(BSS_BOOTSCRIPT_ROLE_LIMITS) Comma separated role=limit caps on concurrent boot script requests, e.g. Compute=500,Application=50")
+	fs.StringVar(&inventorySource, "inventory-source", inventorySource, "(BSS_INVENTORY_SOURCE) Where node inventory comes from: hsm, json, hosts, or postgres")
+	fs.StringVar(&inventoryFile, "inventory-file", inventoryFile, "(BSS_INVENTORY_FILE) JSON inventory or YAML/CSV host list for the json and hosts inventory sources")
+	fs.BoolVar(&discoveryEnabled, "discovery", discoveryEnabled, "(BSS_DISCOVERY) Record unknown nodes that request a boot script so they can be adopted through /boot/v1/discovered")
+	fs.BoolVar(&bootSessionsEnabled, "boot-sessions", bootSessionsEnabled, "(BSS_BOOT_SESSIONS) Track boot sessions linking boot script, user-data, and phone-home requests, see /boot/v1/boot-sessions")
+	fs.StringVar(&rescueNodeRetries, "rescue-node-retries", rescueNodeRetries, "(BSS_RESCUE_NODE_RETRIES) Comma separated xname=retries overrides of rescue-retries and rescue-role-retries, e.g. x3000c0s1b0n0=2")
+	fs.StringVar(&rescueRoleRetries, "rescue-role-retries", rescueRoleRetries, "(BSS_RESCUE_ROLE_RETRIES) Comma separated role=retries overrides of rescue-retries, e.g. Compute=5,Application=10")
+	fs.StringVar(&endpointHistoryMode, "endpoint-history", endpointHistoryMode, "(BSS_ENDPOINT_HISTORY) Endpoint accesses to keep: last for the latest access of each endpoint by each node, or full for every access")
+	fs.StringVar(&bootscriptNotifySecret, "bootscript-notify-secret", bootscriptNotifySecret, "(BSS_BOOTSCRIPT_NOTIFY_SECRET) Key with which boot script notifications are signed using HMAC-SHA256")
+	fs.StringVar(&bootscriptNotifyQueue, "bootscript-notify-queue", bootscriptNotifyQueue, "(BSS_BOOTSCRIPT_NOTIFY_QUEUE) Directory in which undelivered boot script notifications are kept across restarts")
+	fs.BoolVar(&webhooksEnabled, "webhooks", webhooksEnabled, "(BSS_WEBHOOKS) Send boot parameter changes to webhook subscriptions managed through /boot/v1/webhooks")
+	fs.StringVar(&webhookQueue, "webhook-queue", webhookQueue, "(BSS_WEBHOOK_QUEUE) Directory in which undelivered webhook events are kept across restarts")
+	fs.StringVar(&hsmBase, "hsm", hsmBase, "(HSM_URL) Hardware State Manager location as URI, e.g. [scheme]://[host[:port]]")
+	fs.StringVar(&nfdBase, "nfd", nfdBase, "(NFD_URL) Notification daemon location as URI, e.g. [scheme]://[host[:port]]")
+	if datastoreBase == "" {
+		datastoreBase = kvDefaultURL()
+	}
+	fs.StringVar(&configFile, "config", configFile, "(BSS_CONFIG_FILE) YAML configuration file; environment variables and flags take precedence")
+	fs.StringVar(&datastoreBase, "datastore", datastoreBase, "(DATASTORE_BASE) Datastore Service location as URI")
+	fs.StringVar(&sqlHost, "postgres-host", sqlHost, "(BSS_DBHOST) Postgres host as IP address or name")
+	fs.StringVar(&serviceName, "service-name", serviceName, "(BSS_SERVICE_NAME) Boot script service name")
+	fs.StringVar(&spireServiceURL, "spire-url", spireServiceURL, "(SPIRE_TOKEN_URL) Spire join token service base URL")
+	fs.StringVar(&advertiseAddress, "cloud-init-address", advertiseAddress, "(BSS_ADVERTISE_ADDRESS) IP:PORT to advertise for cloud-init calls. This needs to be an IP as we do not have DNS when cloud-init runs")
+	fs.StringVar(&bssdbName, "postgres-dbname", bssdbName, "(BSS_DBNAME) Postgres database name")
+	fs.StringVar(&sqlUser, "postgres-username", sqlUser, "(BSS_DBUSER) Postgres username")
+	fs.StringVar(&sqlPass, "postgres-password", sqlPass, "(BSS_DBPASS) Postgres password")
+	fs.StringVar(&jwksURL, "jwks-url", jwksURL, "(BSS_JWKS_URL) Set the JWKS URL to fetch the public key for authorization (enables authentication)")
+	fs.StringVar(&authScopeClaim, "auth-scope-claim", authScopeClaim, "(BSS_AUTH_SCOPE_CLAIM) JWT claim containing the caller's scopes")
+	fs.StringVar(&authReadScope, "auth-read-scope", authReadScope, "(BSS_AUTH_READ_SCOPE) Scope required to read boot parameters and other protected data")
+	fs.StringVar(&authWriteScope, "auth-write-scope", authWriteScope, "(BSS_AUTH_WRITE_SCOPE) Scope required to create, modify, or delete boot parameters")
+	fs.StringVar(&authAdminScope, "auth-admin-scope", authAdminScope, "(BSS_AUTH_ADMIN_SCOPE) Scope required for administrative endpoints (implies read and write)")
+	fs.StringVar(&apiKeysFile, "api-keys-file", apiKeysFile, "(BSS_API_KEYS_FILE) YAML file of hashed API keys and their roles (enables authentication)")
+	fs.StringVar(&clientCertRolesFile, "client-cert-roles-file", clientCertRolesFile, "(BSS_CLIENT_CERT_ROLES_FILE) YAML file mapping TLS client certificate subjects to roles (enables authentication)")
+	fs.StringVar(&oauth2AdminBaseURL, "oauth2-admin-base-url", oauth2AdminBaseURL, "(BSS_OAUTH2_ADMIN_BASE_URL) Base URL of the OAUTH2 server admin endpoints for client authorizations")
+	fs.StringVar(&oauth2PublicBaseURL, "oauth2-public-base-url", oauth2PublicBaseURL, "(BSS_OAUTH2_PUBLIC_BASE_URL) Base URL of the OAUTH2 server public endpoints (e.g. for token grants)")
+	fs.StringVar(&bootscriptNotifyURL, "bootscript-notify-url", bootscriptNotifyURL, "(BSS_BOOTSCRIPT_NOTIFY_URL) Full URL to which a JSON event is POSTed for each boot script request (e.g. TPM-manager server)")
+	fs.StringVar(&notifierURL, "endpoint-host", notifierURL, "(BSS_ENDPOINT_HOST) Host and port at which HSM sends state change notifications to BSS, by default derived from the host name and --http-listen")
+	fs.StringVar(&ipxeServer, "ipxe-server", ipxeServer, "(BSS_IPXE_SERVER) Host that boot scripts chain back to")
+	fs.StringVar(&chainProto, "chain-proto", chainProto, "(BSS_CHAIN_PROTO) Protocol with which boot scripts chain back: http or https; always http on --http-plain-listen")
+	fs.StringVar(&gwURI, "gw-uri", gwURI, "(BSS_GW_URI) Path prefix of BSS behind the API gateway that boot scripts chain back to")
+	fs.StringVar(&kvHost, "etcd-host", kvHost, "(ETCD_HOST) etcd host, used with --etcd-port instead of --datastore")
+	fs.StringVar(&kvPort, "etcd-port", kvPort, "(ETCD_PORT) etcd port, used with --etcd-host instead of --datastore")
+	fs.StringVar(&sqlDbOpts, "postgres-opts", sqlDbOpts, "(BSS_DBOPTS) Additional Postgres connection options")
+	stringListVar(fs, &blockedRoles, "blocked-roles", "(BSS_BLOCKED_ROLES) Comma separated roles that are not given boot scripts")
+	stringListVar(fs, &bootscriptNotifyURLs, "bootscript-notify-urls", "(BSS_BOOTSCRIPT_NOTIFY_URLS) Comma separated URLs to which a JSON event is POSTed for each boot script request")
+	fs.BoolVar(&authScopesEnabled, "auth-scopes", authScopesEnabled, "(BSS_AUTH_SCOPES_ENABLED) Require scopes in addition to a valid token on protected endpoints")
+	fs.BoolVar(&authProtectReads, "auth-protect-reads", authProtectReads, "(BSS_AUTH_PROTECT_READS) Require authentication for /hosts, /dumpstate, and /endpoint-history")
+	fs.BoolVar(&insecure, "insecure", insecure, "(BSS_INSECURE) Don't enforce https certificate security")
+	fs.BoolVar(&debugFlag, "debug", debugFlag, "(BSS_DEBUG) Enable debug output")
+	fs.BoolVar(&useSQL, "postgres", useSQL, "(BSS_USESQL) Use Postgres instead of ETCD")
+	fs.UintVar(&retryDelay, "retry-delay", retryDelay, "(BSS_RETRY_DELAY) Retry delay in seconds")
+	fs.UintVar(&hsmRetrievalDelay, "hsm-retrieval-delay", hsmRetrievalDelay, "(BSS_HSM_RETRIEVAL_DELAY) SM Retrieval delay in seconds")
+	fs.UintVar(&hsmResyncInterval, "hsm-resync-interval", hsmResyncInterval, "(BSS_HSM_RESYNC_INTERVAL) Seconds after which a state change notification triggers a full HSM fetch instead of an incremental update, 0 to always fetch")
+	fs.UintVar(&bootscriptMaxConcurrent, "bootscript-max-concurrent", bootscriptMaxConcurrent, "(BSS_BOOTSCRIPT_MAX_CONCURRENT) Maximum concurrent boot script requests, 0 for no limit")
+	fs.UintVar(&throttleDelay, "throttle-delay", throttleDelay, "(BSS_THROTTLE_DELAY) Seconds a throttled node sleeps before retrying")
+	fs.UintVar(&bootscriptCacheTTL, "bootscript-cache-ttl", bootscriptCacheTTL, "(BSS_BOOTSCRIPT_CACHE_TTL) Seconds to cache boot script lookups, 0 to disable the cache")
+	fs.UintVar(&bootscriptCacheSize, "bootscript-cache-size", bootscriptCacheSize, "(BSS_BOOTSCRIPT_CACHE_SIZE) Maximum number of cached boot script lookups")
+	fs.UintVar(&bootDeadline, "boot-deadline", bootDeadline, "(BSS_BOOT_DEADLINE) Seconds after its boot script within which a node must fetch user-data or phone home before its boot is reported as stuck, 0 to disable")
+	fs.UintVar(&bootRetryLimit, "boot-retry-limit", bootRetryLimit, "(BSS_BOOT_RETRY_LIMIT) Boot retries or restarts without progress after which a node is reported as looping, 0 to disable")
+	fs.UintVar(&rescueRetries, "rescue-retries", rescueRetries, "(BSS_RESCUE_RETRIES) Boot script retries after which a node gets its rescue boot configuration or stops retrying, 0 to retry forever")
+	fs.UintVar(&endpointHistoryRetention, "endpoint-history-retention", endpointHistoryRetention, "(BSS_ENDPOINT_HISTORY_RETENTION) Seconds to keep endpoint accesses, 0 to keep them forever")
+	fs.UintVar(&bootscriptNotifyMaxAttempts, "bootscript-notify-max-attempts", bootscriptNotifyMaxAttempts, "(BSS_BOOTSCRIPT_NOTIFY_MAX_ATTEMPTS) Delivery attempts after which a boot script notification is given up, 0 to retry forever")
+	fs.UintVar(&webhookMaxAttempts, "webhook-max-attempts", webhookMaxAttempts, "(BSS_WEBHOOK_MAX_ATTEMPTS) Delivery attempts after which a webhook event becomes a dead letter, 0 to retry forever")
+	fs.UintVar(&eventLogSize, "event-log-size", eventLogSize, "(BSS_EVENT_LOG_SIZE) Events kept for clients resuming /boot/v1/events with Last-Event-ID, 0 to disable the event stream")
+	fs.UintVar(&shutdownDelay, "shutdown-delay", shutdownDelay, "(BSS_SHUTDOWN_DELAY) Seconds to report not ready before closing listeners on shutdown")
+	fs.UintVar(&shutdownTimeout, "shutdown-timeout", shutdownTimeout, "(BSS_SHUTDOWN_TIMEOUT) Seconds to wait for in-flight requests and pending notifications on shutdown")
+	fs.UintVar(&sqlPort, "postgres-port", sqlPort, "(BSS_DBPORT) Postgres port")
+	fs.Uint64Var(&authRetryCount, "auth-retry-count", authRetryCount, "(BSS_AUTH_RETRY_COUNT) Retry fetching JWKS public key set")
+	fs.Uint64Var(&authRetryWait, "auth-retry-wait", authRetryWait, "(BSS_AUTH_RETRY_WAIT) Interval in seconds between authentication request attempts")
+	fs.Uint64Var(&kvRetryCount, "etcd-retry-count", kvRetryCount, "(ETCD_RETRY_COUNT) Amount of times to retry connecting to etcd")
+	fs.Uint64Var(&kvRetryWait, "etcd-retry-wait", kvRetryWait, "(ETCD_RETRY_WAIT) Interval in seconds between connection attempts to etcd")
+	fs.Uint64Var(&sqlRetryCount, "postgres-retry-count", sqlRetryCount, "(BSS_SQL_RETRY_COUNT) Amount of times to retry connecting to Postgres")
+	fs.Uint64Var(&sqlRetryWait, "postgres-retry-wait", sqlRetryWait, "(BSS_SQL_RETRY_WAIT) Interval in seconds between connection attempts to Postgres")
+	fs.BoolVar(&printConfig, "print-config", printConfig, "Print the effective configuration with secrets masked and exit")
+}
+
+// stringListVar defines a flag for a comma separated list, which replaces the
+// value of p when given.
+func stringListVar(fs *flag.FlagSet, p *[]string, name, usage string) {
+	fs.Func(name, usage, func(s string) error {
+		*p = strings.Split(s, ",")
+		return nil
+	})
+}
+
 func parseCmdLine() {
-	flag.StringVar(&httpListen, "http-listen", httpListen, "(BSS_HTTP_LISTEN) HTTP server IP + port binding")
-	flag.StringVar(&hsmBase, "hsm", hsmBase, "(HSM_URL) Hardware State Manager location as URI, e.g. [scheme]://[host[:port]]")
-	flag.StringVar(&nfdBase, "nfd", nfdBase, "(NFD_URL) Notification daemon location as URI, e.g. [scheme]://[host[:port]]")
-	flag.StringVar(&datastoreBase, "datastore", kvDefaultURL(), "(DATASTORE_BASE) Datastore Service location as URI")
-	flag.StringVar(&sqlHost, "postgres-host", sqlHost, "(BSS_DBHOST) Postgres host as IP address or name")
-	flag.StringVar(&serviceName, "service-name", serviceName, "(BSS_SERVICE_NAME) Boot script service name")
-	flag.StringVar(&spireTokensBaseURL, "spire-url", spireServiceURL, "(SPIRE_TOKEN_URL) Spire join token service base URL")
-	flag.StringVar(&advertiseAddress, "cloud-init-address", advertiseAddress, "(BSS_ADVERTISE_ADDRESS) IP:PORT to advertise for cloud-init calls. This needs to be an IP as we do not have DNS when cloud-init runs")
-	flag.StringVar(&bssdbName, "postgres-dbname", bssdbName, "(BSS_DBNAME) Postgres database name")
-	flag.StringVar(&sqlUser, "postgres-username", sqlUser, "(BSS_DBUSER) Postgres username")
-	flag.StringVar(&sqlPass, "postgres-password", sqlPass, "(BSS_DBPASS) Postgres password")
-	flag.StringVar(&jwksURL, "jwks-url", jwksURL, "(BSS_JWKS_URL) Set the JWKS URL to fetch the public key for authorization (enables authentication)")
-	flag.StringVar(&oauth2AdminBaseURL, "oauth2-admin-base-url", oauth2AdminBaseURL, "(BSS_OAUTH2_ADMIN_BASE_URL) Base URL of the OAUTH2 server admin endpoints for client authorizations")
-	flag.StringVar(&oauth2PublicBaseURL, "oauth2-public-base-url", oauth2PublicBaseURL, "(BSS_OAUTH2_PUBLIC_BASE_URL) Base URL of the OAUTH2 server public endpoints (e.g. for token grants)")
-	flag.StringVar(&bootscriptNotifyURL, "bootscript-notify-url", bootscriptNotifyURL, "(BSS_BOOTSCRIPT_NOTIFY_URL) Full URL to which newly-booted node IPs should be POSTed (e.g. TPM-manager server)")
-	flag.BoolVar(&insecure, "insecure", insecure, "(BSS_INSECURE) Don't enforce https certificate security")
-	flag.BoolVar(&debugFlag, "debug", debugFlag, "(BSS_DEBUG) Enable debug output")
-	flag.BoolVar(&useSQL, "postgres", useSQL, "(BSS_USESQL) Use Postgres instead of ETCD")
-	flag.UintVar(&retryDelay, "retry-delay", retryDelay, "(BSS_RETRY_DELAY) Retry delay in seconds")
-	flag.UintVar(&hsmRetrievalDelay, "hsm-retrieval-delay", hsmRetrievalDelay, "(BSS_HSM_RETRIEVAL_DELAY) SM Retrieval delay in seconds")
-	flag.UintVar(&sqlPort, "postgres-port", sqlPort, "(BSS_DBPORT) Postgres port")
-	flag.Uint64Var(&authRetryCount, "auth-retry-count", authRetryCount, "(BSS_AUTH_RETRY_COUNT) Retry fetching JWKS public key set")
-	flag.Uint64Var(&authRetryWait, "auth-retry-wait", authRetryWait, "(BSS_AUTH_RETRY_WAIT) Interval in seconds between authentication request attempts")
-	flag.Uint64Var(&sqlRetryCount, "postgres-retry-count", sqlRetryCount, "(BSS_SQL_RETRY_COUNT) Amount of times to retry connecting to Postgres")
-	flag.Uint64Var(&sqlRetryWait, "postgres-retry-wait", sqlRetryCount, "(BSS_SQL_RETRY_WAIT) Interval in seconds between connection attempts to Postgres")
+	defineFlags(flag.CommandLine)
 	flag.Parse()
 }
 
 func main() {
 	PrintVersionInfo()
+	configFile = configFileFromArgs(os.Args[1:])
+	if configFile != "" {
+		if err := loadConfigFile(configFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 	err := parseEnvVars()
 	if err != nil {
-		log.Println(err)
-		log.Println("WARNING: Ignoring environment variables with errors.")
+		log.Fatal(err)
 	}
 	parseCmdLine()
+	recordConfigOverrides()
+	if printConfig {
+		printEffectiveConfig()
+		os.Exit(0)
+	}
+	parsed, err := validateConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyParsedConfig(parsed)
+	if configFile != "" {
+		watchConfigReload()
+	}
 
 	sn, snerr := os.Hostname()
 	if snerr == nil {
@@ -454,6 +774,11 @@ func main() {
 	}
 	log.Printf("Service %s started", serviceName)
 
+	shutdownTracing, err := initTracing()
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 	// try and fetch JWKS from issuer
 	if jwksURL != "" {
 		for i := uint64(0); i <= authRetryCount; i++ {
@@ -468,6 +793,14 @@ func main() {
 		}
 	}
 
+	err = loadAuthenticators()
+	if err != nil {
+		log.Fatalf("Failed to load authenticators: %v", err)
+	}
+	if authProtectReads && !authEnabled() {
+		log.Fatalf("auth-protect-reads is set, but no API keys or client certificate roles were loaded")
+	}
+
 	router := initHandlers()
 
 	var svcOpts string
@@ -482,11 +815,6 @@ func main() {
 		log.Fatalf("--cloud-init-address or BSS_ADVERTISE_ADDRESS required.")
 	}
 
-	err = SmOpen(hsmBase, svcOpts)
-	if err != nil {
-		log.Fatalf("Access to SM service %s failed: %v\n", hsmBase, err)
-	}
-
 	notifier = newNotifier(serviceName, nfdBase+"/hmi/v1/subscribe", getNotifierURL(), svcOpts)
 
 	// If --postgres passed, use Postgres. Otherwise, use Etcd.
@@ -499,17 +827,87 @@ func main() {
 		if err != nil {
 			log.Fatalf("Access to Postgres database at %s:%d failed: %v\n", sqlHost, sqlPort, err)
 		}
-		defer sqlClose()
 	} else {
 		err = kvOpen(datastoreBase, svcOpts, kvRetryCount, kvRetryWait)
 		if err != nil {
 			log.Fatalf("Access to Datastore service %s with name %s failed: %v\n", datastoreBase, serviceName, err)
 		}
 	}
+	initEvents()
+	initStateSignal()
+	initDiscovery()
+	initBootSessions()
+	if err = initInventory(svcOpts); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err = initNotifier(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err = initWebhooks(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	stopPruner := startEndpointHistoryPruner()
 	err = spireTokenServiceInit(spireServiceURL, svcOpts)
 	if err != nil {
 		// NOTE: Should this be fatal???  Right now, we will continue.
 		log.Printf("WARNING: Spire join token service %s access failure: %s", spireServiceURL, err)
 	}
-	log.Fatal(http.ListenAndServe(httpListen, router))
+	servers := []*http.Server{{Addr: httpListen, Handler: router}}
+	starts := []func() error{servers[0].ListenAndServe}
+	if tlsCertFile == "" {
+		if httpPlainListen != "" || tlsClientCAFile != "" {
+			log.Printf("WARNING: --http-plain-listen and --tls-client-ca have no effect without --tls-cert")
+		}
+		log.Printf("Serving HTTP on %s", httpListen)
+	} else {
+		tlsConfig, err := newTLSConfig()
+		if err != nil {
+			log.Fatalf("TLS configuration failed: %v", err)
+		}
+		if len(certRoles) > 0 && tlsConfig.ClientCAs == nil {
+			log.Printf("WARNING: client certificate roles are configured but no client CA is set, so no certificates will be accepted")
+		}
+		servers[0].TLSConfig = tlsConfig
+		starts[0] = func() error { return servers[0].ListenAndServeTLS("", "") }
+		log.Printf("Serving HTTPS on %s", httpListen)
+		if httpPlainListen != "" {
+			plain := &http.Server{Addr: httpPlainListen, Handler: initPlainHandlers()}
+			servers = append(servers, plain)
+			starts = append(starts, plain.ListenAndServe)
+			log.Printf("Serving boot and cloud-init endpoints over plain HTTP on %s", httpPlainListen)
+		}
+	}
+	serveErr := serveUntilSignalled(servers, starts)
+
+	stopPruner()
+	notifications.stop()
+	if webhookEvents != nil {
+		webhookEvents.stop()
+	}
+
+	if stateSig != nil {
+		if err := stateSig.close(); err != nil {
+			log.Printf("WARNING: failed to stop listening for state changes: %v", err)
+		}
+	}
+	if configSig != nil {
+		if err := configSig.close(); err != nil {
+			log.Printf("WARNING: failed to stop listening for boot configuration changes: %v", err)
+		}
+	}
+	if useSQL {
+		sqlClose()
+	} else if kvstore != nil {
+		if err := kvstore.Close(); err != nil {
+			log.Printf("WARNING: failed to close etcd connection: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("WARNING: failed to flush traces: %v", err)
+	}
+	if serveErr != nil {
+		log.Fatal(serveErr)
+	}
 }
@@ -0,0 +1,247 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// Prometheus metrics.
+//
+// The metrics are registered with the default registry and served on /metrics
+// together with the Go runtime and process collectors.
+//
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	hmetcd "github.com/Cray-HPE/hms-hmetcd"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	metricsNamespace = "bss"
+	metricsRoute     = "/metrics"
+
+	// Outcomes of a boot script request
+	outcomeServed    = "served"    // Boot script for a known node
+	outcomeUnknown   = "unknown"   // Discovery script or chain for an unknown node
+	outcomeBlocked   = "blocked"   // Node's role is blocked
+	outcomeDelayed   = "delayed"   // Delayed chain while HSM state is refreshed
+	outcomeThrottled = "throttled" // Delayed chain because of admission control
+	outcomeRescue    = "rescue"    // Rescue boot script after too many retries
+	outcomeAbandoned = "abandoned" // Script that stops retrying, with no rescue configuration
+	outcomeError     = "error"     // Anything else
+
+	// How long the number of boot configurations is cached between scrapes
+	configCountCacheTime = time.Minute
+)
+
+var (
+	bootscriptRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "bootscript_requests_total",
+		Help:      "Boot script requests by outcome.",
+	}, []string{"outcome"})
+	bootscriptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "bootscript_request_duration_seconds",
+		Help:      "Time taken to answer boot script requests, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+	cloudInitRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cloudinit_requests_total",
+		Help:      "Cloud-init requests by endpoint and HTTP status code.",
+	}, []string{"endpoint", "code"})
+	hsmRefreshDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "hsm_refresh_duration_seconds",
+		Help:      "Time taken to retrieve component state from HSM, by result.",
+		Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"result"})
+	sqlQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "postgres_query_duration_seconds",
+		Help:      "Latency of Postgres operations, by operation and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+	etcdErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "etcd_errors_total",
+		Help:      "Failed etcd operations, by operation.",
+	}, []string{"operation"})
+	scnUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "hsm_state_changes_total",
+		Help:      "State change notifications, by whether they were applied to the HSM state snapshot or require a full fetch.",
+	}, []string{"result"})
+	spireTokenFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "spire_join_token_failures_total",
+		Help:      "Failed SPIRE join token requests.",
+	})
+	s3PresignFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "s3_presign_failures_total",
+		Help:      "Failures to generate presigned S3 URLs.",
+	})
+	bootCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "bootscript_cache_requests_total",
+		Help:      "Boot script lookups answered from the cache (hit) or storage (miss).",
+	}, []string{"result"})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bootscript_cache_entries",
+		Help:      "Number of boot script lookups in the cache.",
+	}, func() float64 { return float64(bootCache.len()) })
+	knownNodes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "known_nodes",
+		Help:      "Number of nodes in the current HSM state snapshot.",
+	})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "hsm_state_age_seconds",
+		Help:      "Seconds since the HSM state snapshot was requested.",
+	}, func() float64 {
+		smMutex.Lock()
+		ts := smTimeStamp
+		smMutex.Unlock()
+		if ts == 0 {
+			return 0
+		}
+		return float64(time.Now().Unix() - ts)
+	})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "boot_configs",
+		Help:      "Number of stored boot parameter entries.",
+	}, configCount.get)
+)
+
+// countCache caches the number of boot configurations, which would otherwise
+// require a full read of the data store on every scrape.
+type countCache struct {
+	mu      sync.Mutex
+	value   float64
+	updated time.Time
+}
+
+var configCount countCache
+
+func (c *countCache) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.updated) < configCountCacheTime {
+		return c.value
+	}
+	if useSQL {
+		if bssdb.DB == nil {
+			return c.value
+		}
+		start := time.Now()
+		bps, err := bssdb.GetBootParamsAll()
+		observeSQL("get_all", start, err)
+		if err != nil {
+			return c.value
+		}
+		c.value = float64(len(bps))
+	} else {
+		if kvstore == nil {
+			return c.value
+		}
+		tags, err := getTags()
+		if err != nil {
+			return c.value
+		}
+		c.value = float64(len(tags))
+	}
+	c.updated = time.Now()
+	return c.value
+}
+
+// observeBootscript records the outcome and duration of a boot script request.
+func observeBootscript(outcome string, start time.Time) {
+	bootscriptRequests.WithLabelValues(outcome).Inc()
+	bootscriptDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// observeSQL records the latency of a Postgres operation started at start.
+func observeSQL(operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	sqlQueryDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// countCloudInit is middleware counting cloud-init requests by status code.
+func countCloudInit(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next(ww, r)
+		code := ww.Status()
+		if code == 0 {
+			code = http.StatusOK
+		}
+		cloudInitRequests.WithLabelValues(endpoint, strconv.Itoa(code)).Inc()
+	}
+}
+
+// metricsKvi wraps the etcd client to count failed operations.
+type metricsKvi struct {
+	hmetcd.Kvi
+}
+
+func countEtcdError(operation string, err error) error {
+	if err != nil {
+		etcdErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
+
+func (m metricsKvi) Store(key, value string) error {
+	return countEtcdError("store", m.Kvi.Store(key, value))
+}
+
+func (m metricsKvi) Get(key string) (string, bool, error) {
+	val, exists, err := m.Kvi.Get(key)
+	return val, exists, countEtcdError("get", err)
+}
+
+func (m metricsKvi) GetRange(keystart, keyend string) ([]hmetcd.Kvi_KV, error) {
+	kvl, err := m.Kvi.GetRange(keystart, keyend)
+	return kvl, countEtcdError("get_range", err)
+}
+
+func (m metricsKvi) Delete(key string) error {
+	return countEtcdError("delete", m.Kvi.Delete(key))
+}
+
+func (m metricsKvi) DistTimedLock(tosec int) error {
+	return countEtcdError("lock", m.Kvi.DistTimedLock(tosec))
+}
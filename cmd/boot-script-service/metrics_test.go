@@ -0,0 +1,61 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCountCloudInit(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}
+	implicitOK := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("instance-id: test\n"))
+	}
+	handler := countCloudInit("test-404", notFound)
+	for i := 0; i < 2; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/meta-data", nil))
+	}
+	countCloudInit("test-200", implicitOK)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/meta-data", nil))
+
+	if n := testutil.ToFloat64(cloudInitRequests.WithLabelValues("test-404", "404")); n != 2 {
+		t.Errorf("Counted %v requests with status 404, expected 2", n)
+	}
+	if n := testutil.ToFloat64(cloudInitRequests.WithLabelValues("test-200", "200")); n != 1 {
+		t.Errorf("Counted %v requests with status 200, expected 1", n)
+	}
+}
+
+func TestObserveBootscript(t *testing.T) {
+	before := testutil.ToFloat64(bootscriptRequests.WithLabelValues(outcomeBlocked))
+	observeBootscript(outcomeBlocked, time.Now())
+	if n := testutil.ToFloat64(bootscriptRequests.WithLabelValues(outcomeBlocked)); n != before+1 {
+		t.Errorf("Counted %v blocked requests, expected %v", n, before+1)
+	}
+}
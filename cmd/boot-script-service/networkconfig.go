@@ -0,0 +1,273 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Cloud-init network configuration.  /network-config renders a network
+// config version 2 document for the requesting node from its interfaces in
+// the inventory, combined with overrides stored as the network-config of
+// the global, role, group, and node cloud-init data.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// networksKey holds, in the overrides, the prefix length and other
+	// settings of each HSM network, which the HSM does not know.
+	networksKey = "networks"
+	// defaultNetwork applies to addresses with no HSM network.
+	defaultNetwork = "default"
+)
+
+// nodeInterface is a generated ethernet of a node.
+type nodeInterface struct {
+	name  string
+	mac   string
+	addrs []compAddress
+}
+
+// nodeInterfaces returns the interfaces of a node, named nic0, nic1, ... in
+// the order of their MAC addresses, along with the addresses the HSM has for
+// each.
+func nodeInterfaces(comp SMComponent, addrs []compAddress) []nodeInterface {
+	var macs []string
+	seen := make(map[string]bool)
+	add := func(mac string) {
+		if mac = normalizeMAC(mac); mac != "" && mac != badMAC && mac != undefinedMAC && !seen[mac] {
+			seen[mac] = true
+			macs = append(macs, mac)
+		}
+	}
+	for _, m := range comp.Mac {
+		add(m)
+	}
+	for _, a := range addrs {
+		add(a.MAC)
+	}
+	sort.Strings(macs)
+
+	ifaces := make([]nodeInterface, len(macs))
+	for i, mac := range macs {
+		ifaces[i] = nodeInterface{name: fmt.Sprintf("nic%d", i), mac: mac}
+		for _, a := range addrs {
+			if a.MAC == mac {
+				ifaces[i].addrs = append(ifaces[i].addrs, a)
+			}
+		}
+	}
+	return ifaces
+}
+
+// networkOverrides returns the network-config overrides that apply to a
+// node, merged in increasing precedence: those of the global data, of the
+// role named by the shasta-role in the node's meta-data, of the boot group
+// named by its inventory role, and of the node itself.
+func networkOverrides(comp SMComponent, ifaces []nodeInterface) map[string]interface{} {
+	nodeData, _ := lookupNodeCloudInit(comp.ID)
+	role, _ := nodeData.MetaData["shasta-role"].(string)
+
+	overrides := make(map[string]interface{})
+	seen := make(map[string]bool)
+	for _, name := range []string{GlobalTag, role, comp.Role, comp.ID} {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		data := nodeData
+		if name != comp.ID {
+			data, _ = lookupStoredCloudInit(name)
+		}
+		if len(data.NetworkConfig) > 0 {
+			overrides = mergeMaps(overrides, renameInterfaces(data.NetworkConfig, ifaces))
+		}
+	}
+	return overrides
+}
+
+// renameInterfaces returns the overrides with interfaces given by MAC
+// address, as ethernets and in the interfaces of bonds and bridges or the
+// link of VLANs, replaced by their generated names.
+func renameInterfaces(data bssTypes.CloudDataType, ifaces []nodeInterface) map[string]interface{} {
+	names := make(map[string]string, len(ifaces))
+	for _, i := range ifaces {
+		names[i.mac] = i.name
+	}
+	rename := func(v interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			if name, ok := names[normalizeMAC(s)]; ok {
+				return name
+			}
+		}
+		return v
+	}
+
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	if eths, ok := data["ethernets"].(map[string]interface{}); ok {
+		renamed := make(map[string]interface{}, len(eths))
+		for k, v := range eths {
+			renamed[rename(k).(string)] = v
+		}
+		out["ethernets"] = renamed
+	}
+	for _, kind := range []string{"bonds", "bridges", "vlans"} {
+		devs, ok := data[kind].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		renamed := make(map[string]interface{}, len(devs))
+		for k, v := range devs {
+			dev, ok := v.(map[string]interface{})
+			if !ok {
+				renamed[k] = v
+				continue
+			}
+			d := make(map[string]interface{}, len(dev))
+			for dk, dv := range dev {
+				d[dk] = dv
+			}
+			if members, ok := dev["interfaces"].([]interface{}); ok {
+				m := make([]interface{}, len(members))
+				for i, member := range members {
+					m[i] = rename(member)
+				}
+				d["interfaces"] = m
+			}
+			if link, ok := dev["link"]; ok {
+				d["link"] = rename(link)
+			}
+			renamed[k] = d
+		}
+		out[kind] = renamed
+	}
+	return out
+}
+
+// generateNetworkConfig renders the network config of a node.  Each
+// interface is matched by its MAC address.  Its HSM addresses are assigned
+// statically if the overrides give the prefix length of their network, and
+// otherwise the interface uses DHCP.
+func generateNetworkConfig(ifaces []nodeInterface, overrides map[string]interface{}) map[string]interface{} {
+	networks, _ := overrides[networksKey].(map[string]interface{})
+	delete(overrides, networksKey)
+
+	ethernets := make(map[string]interface{}, len(ifaces))
+	for _, i := range ifaces {
+		eth := map[string]interface{}{
+			"match": map[string]interface{}{"macaddress": i.mac},
+		}
+		var addresses, routes []interface{}
+		for _, a := range i.addrs {
+			network := a.Network
+			if network == "" {
+				network = defaultNetwork
+			}
+			settings, _ := networks[network].(map[string]interface{})
+			ip := net.ParseIP(a.IP)
+			dhcp := "dhcp4"
+			if ip != nil && ip.To4() == nil {
+				dhcp = "dhcp6"
+			}
+			prefix, ok := settings["prefix"].(float64)
+			if !ok || ip == nil {
+				eth[dhcp] = true
+				continue
+			}
+			addresses = append(addresses, fmt.Sprintf("%s/%d", a.IP, int(prefix)))
+			if gw, ok := settings["gateway"].(string); ok && gw != "" {
+				routes = append(routes, map[string]interface{}{"to": "default", "via": gw})
+			}
+			for _, k := range []string{"mtu", "nameservers"} {
+				if v, ok := settings[k]; ok {
+					eth[k] = v
+				}
+			}
+			if r, ok := settings["routes"].([]interface{}); ok {
+				routes = append(routes, r...)
+			}
+		}
+		if len(addresses) > 0 {
+			eth["addresses"] = addresses
+		}
+		if len(routes) > 0 {
+			eth["routes"] = routes
+		}
+		ethernets[i.name] = eth
+	}
+
+	config := make(map[string]interface{})
+	if len(ethernets) > 0 {
+		config["ethernets"] = ethernets
+	}
+	config = mergeMaps(config, overrides)
+	config["version"] = 2
+	return config
+}
+
+func networkConfigGetAPI(w http.ResponseWriter, r *http.Request) {
+	remoteaddr := findRemoteAddr(r)
+
+	xname, found := FindXnameByIP(remoteaddr)
+	comp, known := FindSMCompByName(xname)
+	if !found || !known {
+		// Without a network config, cloud-init falls back to DHCP on the
+		// first interface, which is the best that can be done.
+		log.Printf("CloudInit -> No XName found for: %s, no network-config\n", remoteaddr)
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("No node found for %s", remoteaddr))
+		return
+	}
+
+	log.Printf("GET /network-config, xname: %s ip: %s", xname, remoteaddr)
+	ifaces := nodeInterfaces(comp, FindSMAddresses(xname))
+	overrides := networkOverrides(comp, ifaces)
+	if len(ifaces) == 0 && len(overrides) == 0 {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound,
+			fmt.Sprintf("No interfaces or network-config found for %s", xname))
+		return
+	}
+	config := generateNetworkConfig(ifaces, overrides)
+
+	databytes, err := yaml.Marshal(config)
+	if err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "Invalid YAML")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(databytes)
+
+	// Record the fact this was asked for.
+	updateEndpointAccessed(xname, bssTypes.EndpointTypeNetworkConfig, remoteaddr, false)
+}
@@ -0,0 +1,163 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+	"github.com/OpenCHAMI/smd/v2/pkg/sm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func getNetworkConfig(t *testing.T, ip string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/network-config", nil)
+	req.RemoteAddr = ip + ":40000"
+	networkConfigGet(rr, req)
+	return rr
+}
+
+func TestNetworkConfig(t *testing.T) {
+	const xname, group, role = "x9200c0s0b0n0", "NetGroup", "net-role"
+	useInventory(t, &SMData{
+		Components: []SMComponent{{
+			Component: base.Component{ID: xname, Type: "Node", Role: group, SubRole: role},
+			Mac:       []string{"00:40:a6:00:00:02", "00:40:A6:00:00:01"},
+		}},
+		IPAddrs: map[string]sm.CompEthInterfaceV2{
+			"10.92.0.1": {CompID: xname, MACAddr: "00:40:a6:00:00:01",
+				IPAddrs: []sm.IPAddressMapping{{IPAddr: "10.92.0.1", Network: "NMN"}}},
+			"10.93.0.1": {CompID: xname, MACAddr: "0040a6000002",
+				IPAddrs: []sm.IPAddressMapping{{IPAddr: "10.93.0.1", Network: "HSN"}}},
+		},
+	})
+
+	// Later levels take precedence: global, role, group, node.
+	overrides := map[string]string{
+		GlobalTag: `{"networks": {"NMN": {"prefix": 16, "gateway": "10.92.0.254", "nameservers": {"addresses": ["10.92.0.2"]}}}}`,
+		role: `{"ethernets": {"00:40:a6:00:00:01": {"mtu": 8000}},
+			"vlans": {"vlan100": {"id": 100, "link": "00-40-a6-00-00-01", "addresses": ["192.168.100.5/24"]}}}`,
+		group: `{"ethernets": {"00:40:a6:00:00:01": {"mtu": 9000}, "00:40:a6:00:00:02": {"mtu": 9000}}}`,
+		xname: `{"ethernets": {"nic1": {"mtu": 1500}}}`,
+	}
+	for name, nc := range overrides {
+		metaData := `null`
+		if name == xname {
+			metaData = `{"shasta-role": "` + role + `"}`
+		}
+		body := `{"hosts":["` + name + `"],"cloud-init":{"meta-data":` + metaData + `,"network-config":` + nc + `}}`
+		if rr := webhookRequest(t, bootParameters, http.MethodPut, "/boot/v1/bootparameters", body); rr.Code != http.StatusOK {
+			t.Fatalf("PUT %s returned %d: %s", body, rr.Code, rr.Body.String())
+		}
+		defer Remove(bssTypes.BootParams{Hosts: []string{name}})
+	}
+
+	expected := `
+version: 2
+ethernets:
+  nic0:
+    match: {macaddress: "00:40:a6:00:00:01"}
+    addresses: [10.92.0.1/16]
+    routes: [{to: default, via: 10.92.0.254}]
+    nameservers: {addresses: [10.92.0.2]}
+    mtu: 9000
+  nic1:
+    match: {macaddress: "00:40:a6:00:00:02"}
+    dhcp4: true
+    mtu: 1500
+vlans:
+  vlan100:
+    id: 100
+    link: nic0
+    addresses: [192.168.100.5/24]
+`
+	rr := getNetworkConfig(t, "10.93.0.1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /network-config returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var got, want interface{}
+	if err := yaml.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid network-config %s: %v", rr.Body.String(), err)
+	}
+	yaml.Unmarshal([]byte(expected), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got network-config\n%s\nexpected\n%s", rr.Body.String(), expected)
+	}
+
+	ea, err := getEndpointAccess(xname, bssTypes.EndpointTypeNetworkConfig)
+	if err != nil || ea.ClientIP != "10.93.0.1" {
+		t.Errorf("Network-config access not recorded: %+v, %v", ea, err)
+	}
+}
+
+func TestNetworkOverridesSQL(t *testing.T) {
+	// Postgres stores no cloud-init data, and the KV store is not opened.
+	savedSQL, savedKV := useSQL, kvstore
+	useSQL, kvstore = true, nil
+	defer func() { useSQL, kvstore = savedSQL, savedKV }()
+
+	comp := SMComponent{Component: base.Component{ID: "x9200c0s0b0n0", Type: "Node", Role: "NetGroup"}}
+	if o := networkOverrides(comp, nil); len(o) != 0 {
+		t.Errorf("Got overrides %v with Postgres", o)
+	}
+}
+
+func TestNetworkConfigNotFound(t *testing.T) {
+	useInventory(t, &SMData{
+		Components: []SMComponent{{Component: base.Component{ID: "x9200c0s1b0n0", Type: "Node"}}},
+		IPAddrs:    map[string]sm.CompEthInterfaceV2{"10.92.1.1": {CompID: "x9200c0s1b0n0"}},
+	})
+	if rr := getNetworkConfig(t, "192.0.2.99"); rr.Code != http.StatusNotFound {
+		t.Errorf("GET /network-config from an unknown node returned %d", rr.Code)
+	}
+	// The node is known, but has no interfaces and no overrides.
+	if rr := getNetworkConfig(t, "10.92.1.1"); rr.Code != http.StatusNotFound {
+		t.Errorf("GET /network-config without interfaces returned %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNetworkConfigValidation(t *testing.T) {
+	for _, nc := range []string{
+		`{"version": 2}`,
+		`{"ethernets": ["nic0"]}`,
+		`{"networks": {"NMN": 16}}`,
+	} {
+		body := `{"hosts":["x9200c0s2b0n0"],"cloud-init":{"network-config":` + nc + `}}`
+		if rr := webhookRequest(t, bootParameters, http.MethodPut, "/boot/v1/bootparameters", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("PUT with network-config %s returned %d", nc, rr.Code)
+		}
+	}
+	bp := bssTypes.BootParams{CloudInit: bssTypes.CloudInit{NetworkConfig: bssTypes.CloudDataType{
+		"ethernets": map[string]interface{}{"nic0": nil},
+		"networks":  nil,
+	}}}
+	if err := bp.CheckNetworkConfig(); err != nil {
+		t.Errorf("Removals in a PATCH were rejected: %v", err)
+	}
+}
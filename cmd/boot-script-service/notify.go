@@ -0,0 +1,392 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Boot script notifications.
+//
+// Every boot script request produces a JSON event describing the node and
+// the outcome, which is POSTed to each configured target.  Deliveries that
+// fail are retried with exponential backoff, and kept in a queue directory
+// if one is configured so they survive a restart.  Deliveries that fail too
+// many times are moved to the dead letter directory, or logged.  The same
+// queue delivers boot parameter change events to webhook subscriptions.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+const (
+	notifySignatureHeader = "X-BSS-Signature"
+	notifyEventIDHeader   = "X-BSS-Event-ID"
+	notifyDeadDir         = "dead"
+)
+
+var (
+	bootscriptNotifyURLs        []string   // Additional boot script notification targets
+	bootscriptNotifySecret      = ""       // Key for HMAC-SHA256 signatures of notifications
+	bootscriptNotifyQueue       = ""       // Directory holding undelivered notifications
+	bootscriptNotifyMaxAttempts = uint(10) // Delivery attempts before a notification is dead-lettered
+
+	notifyRetryBase = time.Second     // Delay before the first retry, doubled for each one after
+	notifyRetryMax  = 5 * time.Minute // Longest delay between retries
+	notifyClient    = &http.Client{Timeout: 10 * time.Second}
+
+	notifications *notifyQueue
+)
+
+// notification is the delivery of one event to one target.
+type notification struct {
+	ID      string `json:"id"`
+	EventID string `json:"event_id"`
+	Target  string `json:"target"`
+	// Subscription is the webhook subscription this is delivered for, if any.
+	Subscription string          `json:"subscription,omitempty"`
+	Event        json.RawMessage `json:"event"`
+	Attempts     uint            `json:"attempts"`
+	NextAttempt  time.Time       `json:"next_attempt"`
+}
+
+// notifyQueue holds the notifications waiting to be retried.  If dir is set,
+// every undelivered notification is also kept there as <id>.json.
+type notifyQueue struct {
+	dir  string
+	what string // What the notifications are, for log messages
+
+	// secret returns the key n is signed with, if any, and false if its
+	// target has gone away so it should be dropped.
+	secret      func(n *notification) (string, bool, error)
+	maxAttempts *uint
+	// dead, if set, takes the notifications that are given up instead of
+	// the dead letter directory.
+	dead func(n *notification, err error)
+
+	mu      sync.Mutex
+	pending []*notification
+	wake    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// initNotifier starts the notification queue, loading the notifications left
+// in the queue directory by a previous run.
+func initNotifier() error {
+	q, err := newNotifyQueue(bootscriptNotifyQueue)
+	if err != nil {
+		return err
+	}
+	notifications = q
+	return nil
+}
+
+func newNotifyQueue(dir string) (*notifyQueue, error) {
+	q := &notifyQueue{
+		dir:         dir,
+		what:        "boot script notifications",
+		maxAttempts: &bootscriptNotifyMaxAttempts,
+		secret: func(*notification) (string, bool, error) {
+			configMutex.RLock()
+			defer configMutex.RUnlock()
+			return bootscriptNotifySecret, true, nil
+		},
+	}
+	if err := q.start(); err != nil {
+		return nil, fmt.Errorf("bootscript-notify-queue: %v", err)
+	}
+	return q, nil
+}
+
+// start loads the notifications left in the queue directory and starts
+// delivering them.
+func (q *notifyQueue) start() error {
+	q.wake = make(chan struct{}, 1)
+	q.done = make(chan struct{})
+	if dir := q.dir; dir != "" {
+		if err := os.MkdirAll(filepath.Join(dir, notifyDeadDir), 0o700); err != nil {
+			return err
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			var n notification
+			if err == nil {
+				err = json.Unmarshal(data, &n)
+			}
+			if err != nil {
+				log.Printf("WARNING: ignoring queued notification %s: %v", f, err)
+				continue
+			}
+			q.pending = append(q.pending, &n)
+		}
+		if len(q.pending) > 0 {
+			log.Printf("Loaded %d undelivered %s from %s", len(q.pending), q.what, dir)
+		}
+	}
+	q.wg.Add(1)
+	go q.run()
+	return nil
+}
+
+// stop stops retrying notifications.  Those in the queue directory are
+// retried when BSS next starts.
+func (q *notifyQueue) stop() {
+	close(q.done)
+	q.wg.Wait()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) > 0 && q.dir == "" {
+		log.Printf("WARNING: dropping %d undelivered %s", len(q.pending), q.what)
+	}
+}
+
+// run starts the delivery of queued notifications as they become due.
+func (q *notifyQueue) run() {
+	defer q.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		var next time.Time
+		remaining := q.pending[:0]
+		for _, n := range q.pending {
+			if !n.NextAttempt.After(now) {
+				goBackground(func() { q.deliver(n) })
+				continue
+			}
+			if next.IsZero() || n.NextAttempt.Before(next) {
+				next = n.NextAttempt
+			}
+			remaining = append(remaining, n)
+		}
+		q.pending = remaining
+		q.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case <-timer.C:
+		case <-q.wake:
+		case <-q.done:
+			return
+		}
+	}
+}
+
+func (q *notifyQueue) schedule(n *notification) {
+	q.mu.Lock()
+	q.pending = append(q.pending, n)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *notifyQueue) path(n *notification, dir ...string) string {
+	return filepath.Join(append(append([]string{q.dir}, dir...), n.ID+".json")...)
+}
+
+// persist writes n to the queue directory, if there is one.
+func (q *notifyQueue) persist(n *notification) {
+	if q.dir == "" {
+		return
+	}
+	data, _ := json.Marshal(n)
+	tmp := q.path(n) + ".tmp"
+	err := os.WriteFile(tmp, data, 0o600)
+	if err == nil {
+		err = os.Rename(tmp, q.path(n))
+	}
+	if err != nil {
+		log.Printf("WARNING: failed to queue notification %s: %v", n.ID, err)
+	}
+}
+
+// deliver makes one attempt to deliver n and schedules a retry if it fails.
+func (q *notifyQueue) deliver(n *notification) {
+	secret, ok, err := q.secret(n)
+	if err == nil && !ok {
+		debugf("Dropping notification %s, its target %s is gone", n.ID, n.Target)
+		q.remove(n)
+		return
+	}
+	if err == nil {
+		err = sendNotification(n, secret)
+	}
+	if err == nil {
+		q.remove(n)
+		return
+	}
+
+	configMutex.RLock()
+	maxAttempts := *q.maxAttempts
+	configMutex.RUnlock()
+	n.Attempts++
+	if maxAttempts > 0 && n.Attempts >= maxAttempts {
+		q.deadLetter(n, err)
+		return
+	}
+	delay := notifyRetryBase << (n.Attempts - 1)
+	if delay > notifyRetryMax || delay <= 0 {
+		delay = notifyRetryMax
+	}
+	n.NextAttempt = time.Now().Add(delay)
+	debugf("Notification %s to %s failed (attempt %d), retrying in %v: %v", n.ID, n.Target, n.Attempts, delay, err)
+	q.persist(n)
+	q.schedule(n)
+}
+
+// remove deletes n from the queue directory, if there is one.
+func (q *notifyQueue) remove(n *notification) {
+	if q.dir == "" {
+		return
+	}
+	if err := os.Remove(q.path(n)); err != nil && !os.IsNotExist(err) {
+		log.Printf("WARNING: failed to remove notification %s: %v", n.ID, err)
+	}
+}
+
+func (q *notifyQueue) deadLetter(n *notification, err error) {
+	if q.dead != nil {
+		q.dead(n, err)
+		q.remove(n)
+		return
+	}
+	if q.dir == "" {
+		log.Printf("WARNING: giving up on notification %s to %s after %d attempts: %v: %s",
+			n.ID, n.Target, n.Attempts, err, n.Event)
+		return
+	}
+	log.Printf("WARNING: giving up on notification %s to %s after %d attempts, moving it to %s: %v",
+		n.ID, n.Target, n.Attempts, filepath.Join(q.dir, notifyDeadDir), err)
+	q.persist(n)
+	if err := os.Rename(q.path(n), q.path(n, notifyDeadDir)); err != nil {
+		log.Printf("WARNING: failed to dead-letter notification %s: %v", n.ID, err)
+	}
+}
+
+// signNotification returns the value of the signature header for body.
+func signNotification(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendNotification(n *notification, secret string) error {
+	req, err := http.NewRequest(http.MethodPost, n.Target, bytes.NewReader(n.Event))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(notifyEventIDHeader, n.EventID)
+	if secret != "" {
+		req.Header.Set(notifySignatureHeader, signNotification(secret, n.Event))
+	}
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", n.Target, resp.Status)
+	}
+	return nil
+}
+
+// notifyTargets returns the configured notification targets.
+func notifyTargets() []string {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	var targets []string
+	for _, t := range append([]string{bootscriptNotifyURL}, bootscriptNotifyURLs...) {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// bootConfigRevision identifies the boot configuration a node was given, so
+// consumers can tell whether it changed between boots.
+func bootConfigRevision(bd BootData) string {
+	if bd.Kernel.Path == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(bd.Kernel.Path + "\n" + bd.Initrd.Path + "\n" + bd.Params))
+	return hex.EncodeToString(sum[:6])
+}
+
+// notifyBootscript sends ev to every notification target.
+func notifyBootscript(ev bssTypes.BootscriptEvent) {
+	q := notifications
+	targets := notifyTargets()
+	if q == nil || len(targets) == 0 {
+		return
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("WARNING: failed to encode boot script notification: %v", err)
+		return
+	}
+	for i, target := range targets {
+		n := &notification{
+			ID:      fmt.Sprintf("%s-%d", ev.ID, i),
+			EventID: ev.ID,
+			Target:  target,
+			Event:   data,
+		}
+		q.persist(n)
+		goBackground(func() { q.deliver(n) })
+	}
+}
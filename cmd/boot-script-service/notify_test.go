@@ -0,0 +1,189 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+// notifyTarget is a notification target that fails the first failures
+// requests it receives.
+type notifyTarget struct {
+	*httptest.Server
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newNotifyTarget(failures int) *notifyTarget {
+	nt := &notifyTarget{failures: failures}
+	nt.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		nt.mu.Lock()
+		defer nt.mu.Unlock()
+		nt.requests = append(nt.requests, r)
+		nt.bodies = append(nt.bodies, body)
+		if len(nt.requests) <= nt.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	return nt
+}
+
+func (nt *notifyTarget) count() int {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	return len(nt.requests)
+}
+
+// waitFor polls cond until it is true or a second has passed.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// useNotifyQueue sets up notifications to the given targets through a queue
+// in a temporary directory, which it returns.
+func useNotifyQueue(t *testing.T, targets ...string) string {
+	savedURLs, savedSecret, savedBase, savedMax := bootscriptNotifyURLs, bootscriptNotifySecret, notifyRetryBase, bootscriptNotifyMaxAttempts
+	dir := t.TempDir()
+	q, err := newNotifyQueue(dir)
+	if err != nil {
+		t.Fatalf("Unable to create notification queue: %v", err)
+	}
+	bootscriptNotifyURLs, notifyRetryBase, notifications = targets, 10*time.Millisecond, q
+	t.Cleanup(func() {
+		backgroundTasks.Wait()
+		q.stop()
+		notifications = nil
+		bootscriptNotifyURLs, bootscriptNotifySecret, notifyRetryBase, bootscriptNotifyMaxAttempts = savedURLs, savedSecret, savedBase, savedMax
+	})
+	return dir
+}
+
+func queued(t *testing.T, dir ...string) []string {
+	files, err := filepath.Glob(filepath.Join(append(dir, "*.json")...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestNotifyBootscript(t *testing.T) {
+	first, second := newNotifyTarget(0), newNotifyTarget(0)
+	defer first.Close()
+	defer second.Close()
+	dir := useNotifyQueue(t, first.URL, second.URL)
+	bootscriptNotifySecret = "s3cret"
+
+	BootscriptGet(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?mac=00:1e:67:e3:46:51&retry=2", nil))
+	backgroundTasks.Wait()
+
+	for _, nt := range []*notifyTarget{first, second} {
+		if nt.count() != 1 {
+			t.Fatalf("Expected 1 notification, got %d", nt.count())
+		}
+		var ev bssTypes.BootscriptEvent
+		if err := json.Unmarshal(nt.bodies[0], &ev); err != nil {
+			t.Fatalf("Unable to decode notification %s: %v", nt.bodies[0], err)
+		}
+		if ev.ID == "" || ev.Time == 0 || ev.Xname != "x0c0s1b0n0" || ev.Mac != "00:1e:67:e3:46:51" ||
+			ev.Nid != 8 || ev.IP != "192.0.2.1" || ev.Retry != 2 || ev.Outcome == "" {
+			t.Errorf("Unexpected notification: %+v", ev)
+		}
+		r := nt.requests[0]
+		if r.Header.Get(notifyEventIDHeader) != ev.ID {
+			t.Errorf("Event ID header %q does not match %q", r.Header.Get(notifyEventIDHeader), ev.ID)
+		}
+		if sig := r.Header.Get(notifySignatureHeader); sig != signNotification("s3cret", nt.bodies[0]) {
+			t.Errorf("Bad signature %q", sig)
+		}
+	}
+	if files := queued(t, dir); len(files) != 0 {
+		t.Errorf("Delivered notifications left in the queue: %v", files)
+	}
+}
+
+func TestNotifyRetry(t *testing.T) {
+	nt := newNotifyTarget(2)
+	defer nt.Close()
+	dir := useNotifyQueue(t, nt.URL)
+
+	notifyBootscript(bssTypes.BootscriptEvent{ID: newEventID(), Xname: "x0c0s1b0n0", Outcome: outcomeServed})
+	if !waitFor(func() bool { return nt.count() == 3 && len(queued(t, dir)) == 0 }) {
+		t.Errorf("Notification not delivered on the third attempt: %d attempts, queue %v", nt.count(), queued(t, dir))
+	}
+	if nt.requests[0].Header.Get(notifySignatureHeader) != "" {
+		t.Errorf("Notification signed without a secret")
+	}
+}
+
+func TestNotifyDeadLetter(t *testing.T) {
+	nt := newNotifyTarget(1000)
+	defer nt.Close()
+	dir := useNotifyQueue(t, nt.URL)
+	bootscriptNotifyMaxAttempts = 2
+
+	notifyBootscript(bssTypes.BootscriptEvent{ID: newEventID(), Xname: "x0c0s1b0n0", Outcome: outcomeServed})
+	if !waitFor(func() bool { return len(queued(t, dir, notifyDeadDir)) == 1 }) {
+		t.Fatalf("Notification not dead-lettered")
+	}
+	if nt.count() != 2 || len(queued(t, dir)) != 0 {
+		t.Errorf("Expected 2 attempts and an empty queue, got %d attempts and %v", nt.count(), queued(t, dir))
+	}
+}
+
+func TestNotifyQueueRestart(t *testing.T) {
+	nt := newNotifyTarget(0)
+	defer nt.Close()
+	dir := t.TempDir()
+	n := notification{ID: "abc-0", EventID: "abc", Target: nt.URL, Event: json.RawMessage(`{"id":"abc"}`), Attempts: 3}
+	data, _ := json.Marshal(n)
+	if err := os.WriteFile(filepath.Join(dir, "abc-0.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := newNotifyQueue(dir)
+	if err != nil {
+		t.Fatalf("Unable to create notification queue: %v", err)
+	}
+	defer q.stop()
+	if !waitFor(func() bool { return nt.count() == 1 && len(queued(t, dir)) == 0 }) {
+		t.Errorf("Queued notification not delivered after restart")
+	}
+}
@@ -0,0 +1,184 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// Liveness and readiness probes.
+//
+// /livez only reports that the process is able to answer requests.  /readyz
+// runs a configurable set of dependency checks and answers 503 with the
+// failing reasons when any of them fails, so that orchestrators can stop
+// sending boot traffic to a replica that cannot serve it.  None of the checks
+// write to the data store.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	livezRoute  = "/livez"
+	readyzRoute = "/readyz"
+
+	readyCheckStorage = "storage"
+	readyCheckHSM     = "hsm"
+	readyCheckJWKS    = "jwks"
+
+	readyCheckTimeout = 5 * time.Second
+)
+
+var (
+	readyChecks        = "storage,hsm,jwks" // Comma separated list of readiness checks
+	enabledReadyChecks []string
+
+	// Set once an HSM snapshot has been loaded
+	hsmSnapshotLoaded atomic.Bool
+)
+
+// readyCheckFuncs maps the name of each readiness check to its
+// implementation.  A check returns nil when the dependency is usable.
+var readyCheckFuncs = map[string]func(ctx context.Context) error{
+	readyCheckStorage: checkStorageReady,
+	readyCheckHSM:     checkHSMReady,
+	readyCheckJWKS:    checkJWKSReady,
+}
+
+type probeCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type probeStatus struct {
+	Status string       `json:"status"`
+	Checks []probeCheck `json:"checks,omitempty"`
+}
+
+// parseReadyChecks validates the configured readiness checks.
+func parseReadyChecks() error {
+	enabledReadyChecks = nil
+	for _, c := range strings.Split(readyChecks, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" || c == "none" {
+			continue
+		}
+		if _, ok := readyCheckFuncs[c]; !ok {
+			return fmt.Errorf("unknown readiness check %q (expected storage, hsm, jwks, or none)", c)
+		}
+		enabledReadyChecks = append(enabledReadyChecks, c)
+	}
+	return nil
+}
+
+func checkStorageReady(ctx context.Context) error {
+	if useSQL {
+		if bssdb.DB == nil {
+			return fmt.Errorf("postgres connection not open")
+		}
+		if err := bssdb.DB.PingContext(ctx); err != nil {
+			return fmt.Errorf("postgres unreachable: %v", err)
+		}
+		return nil
+	}
+	if kvstore == nil {
+		return fmt.Errorf("etcd connection not open")
+	}
+	if _, _, err := kvstore.Get(UpdateTimestampKey); err != nil {
+		return fmt.Errorf("etcd unreachable: %v", err)
+	}
+	return nil
+}
+
+// checkHSMReady loads HSM state if no snapshot has been loaded yet.  State is
+// otherwise only loaded by the first request that needs it, which a replica
+// that is not ready would never receive.
+func checkHSMReady(ctx context.Context) error {
+	if !hsmSnapshotLoaded.Load() {
+		protectedGetState(0)
+	}
+	if !hsmSnapshotLoaded.Load() {
+		return fmt.Errorf("no HSM state snapshot has been loaded")
+	}
+	return nil
+}
+
+func checkJWKSReady(ctx context.Context) error {
+	if jwksURL != "" && tokenAuth == nil {
+		return fmt.Errorf("JWKS keys have not been fetched from %s", jwksURL)
+	}
+	return nil
+}
+
+// runReadyCheck runs a check, giving up after readyCheckTimeout.
+func runReadyCheck(ctx context.Context, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out after %v", readyCheckTimeout)
+	}
+}
+
+func writeProbeStatus(w http.ResponseWriter, httpStatus int, status probeStatus) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(status)
+}
+
+func livezGet(w http.ResponseWriter, r *http.Request) {
+	writeProbeStatus(w, http.StatusOK, probeStatus{Status: "alive"})
+}
+
+func readyzGet(w http.ResponseWriter, r *http.Request) {
+	if shuttingDown.Load() {
+		writeProbeStatus(w, http.StatusServiceUnavailable, probeStatus{
+			Status: "not ready",
+			Checks: []probeCheck{{Name: "shutdown", Status: "failed", Reason: "service is shutting down"}},
+		})
+		return
+	}
+	status := probeStatus{Status: "ready"}
+	httpStatus := http.StatusOK
+	for _, name := range enabledReadyChecks {
+		check := probeCheck{Name: name, Status: "ok"}
+		if err := runReadyCheck(r.Context(), readyCheckFuncs[name]); err != nil {
+			check.Status = "failed"
+			check.Reason = err.Error()
+			status.Status = "not ready"
+			httpStatus = http.StatusServiceUnavailable
+		}
+		status.Checks = append(status.Checks, check)
+	}
+	writeProbeStatus(w, httpStatus, status)
+}
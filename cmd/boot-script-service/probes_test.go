@@ -0,0 +1,112 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	if err := parseReadyChecks(); err != nil {
+		t.Fatalf("parseReadyChecks returned error for default checks: %v", err)
+	}
+	defer func() { jwksURL = "" }()
+
+	tests := []struct {
+		jwksURL  string
+		expected int
+		failed   string
+	}{
+		{"", http.StatusOK, ""},
+		{"https://example.com/jwks.json", http.StatusServiceUnavailable, readyCheckJWKS},
+	}
+	for _, tt := range tests {
+		jwksURL = tt.jwksURL
+		rr := httptest.NewRecorder()
+		readyzGet(rr, httptest.NewRequest(http.MethodGet, readyzRoute, nil))
+		if rr.Code != tt.expected {
+			t.Errorf("/readyz with jwksURL %q returned %d, expected %d: %s", tt.jwksURL, rr.Code, tt.expected, rr.Body)
+		}
+		var status probeStatus
+		if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Could not decode /readyz response: %v", err)
+		}
+		for _, c := range status.Checks {
+			if (c.Status == "failed") != (c.Name == tt.failed) {
+				t.Errorf("Check %s has status %s (%s)", c.Name, c.Status, c.Reason)
+			}
+		}
+	}
+}
+
+func TestParseReadyChecks(t *testing.T) {
+	defer func() { readyChecks = "storage,hsm,jwks"; parseReadyChecks() }()
+
+	readyChecks = "storage, HSM"
+	if err := parseReadyChecks(); err != nil || len(enabledReadyChecks) != 2 {
+		t.Errorf("parseReadyChecks returned %v, %v", enabledReadyChecks, err)
+	}
+	readyChecks = "none"
+	if err := parseReadyChecks(); err != nil || len(enabledReadyChecks) != 0 {
+		t.Errorf("parseReadyChecks returned %v, %v", enabledReadyChecks, err)
+	}
+	readyChecks = "storage,disk"
+	if err := parseReadyChecks(); err == nil {
+		t.Errorf("parseReadyChecks accepted an unknown check")
+	}
+}
+
+func TestReadyzLoadsHSMState(t *testing.T) {
+	smMutex.Lock()
+	saved, savedIndex, savedTime := smData, smIndex, smTimeStamp
+	smData, smIndex = nil, nil
+	hsmSnapshotLoaded.Store(false)
+	smMutex.Unlock()
+	defer func() {
+		smMutex.Lock()
+		setState(saved)
+		smIndex, smTimeStamp = savedIndex, savedTime
+		smMutex.Unlock()
+	}()
+
+	savedInventory := inventory
+	defer func() { inventory = savedInventory }()
+	inventory = jsonInventory{path: "/nonexistent/inventory.json"}
+	if err := checkHSMReady(context.Background()); err == nil {
+		t.Errorf("HSM check passed without an HSM state snapshot")
+	}
+
+	inventory = savedInventory
+	rr := httptest.NewRecorder()
+	readyzGet(rr, httptest.NewRequest(http.MethodGet, readyzRoute, nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("/readyz returned %d after loading HSM state, expected %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if !hsmSnapshotLoaded.Load() || getIndex().byID["x0c0s1b0n0"] == nil {
+		t.Errorf("/readyz did not load HSM state")
+	}
+}
@@ -0,0 +1,118 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Rescue fallback for nodes that cannot boot.
+//
+// A boot script that fails to load its kernel or initrd sleeps and chains
+// back with an incremented retry count, so a node with a broken image URL
+// asks for the same script forever. Once the retry count reaches the limit
+// for the node, its role, or every node, the node is instead given its rescue
+// boot configuration, and if there is none, a script that stops retrying.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const rescueTag = "Rescue"
+
+var (
+	rescueRetries     = uint(0) // Boot script retries after which a node gets its rescue boot configuration
+	rescueNodeRetries = ""      // Comma separated xname=retries overrides of rescueRetries
+	rescueRoleRetries = ""      // Comma separated role=retries overrides of rescueRetries
+
+	// rescueNodeRetries and rescueRoleRetries parsed by validateConfig or
+	// reloadConfig
+	rescueNodeLimits map[string]uint
+	rescueRoleLimits map[string]uint
+)
+
+// rescueRetryLimit returns the number of retries after which a node falls
+// back to its rescue boot configuration, 0 if never.  As with the rescue
+// boot configuration itself, a limit for the node takes precedence over one
+// for its role, i.e. its boot group, which takes precedence over
+// rescueRetries.
+func rescueRetryLimit(comp SMComponent) uint {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	if limit, ok := rescueNodeLimits[strings.ToLower(comp.ID)]; ok {
+		return limit
+	}
+	if limit, ok := rescueRoleLimits[strings.ToLower(comp.Role)]; ok {
+		return limit
+	}
+	return rescueRetries
+}
+
+// rescueNames returns the names under which the rescue boot configuration
+// of a node is looked up, most specific first.
+func rescueNames(comp SMComponent) []string {
+	names := []string{rescueTag + "-" + comp.ID}
+	if comp.Role != "" {
+		names = append(names, rescueTag+"-"+comp.Role)
+	}
+	return append(names, rescueTag)
+}
+
+// lookupRescue returns the rescue boot configuration of a node: the boot
+// parameters stored under Rescue-<xname>, Rescue-<role>, or Rescue, or the
+// boot group with that name in Postgres.
+func lookupRescue(comp SMComponent) (bd BootData, name string, found bool) {
+	for _, name = range rescueNames(comp) {
+		bp, exists, err := groupBootParams(name)
+		if err != nil {
+			log.Printf("Failed to look up rescue boot configuration %s: %v", name, err)
+			continue
+		}
+		if exists && bp.Kernel != "" {
+			bd.Kernel = ImageData{Path: bp.Kernel}
+			bd.Initrd = ImageData{Path: bp.Initrd}
+			bd.Params = bp.Params
+			bd.CloudInit = bp.CloudInit
+			return bd, name, true
+		}
+	}
+	return bd, "", false
+}
+
+// rescueBootScript returns the script for a node that has retried its boot
+// script retry times.  rescued is false if the node has no rescue boot
+// configuration and the script only stops it from retrying.  Neither script
+// chains back to BSS, so a rescue image that fails to load does not start a
+// new loop.
+func rescueBootScript(ctx context.Context, sp scriptParams, comp SMComponent, retry int, descr string) (script string, rescued bool, err error) {
+	stop := fmt.Sprintf("echo Boot failed after %d retries\nexit", retry)
+	bd, name, found := lookupRescue(comp)
+	if !found {
+		log.Printf("%s: boot failed after %d retries and there is no rescue boot configuration, stopping retries", descr, retry)
+		return "#!ipxe\n" + stop + "\n", false, nil
+	}
+	log.Printf("%s: boot failed after %d retries, falling back to rescue boot configuration %s", descr, retry, name)
+	// The node's own parameters do not apply to the rescue image.
+	sp.params = ""
+	script, err = buildBootScript(ctx, bd, sp, stop, comp.Role, comp.SubRole, descr)
+	return script, err == nil, err
+}
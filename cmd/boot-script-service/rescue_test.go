@@ -0,0 +1,104 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+func TestRescueRetryLimit(t *testing.T) {
+	savedRetries, savedNodes, savedRoles := rescueRetries, rescueNodeLimits, rescueRoleLimits
+	defer func() { rescueRetries, rescueNodeLimits, rescueRoleLimits = savedRetries, savedNodes, savedRoles }()
+	rescueRetries = 3
+	rescueNodeLimits, _ = parseRoleLimits("x0c0s1b0n0=7")
+	rescueRoleLimits, _ = parseRoleLimits("Compute=5, application=1")
+
+	node := func(id, role string) SMComponent {
+		return SMComponent{Component: base.Component{ID: id, Role: role}}
+	}
+	for _, tt := range []struct {
+		comp     SMComponent
+		expected uint
+	}{
+		{node("x0c0s1b0n0", "Compute"), 7},
+		{node("x0c0s2b0n0", "Compute"), 5},
+		{node("x0c0s2b0n0", "compute"), 5},
+		{node("x0c0s3b0n0", "Application"), 1},
+		{node("x0c0s4b0n0", "Storage"), 3},
+		{node("x0c0s4b0n0", ""), 3},
+	} {
+		if limit := rescueRetryLimit(tt.comp); limit != tt.expected {
+			t.Errorf("Node %s with role %q: got limit %d, expected %d", tt.comp.ID, tt.comp.Role, limit, tt.expected)
+		}
+	}
+}
+
+func TestBootscriptGetRescue(t *testing.T) {
+	savedRetries := rescueRetries
+	defer func() { rescueRetries = savedRetries }()
+	rescueRetries = 2
+
+	const node = "x0c0s5b0n0"
+	for _, bp := range []bssTypes.BootParams{
+		{Hosts: []string{node}, Kernel: "/test/broken/vmlinuz", Params: "root=broken"},
+		{Hosts: []string{rescueTag + "-" + node}, Kernel: "/test/rescue/vmlinuz", Params: "rescue=1"},
+	} {
+		if err, _ := Store(bp); err != nil {
+			t.Fatalf("Unable to store boot parameters: %v", err)
+		}
+	}
+	defer Remove(bssTypes.BootParams{Hosts: []string{node, rescueTag + "-" + node}})
+
+	get := func(retry string) string {
+		rr := httptest.NewRecorder()
+		BootscriptGet(rr, httptest.NewRequest(http.MethodGet, "/boot/v1/bootscript?name="+node+"&retry="+retry, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Retry %s returned %d: %s", retry, rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	if script := get("1"); !strings.Contains(script, "/test/broken/vmlinuz") || !strings.Contains(script, "&retry=2") {
+		t.Errorf("Node below the retry limit did not get its own boot script: %s", script)
+	}
+	script := get("2")
+	if !strings.Contains(script, "/test/rescue/vmlinuz") || !strings.Contains(script, "rescue=1") ||
+		strings.Contains(script, "root=broken") || strings.Contains(script, "chain ") {
+		t.Errorf("Unexpected rescue boot script: %s", script)
+	}
+	if ts, err := getEndpointAccessed(node, bssTypes.EndpointTypeRescue); err != nil || ts == 0 {
+		t.Errorf("Rescue fallback not recorded in endpoint history: %d, %v", ts, err)
+	}
+
+	Remove(bssTypes.BootParams{Hosts: []string{rescueTag + "-" + node}})
+	script = get("3")
+	if strings.Contains(script, "kernel ") || strings.Contains(script, "chain ") || !strings.Contains(script, "exit") {
+		t.Errorf("Node without a rescue configuration did not stop retrying: %s", script)
+	}
+}
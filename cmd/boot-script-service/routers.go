@@ -37,19 +37,17 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
-	net_url "net/url"
 	"os"
+	"strings"
 	"time"
 
 	base "github.com/Cray-HPE/hms-base"
 	"github.com/OpenCHAMI/jwtauth/v5"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
-	"github.com/hashicorp/go-retryablehttp"
-	openchami_authenticator "github.com/openchami/chi-middleware/auth"
 	openchami_logger "github.com/openchami/chi-middleware/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	zlog "github.com/rs/zerolog/log"
 )
@@ -58,62 +56,136 @@ const (
 	baseEndpoint     = "/boot/v1"
 	notifierEndpoint = baseEndpoint + "/scn"
 	// We don't use the baseEndpoint here because cloud-init doesn't like them
-	metaDataRoute  = "/meta-data"
-	userDataRoute  = "/user-data"
-	phoneHomeRoute = "/phone-home"
+	metaDataRoute      = "/meta-data"
+	userDataRoute      = "/user-data"
+	vendorDataRoute    = "/vendor-data"
+	networkConfigRoute = "/network-config"
+	phoneHomeRoute     = "/phone-home"
 )
 
 var (
 	tokenAuth *jwtauth.JWTAuth
 )
 
-func initHandlers() *chi.Mux {
+// newRouter returns a router with the middleware common to all listeners.
+func newRouter() *chi.Mux {
 	// Setup logger
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	logger := zlog.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
 	router := chi.NewRouter()
+	router.Use(tracingMiddleware)
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.StripSlashes)
 	router.Use(openchami_logger.OpenCHAMILogger(logger))
-	router.Use(middleware.Timeout(60 * time.Second))
-	if jwksURL != "" {
-		router.Group(func(r chi.Router) {
-			r.Use(
-				jwtauth.Verifier(tokenAuth),
-				openchami_authenticator.AuthenticatorWithRequiredClaims(tokenAuth, []string{"sub", "iss", "aud"}),
-			)
+	router.Use(requestTimeout(60 * time.Second))
+	return router
+}
 
-			// protected routes if using auth
-			r.HandleFunc(baseEndpoint+"/", Index)
-			r.HandleFunc(baseEndpoint+"/bootparameters", bootParameters)
+// requestTimeout cancels requests after d, except for event streams, which
+// stay open until the client goes away or BSS shuts down.
+func requestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	timeout := middleware.Timeout(d)
+	return func(next http.Handler) http.Handler {
+		limited := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.TrimSuffix(r.URL.Path, "/") == eventsRoute {
+				next.ServeHTTP(w, r)
+			} else {
+				limited.ServeHTTP(w, r)
+			}
 		})
-	} else {
-		// public routes without auth
-		router.HandleFunc(baseEndpoint+"/", Index)
-		router.HandleFunc(baseEndpoint+"/bootparameters", bootParameters)
 	}
-	// every thing else is public
+}
+
+// addBootRoutes adds the routes used by booting nodes, which can never
+// authenticate, along with the service status routes and probes.
+func addBootRoutes(router chi.Router) {
+	// probes
+	router.Get(livezRoute, livezGet)
+	router.Get(readyzRoute, readyzGet)
 	// boot
 	router.HandleFunc(baseEndpoint+"/bootscript", bootScript)
-	router.HandleFunc(baseEndpoint+"/hosts", hosts)
-	router.HandleFunc(baseEndpoint+"/dumpstate", dumpstate)
 	router.HandleFunc(baseEndpoint+"/service/status", serviceStatusResponse)
 	router.HandleFunc(baseEndpoint+"/service/status/all", service)
 	router.HandleFunc(baseEndpoint+"/service/version", serviceVersionResponse)
 	router.HandleFunc(baseEndpoint+"/service/hsm", serviceHSMResponse)
 	router.HandleFunc(baseEndpoint+"/service/storage/status", serviceStorageResponse)
 	// cloud-init
-	router.HandleFunc(metaDataRoute, metaDataGet)
-	router.HandleFunc(userDataRoute, userDataGet)
-	router.HandleFunc(phoneHomeRoute, phoneHomePost)
+	router.HandleFunc(metaDataRoute, countCloudInit("meta-data", metaDataGet))
+	router.HandleFunc(userDataRoute, countCloudInit("user-data", userDataGet))
+	router.HandleFunc(vendorDataRoute, countCloudInit("vendor-data", vendorDataGet))
+	router.HandleFunc(networkConfigRoute, countCloudInit("network-config", networkConfigGet))
+	router.HandleFunc(phoneHomeRoute, countCloudInit("phone-home", phoneHomePost))
+}
+
+// initPlainHandlers returns the router for the plain HTTP listener used
+// alongside HTTPS.  It only serves what iPXE and cloud-init clients need.
+func initPlainHandlers() *chi.Mux {
+	router := newRouter()
+	router.Use(servedPlain)
+	addBootRoutes(router)
+	return router
+}
+
+func initHandlers() *chi.Mux {
+	router := newRouter()
+	if authEnabled() {
+		router.Group(func(r chi.Router) {
+			r.Use(authenticate)
+
+			// protected routes if using auth
+			r.HandleFunc(baseEndpoint+"/", Index)
+			r.With(requireScopeByMethod(authReadScope, authWriteScope)).
+				HandleFunc(baseEndpoint+"/bootparameters", bootParameters)
+			r.With(requireScopeByMethod(authReadScope, authWriteScope)).
+				HandleFunc(baseEndpoint+"/discovered", discovered)
+			r.With(requireScope(authAdminScope)).
+				HandleFunc(baseEndpoint+"/webhooks", webhooksHandler)
+			r.With(requireScope(authAdminScope)).
+				HandleFunc(baseEndpoint+"/webhooks/dead-letters", webhookDeadLetters)
+			r.With(requireScope(authReadScope)).
+				HandleFunc(eventsRoute, eventsGet)
+			if authProtectReads {
+				// HostsPost forces an HSM refresh and dumpstate exposes the
+				// kernel parameters of every node, so both need admin scope.
+				r.With(requireScopeByMethod(authReadScope, authAdminScope)).
+					HandleFunc(baseEndpoint+"/hosts", hosts)
+				r.With(requireScope(authAdminScope)).
+					HandleFunc(baseEndpoint+"/dumpstate", dumpstate)
+				r.With(requireScope(authReadScope)).
+					HandleFunc(baseEndpoint+"/endpoint-history", endpointHistoryGet)
+				r.With(requireScope(authReadScope)).
+					HandleFunc(baseEndpoint+"/boot-sessions", bootSessionsGet)
+				r.With(requireScope(authReadScope)).
+					Handle(metricsRoute, promhttp.Handler())
+			}
+		})
+	} else {
+		// public routes without auth
+		router.HandleFunc(baseEndpoint+"/", Index)
+		router.HandleFunc(baseEndpoint+"/bootparameters", bootParameters)
+		router.HandleFunc(baseEndpoint+"/discovered", discovered)
+		router.HandleFunc(baseEndpoint+"/webhooks", webhooksHandler)
+		router.HandleFunc(baseEndpoint+"/webhooks/dead-letters", webhookDeadLetters)
+		router.HandleFunc(eventsRoute, eventsGet)
+	}
+	// Read endpoints are not served at all if they are to be protected but
+	// there is no way to authenticate, which startup rejects anyway.
+	if !authProtectReads {
+		router.HandleFunc(baseEndpoint+"/hosts", hosts)
+		router.HandleFunc(baseEndpoint+"/dumpstate", dumpstate)
+		router.HandleFunc(baseEndpoint+"/endpoint-history", endpointHistoryGet)
+		router.HandleFunc(baseEndpoint+"/boot-sessions", bootSessionsGet)
+		router.Handle(metricsRoute, promhttp.Handler())
+	}
+	// every thing else is public
+	addBootRoutes(router)
 	// notifications
 	router.HandleFunc(notifierEndpoint, scn)
-	// endpoint-access
-	router.HandleFunc(baseEndpoint+"/endpoint-history", endpointHistoryGet)
 	return router
 }
 
@@ -144,9 +216,6 @@ func bootParameters(w http.ResponseWriter, r *http.Request) {
 }
 
 func bootScript(w http.ResponseWriter, r *http.Request) {
-	if bootscriptNotifyURL != "" {
-		go notifyTarget(bootscriptNotifyURL, r.RemoteAddr)
-	}
 	switch r.Method {
 	case http.MethodGet:
 		BootscriptGet(w, r)
@@ -211,6 +280,24 @@ func userDataGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func vendorDataGet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		vendorDataGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
+func networkConfigGet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		networkConfigGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
 func phoneHomePost(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -220,20 +307,66 @@ func phoneHomePost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func endpointHistoryGet(w http.ResponseWriter, r *http.Request) {
+func discovered(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		endpointHistoryGetAPI(w, r)
+		discoveredGetAPI(w, r)
+	case http.MethodPost:
+		discoveredPostAPI(w, r)
+	case http.MethodDelete:
+		discoveredDeleteAPI(w, r)
+	default:
+		sendAllowable(w, "GET,POST,DELETE")
+	}
+}
+
+func webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		webhooksGetAPI(w, r)
+	case http.MethodPost:
+		webhooksPostAPI(w, r)
+	case http.MethodDelete:
+		webhooksDeleteAPI(w, r)
+	default:
+		sendAllowable(w, "GET,POST,DELETE")
+	}
+}
+
+func webhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		webhookDeadLettersGetAPI(w, r)
+	case http.MethodDelete:
+		webhookDeadLettersDeleteAPI(w, r)
+	default:
+		sendAllowable(w, "GET,DELETE")
+	}
+}
+
+func eventsGet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		eventsGetAPI(w, r)
 	default:
 		sendAllowable(w, "GET")
 	}
 }
 
-func notifyTarget(url string, data string) {
-	resp, err := retryablehttp.PostForm(url, net_url.Values{"data": {data}})
-	if err != nil {
-		log.Printf("WARNING: HTTP POST of \"%v\" failed: %v\n", data, err)
-		return
+func bootSessionsGet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bootSessionsGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
+	}
+}
+
+func endpointHistoryGet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		endpointHistoryGetAPI(w, r)
+	default:
+		sendAllowable(w, "GET")
 	}
-	defer resp.Body.Close()
 }
@@ -38,7 +38,6 @@ import (
 	"net/http"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -197,9 +196,18 @@ func stateChangeNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("Received state change notification: %s", p)
-	// We simply store a timestamp.  This is the approx. time that SM updated
-	// something.  The next time BSS needs to check a host, it will see if it
-	// is up-to-date, and if not, it will fetch new SM data at that time.
+	now := time.Now().Unix()
+	if applyStateChange(scn, now) {
+		scnUpdates.WithLabelValues("applied").Inc()
+	} else {
+		scnUpdates.WithLabelValues("refetch").Inc()
+	}
+	// The state of the components the notification names is fetched from
+	// HSM and applied to our copy of the HSM state when possible.  The rest
+	// of the notification is not used, as this route is not authenticated.  Other BSS instances, and this one if the change could not be
+	// applied, learn of it from a timestamp.  This is the approx. time that SM
+	// updated something.  The next time BSS needs to check a host, it will see
+	// if it is up-to-date, and if not, it will fetch new SM data at that time.
 	// This has the advantage of not needing to fetch this data if BSS doesn't
 	// need it.  Additional updates to SM can then be made without BSS
 	// fetching the intermediate state.  The disadvantage is that it needs to
@@ -207,31 +215,30 @@ func stateChangeNotification(w http.ResponseWriter, r *http.Request) {
 	// will respond to immediate requests with a chained response to have the
 	// requester try again after a short delay, giving BSS time to retrieve
 	// the SM data.
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
-	if err = kvstore.Store(UpdateTimestampKey, timestamp); err != nil {
-		log.Printf("Failed to store update timestamp %s to key %s: %s",
-			timestamp, UpdateTimestampKey, err)
+	if stateSig == nil {
+		return
+	}
+	if err = stateSig.publish(now); err != nil {
+		log.Printf("Failed to publish state change timestamp %d: %s", now, err)
 	}
 }
 
-// Checks the current timestamp of this running image vs. the timestamp in etcd.
-// Will do a refresh if needed.
+// Checks the current timestamp of this running image vs. the latest state
+// change published to the datastore.  Will do a refresh if needed.
 func checkState(force bool) bool {
 	var (
-		timestamp string
-		exists    bool
-		ts        int64
-		err       error
+		exists bool
+		ts     int64
+		err    error
 	)
 	if force {
 		ts = -1
-	} else {
-		timestamp, exists, _ = kvstore.Get(UpdateTimestampKey)
-		ts, err = strconv.ParseInt(timestamp, 0, 64)
+	} else if stateSig != nil {
+		ts, exists, err = stateSig.latest()
 	}
 	if force || exists && err == nil && smTimeStamp < ts {
-		debugf("force: %t, exists: %t, timestamp = %s, ts = %d, smTimeStamp = %d", force, exists, timestamp, ts, smTimeStamp)
-		go refreshState(ts)
+		debugf("force: %t, exists: %t, ts = %d, smTimeStamp = %d", force, exists, ts, smTimeStamp)
+		goBackground(func() { refreshState(ts) })
 		return true
 	}
 	return false
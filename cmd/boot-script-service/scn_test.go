@@ -0,0 +1,124 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+)
+
+// stateInventory is the test inventory with the current state of some
+// components overridden, as HSM returns after a state change.
+type stateInventory struct {
+	staticInventory
+	states []base.Component
+}
+
+func (si stateInventory) ComponentStates(ctx context.Context, ids []string) ([]base.Component, error) {
+	return si.states, nil
+}
+
+func useComponentStates(t *testing.T, states ...base.Component) {
+	saved := inventory
+	inventory = stateInventory{staticInventory{data: []byte(state_manager_data_temp)}, states}
+	t.Cleanup(func() { inventory = saved })
+}
+
+func TestStateChangeNotificationApplied(t *testing.T) {
+	disabled := false
+	useComponentStates(t, base.Component{ID: "x0c0s2b0n0", State: "Ready", Enabled: &disabled, Role: "Application", SubRole: "UAN"})
+	smMutex.Lock()
+	saved, savedTime, savedSync := smData, smTimeStamp, smFullSyncTime
+	smFullSyncTime = time.Now()
+	smMutex.Unlock()
+	defer func() {
+		smMutex.Lock()
+		setState(saved)
+		smTimeStamp, smFullSyncTime = savedTime, savedSync
+		smMutex.Unlock()
+	}()
+
+	// The role in the notification is not trusted, the one from HSM is used.
+	body := `{"Components":["x0c0s2b0n0"],"Role":"Management","Enabled":false}`
+	rr := httptest.NewRecorder()
+	stateChangeNotification(rr, httptest.NewRequest(http.MethodPost, "/boot/v1/scn", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("stateChangeNotification returned %d", rr.Code)
+	}
+
+	c, ok := FindSMCompByName("x0c0s2b0n0")
+	if !ok || c.Role != "Application" || c.SubRole != "UAN" || c.Enabled == nil || *c.Enabled {
+		t.Errorf("State change was not applied: %+v", c)
+	}
+	if c, _ = FindSMCompByNid(12); c.Role != "Application" {
+		t.Errorf("NID index was not updated: %+v", c)
+	}
+	for _, orig := range saved.Components {
+		if orig.ID == "x0c0s2b0n0" && orig.Role != "Compute" {
+			t.Errorf("State change modified the previous snapshot")
+		}
+	}
+}
+
+func TestApplyStateChangeFallback(t *testing.T) {
+	useComponentStates(t,
+		base.Component{ID: "x9c0s0b0n0", State: "Ready"},
+		base.Component{ID: "x0c0s3b0n0", State: "Populated"},
+		base.Component{ID: "x0c0s4b0n0", State: "Ready"})
+	smMutex.Lock()
+	saved, savedSync := smData, smFullSyncTime
+	smMutex.Unlock()
+	defer func() {
+		smMutex.Lock()
+		smFullSyncTime = savedSync
+		smMutex.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		scn      Scn
+		lastSync time.Time
+	}{
+		{"unknown component", Scn{Components: []string{"x9c0s0b0n0"}, State: "Ready"}, time.Now()},
+		{"not returned by HSM", Scn{Components: []string{"x0c0s2b0n0"}, State: "Ready"}, time.Now()},
+		{"hardware change", Scn{Components: []string{"x0c0s3b0n0"}, State: "Ready"}, time.Now()},
+		{"resync due", Scn{Components: []string{"x0c0s4b0n0"}, State: "Ready"}, time.Now().Add(-2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		smMutex.Lock()
+		smFullSyncTime = tt.lastSync
+		smMutex.Unlock()
+		if applyStateChange(tt.scn, time.Now().Unix()) {
+			t.Errorf("%s: state change was applied instead of requiring a full fetch", tt.name)
+		}
+		if getState() != saved {
+			t.Errorf("%s: HSM state snapshot was replaced", tt.name)
+		}
+	}
+}
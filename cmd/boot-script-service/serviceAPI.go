@@ -61,8 +61,10 @@ func serviceStatusAPI(w http.ResponseWriter, req *http.Request) {
 		strings.Contains(strings.ToUpper(req.URL.Path), "ALL") {
 		bssStatus.HSMStatus = "connected"
 		url := smBaseURL + "/service/values/class"
-		rsp, err := smClient.Get(url)
-		if err != nil {
+		if smClient == nil {
+			// Node inventory does not come from HSM.
+			bssStatus.HSMStatus = "unused"
+		} else if rsp, err := smClient.Get(url); err != nil {
 			httpStatus = http.StatusInternalServerError
 			bssStatus.HSMStatus = "error"
 			log.Printf("Cannot connect to HSM: %s", err)
@@ -158,8 +160,10 @@ func serviceHSMResponse(w http.ResponseWriter, req *http.Request) {
 
 	bssStatus.HSMStatus = "connected"
 	url := smBaseURL + "/service/values/class"
-	rsp, err := smClient.Get(url)
-	if err != nil {
+	if smClient == nil {
+		// Node inventory does not come from HSM.
+		bssStatus.HSMStatus = "unused"
+	} else if rsp, err := smClient.Get(url); err != nil {
 		httpStatus = http.StatusInternalServerError
 		bssStatus.HSMStatus = "error"
 		log.Printf("Cannot connect to HSM: %v", err)
@@ -0,0 +1,175 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	shutdownDelay   = uint(0)  // Seconds to report not-ready before closing listeners
+	shutdownTimeout = uint(30) // Seconds to drain requests and background work
+
+	// Set once a shutdown signal has been received so /readyz fails
+	shuttingDown atomic.Bool
+
+	// Tracks goroutines, such as boot script notifications and HSM state
+	// refreshes, that should finish before the process exits.
+	backgroundTasks taskGroup
+)
+
+// taskGroup counts running goroutines.  Unlike with a sync.WaitGroup, tasks
+// may be started while another goroutine waits for the group, as requests
+// that are still draining and retry timers do during shutdown.
+type taskGroup struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // Closed when n drops to zero
+}
+
+func (g *taskGroup) add() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.n == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.n++
+}
+
+func (g *taskGroup) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n--
+	if g.n == 0 {
+		close(g.idle)
+	}
+}
+
+// idleChan returns a channel that is closed once no tasks are running.
+func (g *taskGroup) idleChan() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.n == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return g.idle
+}
+
+// Wait waits until no tasks are running.
+func (g *taskGroup) Wait() {
+	<-g.idleChan()
+}
+
+// goBackground runs f in a goroutine that shutdown waits for.
+func goBackground(f func()) {
+	backgroundTasks.add()
+	go func() {
+		defer backgroundTasks.done()
+		f()
+	}()
+}
+
+// waitBackground waits for background tasks until ctx is done.  Tasks that
+// are started by other tasks while it waits are waited for as well.
+func waitBackground(ctx context.Context) error {
+	select {
+	case <-backgroundTasks.idleChan():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveUntilSignalled runs the servers until SIGINT or SIGTERM is received and
+// then drains them. Each server is started by its own start function, e.g.
+// ListenAndServe or ListenAndServeTLS. If a server fails to start, the others
+// are drained and the error is returned.
+func serveUntilSignalled(servers []*http.Server, starts []func() error) error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	failed := make(chan error, len(starts))
+	for _, start := range starts {
+		go func(start func() error) {
+			if err := start(); !errors.Is(err, http.ErrServerClosed) {
+				failed <- err
+			}
+		}(start)
+	}
+
+	var serveErr error
+	select {
+	case sig := <-stop:
+		log.Printf("Received %v, shutting down", sig)
+	case serveErr = <-failed:
+		log.Printf("Server failed, shutting down: %v", serveErr)
+	}
+	shutdown(servers)
+	return serveErr
+}
+
+// shutdown marks the service not ready, waits shutdownDelay so load balancers
+// stop sending traffic, then stops accepting connections and waits up to
+// shutdownTimeout for in-flight requests and background tasks to complete.
+func shutdown(servers []*http.Server) {
+	shuttingDown.Store(true)
+	if shutdownDelay > 0 {
+		log.Printf("Reporting not ready for %ds before closing listeners", shutdownDelay)
+		time.Sleep(time.Duration(shutdownDelay) * time.Second)
+	}
+
+	// Event streams never finish on their own, so end them first.
+	if events != nil {
+		events.close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(shutdownTimeout)*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Printf("WARNING: %s did not drain cleanly: %v", server.Addr, err)
+			}
+		}(server)
+	}
+	wg.Wait()
+
+	if err := waitBackground(ctx); err != nil {
+		log.Printf("WARNING: abandoning pending notifications and state refreshes: %v", err)
+	}
+	log.Printf("Shutdown complete")
+}
@@ -0,0 +1,111 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrains(t *testing.T) {
+	defer shuttingDown.Store(false)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})}
+	go server.Serve(listener)
+
+	var notified atomic.Bool
+	goBackground(func() {
+		time.Sleep(300 * time.Millisecond)
+		notified.Store(true)
+	})
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	shutdown([]*http.Server{server})
+	if b := <-body; b != "done" {
+		t.Errorf("In-flight request was not drained: %s", b)
+	}
+	if !notified.Load() {
+		t.Errorf("Shutdown returned before background tasks finished")
+	}
+
+	rr := httptest.NewRecorder()
+	readyzGet(rr, httptest.NewRequest(http.MethodGet, readyzRoute, nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz returned %d while shutting down, expected %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestWaitBackgroundWhileStarting(t *testing.T) {
+	var finished atomic.Int32
+	goBackground(func() {
+		time.Sleep(50 * time.Millisecond)
+		// Retries start new tasks while shutdown waits.
+		goBackground(func() {
+			time.Sleep(50 * time.Millisecond)
+			finished.Add(1)
+		})
+		finished.Add(1)
+	})
+	waiting := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		waiting <- waitBackground(ctx)
+	}()
+	for i := 0; i < 100; i++ {
+		goBackground(func() {})
+	}
+	if err := <-waiting; err != nil {
+		t.Fatalf("waitBackground failed: %v", err)
+	}
+	if n := finished.Load(); n != 2 {
+		t.Errorf("waitBackground returned with %d of 2 tasks finished", n)
+	}
+}
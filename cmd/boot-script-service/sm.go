@@ -30,7 +30,7 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -39,14 +39,17 @@ import (
 	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	base "github.com/Cray-HPE/hms-base"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
 	"github.com/OpenCHAMI/smd/v2/pkg/rf"
 	"github.com/OpenCHAMI/smd/v2/pkg/sm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 const (
@@ -71,18 +74,111 @@ var (
 	smMutex     sync.Mutex
 	smData      *SMData
 	smClient    *OAuthClient
-	smDataMap   map[string]SMComponent
+	smIndex     *smDataIndex
 	smBaseURL   string
-	smJSONFile  string
 	smTimeStamp int64
+
+	// When HSM state was last fetched in full, as opposed to being updated
+	// from state change notifications.
+	smFullSyncTime time.Time
+
+	hsmResyncInterval = uint(3600) // Seconds after which a state change notification triggers a full fetch
 )
 
-func makeSmMap(state *SMData) map[string]SMComponent {
-	m := make(map[string]SMComponent)
+// smDataIndex holds lookup tables for an HSM state snapshot. It is built
+// once per snapshot so that lookups by MAC, NID, xname, FQDN, role, or IP do
+// not have to scan every component. Where several components share a key,
+// they are listed in snapshot order.
+type smDataIndex struct {
+	byID   map[string][]SMComponent
+	byMAC  map[string][]SMComponent
+	byNID  map[int64][]SMComponent
+	byFQDN map[string][]SMComponent
+	byRole map[string][]SMComponent
+	byIP   map[string]string
+	addrs  map[string][]compAddress
+}
+
+// compAddress is an IP address of a component's interface, from the HSM
+// ethernet interfaces.
+type compAddress struct {
+	IP      string
+	Network string
+	MAC     string
+}
+
+// normalizeMAC returns a MAC address in lower case, colon separated form so
+// that, e.g., "00-1E-67-E3-46-93" and "00:1e:67:e3:46:93" index alike.
+func normalizeMAC(mac string) string {
+	if m := ensureLegalMAC(mac); m != badMAC {
+		return m
+	}
+	return strings.ToLower(mac)
+}
+
+// normalizeIP returns the canonical form of an IP address.
+func normalizeIP(ip string) string {
+	if p := net.ParseIP(ip); p != nil {
+		return p.String()
+	}
+	return ip
+}
+
+func makeSmIndex(state *SMData) *smDataIndex {
+	idx := &smDataIndex{
+		byID:   make(map[string][]SMComponent),
+		byMAC:  make(map[string][]SMComponent),
+		byNID:  make(map[int64][]SMComponent),
+		byFQDN: make(map[string][]SMComponent),
+		byRole: make(map[string][]SMComponent),
+		byIP:   make(map[string]string),
+		addrs:  make(map[string][]compAddress),
+	}
+	if state == nil {
+		return idx
+	}
 	for _, v := range state.Components {
-		m[v.ID] = v
+		idx.byID[v.ID] = append(idx.byID[v.ID], v)
+		for _, m := range v.Mac {
+			key := normalizeMAC(m)
+			idx.byMAC[key] = append(idx.byMAC[key], v)
+		}
+		if nid, err := v.NID.Int64(); err == nil {
+			idx.byNID[nid] = append(idx.byNID[nid], v)
+		}
+		if v.Fqdn != "" {
+			key := strings.ToLower(v.Fqdn)
+			idx.byFQDN[key] = append(idx.byFQDN[key], v)
+		}
+		if v.Role != "" {
+			key := strings.ToLower(v.Role)
+			idx.byRole[key] = append(idx.byRole[key], v)
+		}
+	}
+	for ip, e := range state.IPAddrs {
+		idx.byIP[normalizeIP(ip)] = e.CompID
+		a := compAddress{IP: normalizeIP(ip), MAC: normalizeMAC(e.MACAddr)}
+		for _, m := range e.IPAddrs {
+			if normalizeIP(m.IPAddr) == a.IP {
+				a.Network = m.Network
+			}
+		}
+		idx.addrs[e.CompID] = append(idx.addrs[e.CompID], a)
+	}
+	for _, a := range idx.addrs {
+		sort.Slice(a, func(i, j int) bool { return a[i].IP < a[j].IP })
 	}
-	return m
+	return idx
+}
+
+// setState installs a new HSM state snapshot. The caller must hold smMutex
+// unless no other goroutine can be using the state yet.
+func setState(state *SMData) {
+	smData = state
+	smIndex = makeSmIndex(state)
+	hsmSnapshotLoaded.Store(true)
+	knownNodes.Set(float64(len(state.Components)))
+	invalidateBootCache()
 }
 
 func TestSMAuthEnabled(retryCount, retryInterval uint64) (authEnabled bool, err error) {
@@ -175,23 +271,22 @@ func SmOpen(base, options string) error {
 		// and used as state manager data.  This allows for testing of a larger
 		// set of nodes than is currently readily available.
 		debugf("Setting internal HSM data")
-		buf := bytes.NewBufferString(state_manager_data_temp)
-		dec := json.NewDecoder(buf)
-		var comps SMData
-		err = dec.Decode(&comps)
+		inventory = staticInventory{data: []byte(state_manager_data_temp)}
+		comps, err := inventory.Components(context.Background())
 		if err != nil {
 			debugf("Internal data conversion failure: %v", err)
+			comps = &SMData{}
 		}
-		smData = &comps
-		smDataMap = makeSmMap(smData)
+		setState(comps)
+		smFullSyncTime = time.Now()
 		return nil
 	}
 	if u.Scheme == "file" {
 		// The file: interface allows for another method of testing with a
 		// little more flexibilty than the mem: interface, but not quite as
 		// stand-alone.
-		smJSONFile = u.Path
-		debugf("Setting externel HSM data file: %s", smJSONFile)
+		debugf("Setting externel HSM data file: %s", u.Path)
+		inventory = jsonInventory{path: u.Path}
 		return nil
 	}
 	https := u.Scheme == "https"
@@ -216,7 +311,9 @@ func SmOpen(base, options string) error {
 		smClient.Transport = trans
 		log.Printf("WARNING: insecure https connection to state manager service\n")
 	}
+	smClient.Transport = tracedTransport(smClient.Transport)
 	smBaseURL = base + "/hsm/v2"
+	inventory = hsmInventory{}
 	log.Printf("Accessing state manager via %s\n", smBaseURL)
 
 	var smAuthEnabled bool
@@ -276,7 +373,7 @@ func ensureLegalMAC(mac string) string {
 	return hw.String()
 }
 
-func getStateFromHSM() *SMData {
+func getStateFromHSM(ctx context.Context) *SMData {
 	if smClient != nil {
 		var headers map[string][]string
 		var body []byte
@@ -298,7 +395,7 @@ func getStateFromHSM() *SMData {
 		log.Printf("Retrieving state info from %s", smBaseURL)
 		url := smBaseURL + "/State/Components?type=Node"
 		debugf("url: %s, smClient: %v\n", url, smClient)
-		req, rerr := http.NewRequest(http.MethodGet, url, nil)
+		req, rerr := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if rerr != nil {
 			log.Printf("Failed to create HTTP request for '%s': %v", url, rerr)
 			return nil
@@ -334,7 +431,7 @@ func getStateFromHSM() *SMData {
 		}
 
 		url = smBaseURL + "/Inventory/ComponentEndpoints?type=Node"
-		req, rerr = http.NewRequest(http.MethodGet, url, nil)
+		req, rerr = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if rerr != nil {
 			log.Printf("Failed to create HTTP request for '%s': %v", url, rerr)
 			return nil
@@ -414,7 +511,7 @@ func getStateFromHSM() *SMData {
 
 		//ip address
 		url = smBaseURL + "/Inventory/EthernetInterfaces?type=Node"
-		req, rerr = http.NewRequest(http.MethodGet, url, nil)
+		req, rerr = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if rerr != nil {
 			log.Printf("Failed to create HTTP request for '%s': %v", url, rerr)
 			return nil
@@ -481,37 +578,70 @@ func getStateFromHSM() *SMData {
 	return nil
 }
 
-func getStateFromFile() (ret *SMData) {
-	if smJSONFile != "" {
-		log.Printf("Retrieving state info from %s", smJSONFile)
-		debugf("Reading HSM info from %s", smJSONFile)
-		f, err := os.Open(smJSONFile)
-		if err != nil {
-			log.Printf("Error: %v\n", err)
-		} else {
-			defer f.Close()
-			var comps SMData
-			dec := json.NewDecoder(f)
-			err = dec.Decode(&comps)
-			if err != nil {
-				log.Printf("Error: %v\n", err)
-			} else {
-				ret = &comps
-			}
+// getComponentStatesFromHSM returns the current state of the given
+// components, without their endpoints or interfaces.
+func getComponentStatesFromHSM(ctx context.Context, ids []string) ([]base.Component, error) {
+	if smClient == nil {
+		return nil, fmt.Errorf("no HSM client")
+	}
+	authEnabled, err := TestSMAuthEnabled(authRetryCount, authRetryWait)
+	if err != nil {
+		return nil, fmt.Errorf("failed to test if SM auth is enabled: %v", err)
+	}
+	q := url.Values{"id": ids}
+	u := smBaseURL + "/State/Components?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authEnabled {
+		if err = smClient.JWTTestAndRefresh(); err != nil {
+			return nil, fmt.Errorf("failed to refresh JWT: %v", err)
 		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
 	}
-	return ret
+	req.Close = true
+	base.SetHTTPUserAgent(req, serviceName)
+	r, err := smClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, r.Status)
+	}
+	var comps base.ComponentArray
+	if err = json.NewDecoder(r.Body).Decode(&comps); err != nil {
+		return nil, err
+	}
+	ret := make([]base.Component, 0, len(comps.Components))
+	for _, c := range comps.Components {
+		ret = append(ret, *c)
+	}
+	return ret, nil
 }
 
 func getStateInfo() (ret *SMData) {
-	ret = getStateFromHSM()
-	if ret == nil {
-		ret = getStateFromFile()
+	// State is shared by all requests, so its retrieval gets its own trace.
+	ctx, span := tracer.Start(context.Background(), "hsm.getState")
+	defer span.End()
+	if inventory == nil {
+		return nil
+	}
+	ret, err := inventory.Components(ctx)
+	if err != nil {
+		log.Printf("Failed to retrieve node inventory from %s: %v", inventory.Name(), err)
+		span.SetStatus(codes.Error, "inventory retrieval failed")
+		return nil
+	}
+	ret = mergeAdopted(ret)
+	if ret != nil {
+		span.SetAttributes(attribute.Int("bss.hsm.components", len(ret.Components)))
 	}
 	return ret
 }
 
-func protectedGetState(ts int64) (*SMData, map[string]SMComponent) {
+func protectedGetState(ts int64) (*SMData, *smDataIndex) {
 	smMutex.Lock()
 	defer smMutex.Unlock()
 	if ts < 0 || ts > smTimeStamp || smData == nil {
@@ -520,13 +650,112 @@ func protectedGetState(ts int64) (*SMData, map[string]SMComponent) {
 		} else {
 			smTimeStamp = ts
 		}
+		start := time.Now()
 		newSMData := getStateInfo()
 		if newSMData != nil {
-			smData = newSMData
-			smDataMap = makeSmMap(smData)
+			hsmRefreshDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
+			setState(newSMData)
+			smFullSyncTime = time.Now()
+			publishEvent(eventTypeHSMRefresh, nil, nil, bssTypes.StateRefreshEvent{
+				Time:       smFullSyncTime.Unix(),
+				Full:       true,
+				Components: len(newSMData.Components),
+			})
+		} else {
+			hsmRefreshDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		}
+	}
+	return smData, smIndex
+}
+
+// applyStateChange updates the current HSM state snapshot with the state of
+// the components a state change notification names, and marks the snapshot
+// current as of ts.  The notification comes from an unauthenticated route,
+// so only its component list is used: the state, enabled flag, software
+// status, role, and subrole of those components are fetched from the
+// inventory provider.  It returns false, leaving the snapshot untouched, if
+// HSM state needs to be fetched in full instead: when no snapshot has been
+// loaded, when the last full fetch is older than hsmResyncInterval, when the
+// provider cannot fetch some components, when the notification names a
+// component that is not in the snapshot, or when a component became empty or
+// populated, which may mean its hardware changed.
+func applyStateChange(scn Scn, ts int64) bool {
+	configMutex.RLock()
+	resync := time.Duration(hsmResyncInterval) * time.Second
+	configMutex.RUnlock()
+
+	fetcher, ok := inventory.(componentStateFetcher)
+	if !ok || len(scn.Components) == 0 || resync == 0 {
+		return false
+	}
+	smMutex.Lock()
+	current := smData != nil && smIndex != nil && time.Since(smFullSyncTime) <= resync
+	smMutex.Unlock()
+	if !current {
+		return false
+	}
+	ctx, span := tracer.Start(context.Background(), "hsm.getComponentStates")
+	states, err := fetcher.ComponentStates(ctx, scn.Components)
+	span.End()
+	if err != nil {
+		log.Printf("Failed to retrieve the state of %v from %s: %v", scn.Components, inventory.Name(), err)
+		return false
+	}
+	fetched := make(map[string]base.Component, len(states))
+	for _, c := range states {
+		fetched[c.ID] = c
+	}
+
+	smMutex.Lock()
+	defer smMutex.Unlock()
+	if smData == nil || smIndex == nil || time.Since(smFullSyncTime) > resync {
+		return false
+	}
+
+	// Readers use the current snapshot without holding smMutex, so the
+	// change is applied to a copy.
+	comps := make([]SMComponent, len(smData.Components))
+	copy(comps, smData.Components)
+	positions := make(map[string]int, len(comps))
+	for i, c := range comps {
+		if _, ok := positions[c.ID]; !ok {
+			positions[c.ID] = i
+		}
+	}
+	for _, id := range scn.Components {
+		i, ok := positions[id]
+		if !ok {
+			debugf("State change for %s, which is not in the HSM snapshot", id)
+			return false
+		}
+		f, ok := fetched[id]
+		if !ok {
+			debugf("State change for %s, which %s did not return", id, inventory.Name())
+			return false
+		}
+		if strings.EqualFold(f.State, "empty") || strings.EqualFold(f.State, "populated") {
+			return false
+		}
+		c := &comps[i]
+		c.State, c.Flag, c.Enabled, c.SwStatus = f.State, f.Flag, f.Enabled, f.SwStatus
+		c.Role, c.SubRole = f.Role, f.SubRole
+	}
+	setState(&SMData{Components: comps, IPAddrs: smData.IPAddrs})
+	if ts > smTimeStamp {
+		smTimeStamp = ts
+	}
+	var groups []string
+	for _, id := range scn.Components {
+		if r := comps[positions[id]].Role; r != "" {
+			groups = append(groups, r)
 		}
 	}
-	return smData, smDataMap
+	publishEvent(eventTypeHSMRefresh, scn.Components, groups, bssTypes.StateRefreshEvent{
+		Time:       time.Now().Unix(),
+		Components: len(comps),
+		Changed:    scn.Components,
+	})
+	return true
 }
 
 func getState() *SMData {
@@ -534,7 +763,7 @@ func getState() *SMData {
 	return data
 }
 
-func getStateAndMap() (*SMData, map[string]SMComponent) {
+func getStateAndIndex() (*SMData, *smDataIndex) {
 	return protectedGetState(0)
 }
 
@@ -543,71 +772,74 @@ func refreshState(ts int64) *SMData {
 	return data
 }
 
-func FindSMCompByMAC(mac string) (SMComponent, bool) {
-	state := getState()
-	for _, v := range state.Components {
-		if !strings.EqualFold(v.State, "empty") {
-			for _, m := range v.Mac {
-				if strings.EqualFold(mac, m) {
-					return v, true
-				}
-			}
-		}
+func getIndex() *smDataIndex {
+	_, idx := getStateAndIndex()
+	if idx == nil {
+		return makeSmIndex(nil)
 	}
-	return SMComponent{}, false
+	return idx
 }
 
-func FindSMCompByNameInCache(host string) (SMComponent, bool) {
-	_, stateMap := getStateAndMap()
-	if v, ok := stateMap[host]; ok {
-		return v, true
+// How often the inventory snapshot is refreshed for lookups by IP address.
+// We need to semi-frequently refresh this data in case IP addresses change
+// due to DHCP lease expirations. 10 minutes was chosen to start with as it
+// seems reasonable.
+const ipCacheEvictionTime = 10 * time.Minute
+
+func FindSMCompByMAC(mac string) (SMComponent, bool) {
+	for _, v := range nodeLookups().ByMAC(mac) {
+		if !strings.EqualFold(v.State, "empty") {
+			return v, true
+		}
 	}
 	return SMComponent{}, false
 }
 
 func FindSMCompByName(host string) (SMComponent, bool) {
 	debugf("Searching SM data for %s\n", host)
-	state := getState()
-	for i, v := range state.Components {
-		debugf("SM data[%d]: %v\n", i, v)
-		if v.ID == host {
-			return v, true
-		}
+	if comps := nodeLookups().ByName(host); len(comps) > 0 {
+		return comps[0], true
 	}
 	return SMComponent{}, false
 }
 
 func FindSMCompByNid(nid int) (SMComponent, bool) {
-	state := getState()
-	for _, v := range state.Components {
-		if vnid, err := v.NID.Int64(); err == nil && vnid == int64(nid) {
-			return v, true
-		}
+	if comps := nodeLookups().ByNID(int64(nid)); len(comps) > 0 {
+		return comps[0], true
 	}
 	return SMComponent{}, false
 }
 
+// FindSMCompsByFQDN returns every component with the given FQDN.
+func FindSMCompsByFQDN(fqdn string) []SMComponent {
+	return nodeLookups().ByFQDN(fqdn)
+}
+
+// FindSMAddresses returns the IP addresses of the named component's
+// interfaces.
+func FindSMAddresses(name string) []compAddress {
+	return getIndex().addrs[name]
+}
+
+// FindSMCompsByMAC returns every component with the given MAC address,
+// including empty slots.
+func FindSMCompsByMAC(mac string) []SMComponent {
+	return nodeLookups().ByMAC(mac)
+}
+
+// FindSMCompsByNid returns every component with the given NID.
+func FindSMCompsByNid(nid int) []SMComponent {
+	return nodeLookups().ByNID(int64(nid))
+}
+
+// FindSMCompsByRole returns every component with the given role and, unless
+// it is empty, subrole.
+func FindSMCompsByRole(role, subRole string) []SMComponent {
+	return nodeLookups().ByRole(role, subRole)
+}
+
 func FindXnameByIP(ip string) (string, bool) {
-	// This is how many minutes we subtract from time.Now().
-	// This will cause refreshState to refresh ever `cacheEvictionTime` minutes.
-	// 10 minutes was chosen to start with as it seems reasonable.
-	// We need to semi-frequently refresh this data in case IP addresses change
-	// due to DHCP lease expirations.
-	cacheEvictionTime := 10
-
-	currTime := time.Now()
-	ts := currTime.Add(time.Duration(-cacheEvictionTime) * time.Minute)
-	state := refreshState(ts.Unix())
-
-	ethIFace, found := state.IPAddrs[ip]
-	if !found {
-		// If we didn't find the IP, try again with a current timestamp
-		// to force getting new state from HSM. In case the hardware came up
-		// within the last cache eviction period.
-		state = refreshState(time.Now().Unix())
-		ethIFace, found = state.IPAddrs[ip]
-	}
-	return ethIFace.CompID, found
+	return nodeLookups().ByIP(ip)
 }
 
 const state_manager_data_temp = `{
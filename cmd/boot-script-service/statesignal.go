@@ -0,0 +1,229 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// The state signal tells every BSS instance sharing a datastore that HSM
+// reported a change, so that each can refresh its copy of the HSM state the
+// next time it needs it.  The config signal likewise tells them that boot
+// parameters changed, so that each drops its cached boot script lookups.
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenCHAMI/bss/internal/postgres"
+)
+
+type stateSignal interface {
+	// publish records ts as the time of the latest HSM state change.
+	publish(ts int64) error
+	// latest returns the time of the latest HSM state change, if any.
+	latest() (ts int64, exists bool, err error)
+	close() error
+}
+
+var stateSig stateSignal
+
+// etcdStateSignal keeps the timestamp under UpdateTimestampKey, which every
+// instance reads when it needs to check whether its state is current.
+type etcdStateSignal struct{}
+
+func (etcdStateSignal) publish(ts int64) error {
+	return kvstore.Store(UpdateTimestampKey, strconv.FormatInt(ts, 10))
+}
+
+func (etcdStateSignal) latest() (int64, bool, error) {
+	timestamp, exists, err := kvstore.Get(UpdateTimestampKey)
+	if err != nil || !exists {
+		return 0, false, err
+	}
+	ts, err := strconv.ParseInt(timestamp, 0, 64)
+	return ts, err == nil, err
+}
+
+func (etcdStateSignal) close() error { return nil }
+
+// sqlStateSignal keeps the timestamp in the state_changes table. Instances
+// LISTEN for updates and cache the latest value so that checking it does not
+// cost a query per boot request.  Should the listener be unavailable, the
+// table is queried directly.
+type sqlStateSignal struct {
+	db       postgres.BootDataDatabase
+	listener *postgres.ChangeListener
+	ts       atomic.Int64
+}
+
+func newSQLStateSignal(db postgres.BootDataDatabase) *sqlStateSignal {
+	s := &sqlStateSignal{db: db}
+	if ts, exists, err := db.GetStateChanged(); err != nil {
+		log.Printf("WARNING: unable to read the last HSM state change: %v", err)
+	} else if exists {
+		s.ts.Store(ts)
+	}
+	l, err := db.ListenStateChanges(s.observe)
+	if err != nil {
+		log.Printf("WARNING: unable to listen for HSM state changes, polling instead: %v", err)
+	} else {
+		s.listener = l
+	}
+	return s
+}
+
+// observe records ts unless a later change has already been seen.
+func (s *sqlStateSignal) observe(ts int64) {
+	for {
+		cur := s.ts.Load()
+		if ts <= cur || s.ts.CompareAndSwap(cur, ts) {
+			return
+		}
+	}
+}
+
+func (s *sqlStateSignal) publish(ts int64) error {
+	if err := s.db.SetStateChanged(ts); err != nil {
+		return err
+	}
+	s.observe(ts)
+	return nil
+}
+
+func (s *sqlStateSignal) latest() (int64, bool, error) {
+	if s.listener == nil {
+		return s.db.GetStateChanged()
+	}
+	ts := s.ts.Load()
+	return ts, ts > 0, nil
+}
+
+func (s *sqlStateSignal) close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// configRevisionKey is the etcd key of the boot configuration revision.
+const configRevisionKey = "/BootConfigRevision"
+
+type configSignal interface {
+	// changed records a change of boot parameters.
+	changed() error
+	// revision returns a value that differs after any instance recorded a
+	// change.
+	revision() (int64, error)
+	close() error
+}
+
+var configSig configSignal
+
+// etcdConfigSignal stores the time of the latest change, in nanoseconds, as
+// the revision.  Revisions are only compared for equality, so clocks that
+// differ between instances do not matter.
+type etcdConfigSignal struct{}
+
+func (etcdConfigSignal) changed() error {
+	return kvstore.Store(configRevisionKey, strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+func (etcdConfigSignal) revision() (int64, error) {
+	val, exists, err := kvstore.Get(configRevisionKey)
+	if err != nil || !exists {
+		return 0, err
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+func (etcdConfigSignal) close() error { return nil }
+
+// sqlConfigSignal counts changes in the config_changes table, and like
+// sqlStateSignal caches the latest count it was told of.
+type sqlConfigSignal struct {
+	db       postgres.BootDataDatabase
+	listener *postgres.ChangeListener
+	rev      atomic.Int64
+}
+
+func newSQLConfigSignal(db postgres.BootDataDatabase) *sqlConfigSignal {
+	s := &sqlConfigSignal{db: db}
+	if rev, _, err := db.GetConfigRevision(); err != nil {
+		log.Printf("WARNING: unable to read the boot configuration revision: %v", err)
+	} else {
+		s.rev.Store(rev)
+	}
+	l, err := db.ListenConfigChanges(s.observe)
+	if err != nil {
+		log.Printf("WARNING: unable to listen for boot configuration changes, polling instead: %v", err)
+	} else {
+		s.listener = l
+	}
+	return s
+}
+
+// observe records rev unless a later revision has already been seen.
+func (s *sqlConfigSignal) observe(rev int64) {
+	for {
+		cur := s.rev.Load()
+		if rev <= cur || s.rev.CompareAndSwap(cur, rev) {
+			return
+		}
+	}
+}
+
+func (s *sqlConfigSignal) changed() error {
+	rev, err := s.db.BumpConfigRevision()
+	if err != nil {
+		return err
+	}
+	s.observe(rev)
+	return nil
+}
+
+func (s *sqlConfigSignal) revision() (int64, error) {
+	if s.listener == nil {
+		rev, _, err := s.db.GetConfigRevision()
+		return rev, err
+	}
+	return s.rev.Load(), nil
+}
+
+func (s *sqlConfigSignal) close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// initStateSignal selects the state and config signals matching the
+// configured datastore.  It must be called after the datastore has been
+// opened.
+func initStateSignal() {
+	if useSQL {
+		stateSig = newSQLStateSignal(bssdb)
+		configSig = newSQLConfigSignal(bssdb)
+	} else if kvstore != nil {
+		stateSig = etcdStateSignal{}
+		configSig = etcdConfigSignal{}
+	}
+}
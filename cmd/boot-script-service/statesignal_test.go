@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeStateSignal struct {
+	ts        int64
+	published []int64
+}
+
+func (f *fakeStateSignal) publish(ts int64) error {
+	f.published = append(f.published, ts)
+	f.ts = ts
+	return nil
+}
+
+func (f *fakeStateSignal) latest() (int64, bool, error) { return f.ts, f.ts > 0, nil }
+
+func (f *fakeStateSignal) close() error { return nil }
+
+func TestEtcdStateSignal(t *testing.T) {
+	defer kvstore.Delete(UpdateTimestampKey)
+
+	var sig etcdStateSignal
+	if err := sig.publish(1234); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+	ts, exists, err := sig.latest()
+	if err != nil || !exists || ts != 1234 {
+		t.Errorf("latest() = %d, %t, %v; expected 1234, true, nil", ts, exists, err)
+	}
+}
+
+func TestSQLStateSignalObserve(t *testing.T) {
+	var sig sqlStateSignal
+	sig.observe(20)
+	sig.observe(10)
+	if ts := sig.ts.Load(); ts != 20 {
+		t.Errorf("Older state change replaced newer one: got %d", ts)
+	}
+	sig.observe(30)
+	if ts := sig.ts.Load(); ts != 30 {
+		t.Errorf("Newer state change not recorded: got %d", ts)
+	}
+}
+
+func TestCheckStateUsesSignal(t *testing.T) {
+	saved := stateSig
+	defer func() { stateSig = saved }()
+
+	smMutex.Lock()
+	current := smTimeStamp
+	smMutex.Unlock()
+
+	stateSig = nil
+	if checkState(false) {
+		t.Errorf("checkState refreshed without a state signal")
+	}
+	sig := &fakeStateSignal{ts: current}
+	stateSig = sig
+	if checkState(false) {
+		t.Errorf("checkState refreshed for an unchanged timestamp")
+	}
+
+	// An SCN that cannot be applied in place is published so that every
+	// instance refetches.
+	body := `{"Components":["x9999c0s0b0n0"],"State":"Ready"}`
+	rr := httptest.NewRecorder()
+	stateChangeNotification(rr, httptest.NewRequest(http.MethodPost, "/boot/v1/scn", bytes.NewBufferString(body)))
+	if len(sig.published) != 1 {
+		t.Fatalf("Expected one published state change, got %v", sig.published)
+	}
+	sig.ts = sig.published[0] + 1
+	if !checkState(false) {
+		t.Errorf("checkState did not refresh for a newer timestamp")
+	}
+	backgroundTasks.Wait()
+}
+
+func TestSQLConfigSignalObserve(t *testing.T) {
+	var sig sqlConfigSignal
+	sig.observe(2)
+	sig.observe(1)
+	if rev := sig.rev.Load(); rev != 2 {
+		t.Errorf("Older revision replaced newer one: got %d", rev)
+	}
+}
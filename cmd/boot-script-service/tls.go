@@ -0,0 +1,187 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// Native TLS serving.
+//
+// BSS can terminate TLS itself so that small sites do not need a gateway in
+// front of it.  The certificate and key are re-read whenever the files change,
+// which allows them to be rotated by cert-manager, ACME clients, and the like
+// without a restart.  A second, plain HTTP listener with a restricted set of
+// routes can be enabled for iPXE clients that cannot do TLS.  Boot scripts
+// served over it chain back over plain HTTP as well.
+//
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often the certificate files are checked for changes.
+const tlsReloadCheckInterval = 10 * time.Second
+
+var (
+	tlsCertFile     = "" // Serve HTTPS using this certificate (PEM)
+	tlsKeyFile      = "" // Private key for tlsCertFile (PEM)
+	tlsClientCAFile = "" // Verify client certificates against these CAs (PEM)
+	httpPlainListen = "" // Plain HTTP listener for iPXE clients when serving HTTPS
+)
+
+// certReloader serves a certificate and key pair from disk, reloading them
+// when either file is modified.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func modTime(path string) (time.Time, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// reload loads the certificate and key pair.  The caller must hold cr.mu or
+// be the constructor.
+func (cr *certReloader) reload() error {
+	certMod, err := modTime(cr.certFile)
+	if err != nil {
+		return fmt.Errorf("Could not read TLS certificate: %v", err)
+	}
+	keyMod, err := modTime(cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("Could not read TLS key: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("Could not load TLS certificate and key: %v", err)
+	}
+	cr.cert = &cert
+	cr.certMod = certMod
+	cr.keyMod = keyMod
+	cr.lastCheck = time.Now()
+	return nil
+}
+
+// maybeReload reloads the certificate if the files have changed since they
+// were last loaded.  If the new files cannot be loaded (for instance because
+// only one of them has been replaced so far), the current certificate is kept
+// and the load is retried at the next check.
+func (cr *certReloader) maybeReload() {
+	if time.Since(cr.lastCheck) < tlsReloadCheckInterval {
+		return
+	}
+	cr.lastCheck = time.Now()
+	certMod, cerr := modTime(cr.certFile)
+	keyMod, kerr := modTime(cr.keyFile)
+	if cerr != nil || kerr != nil || (certMod.Equal(cr.certMod) && keyMod.Equal(cr.keyMod)) {
+		return
+	}
+	if err := cr.reload(); err != nil {
+		log.Printf("WARNING: keeping current TLS certificate: %v", err)
+		return
+	}
+	log.Printf("Reloaded TLS certificate from %s", cr.certFile)
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.maybeReload()
+	return cr.cert, nil
+}
+
+// newTLSConfig returns the TLS configuration for the HTTPS listener.  Client
+// certificates are requested but not required so that JWT and API key clients
+// keep working; a certificate that is presented must verify against the
+// client CA.
+func newTLSConfig() (*tls.Config, error) {
+	if tlsKeyFile == "" {
+		return nil, fmt.Errorf("a TLS key file is required with a TLS certificate")
+	}
+	cr, err := newCertReloader(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+	if tlsClientCAFile != "" {
+		pem, err := os.ReadFile(tlsClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read TLS client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in TLS client CA file %s", tlsClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+type plainListenerKey struct{}
+
+// servedPlain marks requests as having come in on the plain HTTP listener.
+func servedPlain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), plainListenerKey{}, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// chainProtocol returns the protocol with which a boot script served for the
+// request of ctx chains back.  It is chain-proto, except on the plain HTTP
+// listener, whose clients may not be able to do TLS.
+func chainProtocol(ctx context.Context) string {
+	if plain, _ := ctx.Value(plainListenerKey{}).(bool); plain {
+		return "http"
+	}
+	return chainProto
+}
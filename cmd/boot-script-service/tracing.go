@@ -0,0 +1,159 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//
+// OpenTelemetry tracing.
+//
+// Incoming requests are traced by the router, and spans are added around the
+// slow parts of boot script generation: HSM state retrieval, boot data lookups,
+// SPIRE join tokens, and S3 presigning.  Outgoing HTTP requests carry the trace
+// context so that traces continue into HSM and the SPIRE token service.
+//
+// The OTLP exporter is configured with the standard OTEL_EXPORTER_OTLP_*
+// environment variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT.
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracingExporterNone   = "none"
+	tracingExporterOTLP   = "otlp"
+	tracingExporterStdout = "stdout"
+	tracingExporterFile   = "file"
+	tracerName            = "github.com/OpenCHAMI/bss"
+)
+
+var (
+	tracingExporter = tracingExporterNone // none, otlp, stdout, or file
+	tracingFile     = "bss-traces.json"   // Output of the file exporter
+	tracer          = otel.Tracer(tracerName)
+)
+
+// initTracing installs the global tracer provider for the configured
+// exporter.  The returned function flushes and stops the exporter.
+func initTracing() (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch strings.ToLower(tracingExporter) {
+	case "", tracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case tracingExporterOTLP:
+		exporter, err = otlptracehttp.New(context.Background())
+	case tracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case tracingExporterFile:
+		var f io.Writer
+		f, err = os.OpenFile(tracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("Could not open trace file: %v", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (expected none, otlp, stdout, or file)", tracingExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not create %s trace exporter: %v", tracingExporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("boot-script-service"),
+		semconv.ServiceVersion(VersionInfo()),
+		semconv.ServiceInstanceID(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("Could not create trace resource: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	log.Printf("Tracing enabled using the %s exporter", tracingExporter)
+	return tp.Shutdown, nil
+}
+
+// tracingMiddleware starts a server span for every request.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "bss",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}))
+}
+
+// tracedTransport wraps rt so that outgoing requests are traced and carry the
+// trace context of the request's context.
+func tracedTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(rt)
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedCheckURL returns a signedS3UrlGetter which traces the presigning of
+// S3 URLs.  Other URLs are returned unchanged without a span.
+func tracedCheckURL(ctx context.Context) signedS3UrlGetter {
+	return func(u string) (string, error) {
+		if !strings.HasPrefix(strings.ToLower(u), "s3:") {
+			return checkURL(u)
+		}
+		_, span := tracer.Start(ctx, "s3.presign", trace.WithAttributes(attribute.String("bss.s3.url", u)))
+		signed, err := checkURL(u)
+		endSpan(span, err)
+		return signed, err
+	}
+}
@@ -0,0 +1,66 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBuildParamsTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx, span := tracer.Start(context.Background(), "test")
+	bd := BootData{Params: "console=ttyS0 root=live:https://example.com/image"}
+	if _, err := buildParams(ctx, bd, scriptParams{xname: "x0c0s0b0n0"}, "Compute", ""); err != nil {
+		t.Fatalf("buildParams returned error: %v", err)
+	}
+	span.End()
+
+	// Without SPIRE_JOIN_TOKEN or s3:// URLs no child spans are expected.
+	if n := len(recorder.Ended()); n != 1 {
+		t.Errorf("Recorded %d spans, expected 1", n)
+	}
+
+	if tracingExporter != tracingExporterNone {
+		t.Fatalf("Unexpected default tracing exporter %q", tracingExporter)
+	}
+	shutdown, err := initTracing()
+	if err != nil {
+		t.Fatalf("initTracing returned error for exporter none: %v", err)
+	}
+	shutdown(context.Background())
+
+	tracingExporter = "bogus"
+	defer func() { tracingExporter = tracingExporterNone }()
+	if _, err = initTracing(); err == nil {
+		t.Errorf("initTracing accepted an unknown exporter")
+	}
+}
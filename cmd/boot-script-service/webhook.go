@@ -0,0 +1,637 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Webhook subscriptions.  Clients subscribe a URL through /boot/v1/webhooks
+// and are sent a signed JSON event whenever the boot parameters of a node or
+// group are created, updated, or deleted through /bootparameters.  Events
+// are delivered at least once through a notification queue, and those that
+// cannot be delivered are kept as dead letters in the datastore.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	base "github.com/Cray-HPE/hms-base"
+	"github.com/Cray-HPE/hms-xname/xnames"
+	"github.com/OpenCHAMI/bss/internal/postgres"
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+const (
+	webhooksPfx    = "/webhooks/"
+	webhookDeadPfx = "/webhook-dead-letters/"
+)
+
+var (
+	webhooksEnabled    = false    // Allow webhook subscriptions to boot parameter changes
+	webhookQueue       = ""       // Directory holding undelivered webhook events
+	webhookMaxAttempts = uint(10) // Delivery attempts before a webhook event is dead-lettered
+
+	webhooks      webhookStore
+	webhookEvents *notifyQueue
+)
+
+type webhookStore interface {
+	list() ([]bssTypes.WebhookSubscription, error)
+	get(id string) (s bssTypes.WebhookSubscription, exists bool, err error)
+	add(s bssTypes.WebhookSubscription) error
+	remove(id string) (found bool, err error)
+	addDeadLetter(d bssTypes.WebhookDeadLetter) error
+	deadLetters() ([]bssTypes.WebhookDeadLetter, error)
+	removeDeadLetter(id string) (found bool, err error)
+}
+
+// etcdWebhookStore keeps subscriptions and dead letters as JSON under
+// webhooksPfx and webhookDeadPfx.
+type etcdWebhookStore struct{}
+
+func (etcdWebhookStore) list() ([]bssTypes.WebhookSubscription, error) {
+	kvl, err := kvstore.GetRange(webhooksPfx+keyMin, webhooksPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]bssTypes.WebhookSubscription, 0, len(kvl))
+	for _, kv := range kvl {
+		var s bssTypes.WebhookSubscription
+		if err := json.Unmarshal([]byte(kv.Value), &s); err != nil {
+			log.Printf("WARNING: ignoring malformed webhook subscription %s: %v", kv.Key, err)
+			continue
+		}
+		subs = append(subs, s)
+	}
+	return subs, nil
+}
+
+func (etcdWebhookStore) get(id string) (s bssTypes.WebhookSubscription, exists bool, err error) {
+	val, exists, err := kvstore.Get(webhooksPfx + id)
+	if err != nil || !exists {
+		return s, false, err
+	}
+	err = json.Unmarshal([]byte(val), &s)
+	return s, err == nil, err
+}
+
+func (etcdWebhookStore) add(s bssTypes.WebhookSubscription) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return kvstore.Store(webhooksPfx+s.ID, string(data))
+}
+
+func etcdRemove(key string) (bool, error) {
+	_, exists, err := kvstore.Get(key)
+	if err != nil || !exists {
+		return false, err
+	}
+	return true, kvstore.Delete(key)
+}
+
+func (etcdWebhookStore) remove(id string) (bool, error) {
+	return etcdRemove(webhooksPfx + id)
+}
+
+func (etcdWebhookStore) addDeadLetter(d bssTypes.WebhookDeadLetter) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return kvstore.Store(webhookDeadPfx+d.ID, string(data))
+}
+
+func (etcdWebhookStore) deadLetters() ([]bssTypes.WebhookDeadLetter, error) {
+	kvl, err := kvstore.GetRange(webhookDeadPfx+keyMin, webhookDeadPfx+keyMax)
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]bssTypes.WebhookDeadLetter, 0, len(kvl))
+	for _, kv := range kvl {
+		var d bssTypes.WebhookDeadLetter
+		if err := json.Unmarshal([]byte(kv.Value), &d); err != nil {
+			log.Printf("WARNING: ignoring malformed webhook dead letter %s: %v", kv.Key, err)
+			continue
+		}
+		letters = append(letters, d)
+	}
+	return letters, nil
+}
+
+func (etcdWebhookStore) removeDeadLetter(id string) (bool, error) {
+	return etcdRemove(webhookDeadPfx + id)
+}
+
+// sqlWebhookStore keeps subscriptions and dead letters in the
+// webhook_subscriptions and webhook_dead_letters tables.
+type sqlWebhookStore struct {
+	db postgres.BootDataDatabase
+}
+
+func (s sqlWebhookStore) list() ([]bssTypes.WebhookSubscription, error) {
+	start := time.Now()
+	subs, err := s.db.GetWebhookSubscriptions()
+	observeSQL("get_webhook_subscriptions", start, err)
+	return subs, err
+}
+
+func (s sqlWebhookStore) get(id string) (bssTypes.WebhookSubscription, bool, error) {
+	start := time.Now()
+	sub, exists, err := s.db.GetWebhookSubscription(id)
+	observeSQL("get_webhook_subscription", start, err)
+	return sub, exists, err
+}
+
+func (s sqlWebhookStore) add(sub bssTypes.WebhookSubscription) error {
+	start := time.Now()
+	err := s.db.AddWebhookSubscription(sub)
+	observeSQL("add_webhook_subscription", start, err)
+	return err
+}
+
+func (s sqlWebhookStore) remove(id string) (bool, error) {
+	start := time.Now()
+	found, err := s.db.DeleteWebhookSubscription(id)
+	observeSQL("delete_webhook_subscription", start, err)
+	return found, err
+}
+
+func (s sqlWebhookStore) addDeadLetter(d bssTypes.WebhookDeadLetter) error {
+	start := time.Now()
+	err := s.db.AddWebhookDeadLetter(d)
+	observeSQL("add_webhook_dead_letter", start, err)
+	return err
+}
+
+func (s sqlWebhookStore) deadLetters() ([]bssTypes.WebhookDeadLetter, error) {
+	start := time.Now()
+	letters, err := s.db.GetWebhookDeadLetters()
+	observeSQL("get_webhook_dead_letters", start, err)
+	return letters, err
+}
+
+func (s sqlWebhookStore) removeDeadLetter(id string) (bool, error) {
+	start := time.Now()
+	found, err := s.db.DeleteWebhookDeadLetter(id)
+	observeSQL("delete_webhook_dead_letter", start, err)
+	return found, err
+}
+
+// initWebhooks sets up webhook subscriptions, if enabled, in the configured
+// datastore, and starts delivering the events left in the queue directory by
+// a previous run.  The datastore must already be open.
+func initWebhooks() error {
+	if !webhooksEnabled {
+		return nil
+	}
+	if useSQL {
+		webhooks = sqlWebhookStore{db: bssdb}
+	} else if kvstore != nil {
+		webhooks = etcdWebhookStore{}
+	}
+	q, err := newWebhookQueue(webhookQueue)
+	if err != nil {
+		return err
+	}
+	webhookEvents = q
+	log.Printf("Sending boot parameter changes to webhook subscriptions")
+	return nil
+}
+
+func newWebhookQueue(dir string) (*notifyQueue, error) {
+	q := &notifyQueue{
+		dir:         dir,
+		what:        "webhook events",
+		maxAttempts: &webhookMaxAttempts,
+		secret:      webhookSecret,
+		dead:        deadLetterWebhook,
+	}
+	if err := q.start(); err != nil {
+		return nil, fmt.Errorf("webhook-queue: %v", err)
+	}
+	return q, nil
+}
+
+// webhookSecret returns the key of the subscription n is delivered for, and
+// false if the subscription has been deleted.
+func webhookSecret(n *notification) (string, bool, error) {
+	s, exists, err := webhooks.get(n.Subscription)
+	return s.Secret, exists, err
+}
+
+func deadLetterWebhook(n *notification, err error) {
+	d := bssTypes.WebhookDeadLetter{
+		ID:           n.ID,
+		Subscription: n.Subscription,
+		URL:          n.Target,
+		Event:        n.Event,
+		Attempts:     n.Attempts,
+		Error:        err.Error(),
+		Time:         time.Now().Unix(),
+	}
+	log.Printf("WARNING: giving up on webhook event %s to %s after %d attempts: %v", n.ID, n.Target, n.Attempts, err)
+	if err := webhooks.addDeadLetter(d); err != nil {
+		log.Printf("WARNING: failed to store webhook dead letter %s: %v: %s", n.ID, err, n.Event)
+	}
+}
+
+// configRef identifies the boot parameters of a node or group.  A node given
+// by a MAC address or NID that is not in the inventory is looked up by that.
+type configRef struct {
+	kind string
+	name string
+	mac  string
+	nid  int32
+}
+
+// configRefs returns the nodes and groups whose boot parameters a request
+// for bp touches.  Requests for kernel or initrd parameters touch none.
+func configRefs(bp bssTypes.BootParams) []configRef {
+	var refs []configRef
+	seen := make(map[configRef]bool)
+	add := func(ref configRef) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	for _, h := range bp.Hosts {
+		if _, ok := xnames.FromString(h).(xnames.Node); ok {
+			add(configRef{kind: "node", name: h})
+		} else {
+			add(configRef{kind: "group", name: h})
+		}
+	}
+	for _, m := range bp.Macs {
+		if comp, ok := FindSMCompByMAC(m); ok && !useSQL {
+			add(configRef{kind: "node", name: comp.ID})
+		} else {
+			add(configRef{kind: "node", name: m, mac: m})
+		}
+	}
+	for _, n := range bp.Nids {
+		if comp, ok := FindSMCompByNid(int(n)); ok && !useSQL {
+			add(configRef{kind: "node", name: comp.ID})
+		} else {
+			add(configRef{kind: "node", name: nidName(int(n)), nid: n})
+		}
+	}
+	return refs
+}
+
+// readConfig returns the stored boot parameters of ref, nil if it has none.
+func readConfig(ref configRef) (*bssTypes.BootParams, error) {
+	if !useSQL {
+		// etcd keeps the parameters of unknown MACs and NIDs under the
+		// names configRefs gives them.
+		val, exists, err := kvstore.Get(paramsPfx + ref.name)
+		if err != nil || !exists {
+			return nil, err
+		}
+		var bds BootDataStore
+		if err = json.Unmarshal([]byte(val), &bds); err != nil {
+			return nil, err
+		}
+		bd := bdConvert(bds)
+		return &bssTypes.BootParams{
+			Hosts:     []string{ref.name},
+			Kernel:    bd.Kernel.Path,
+			Initrd:    bd.Initrd.Path,
+			Params:    bd.Params,
+			CloudInit: bd.CloudInit,
+		}, nil
+	}
+	if ref.kind == "group" {
+		bp, exists, err := groupBootParams(ref.name)
+		if err != nil || !exists {
+			return nil, err
+		}
+		bp.Hosts = []string{ref.name}
+		return &bp, nil
+	}
+	var results []bssTypes.BootParams
+	var err error
+	switch {
+	case ref.mac != "":
+		results, err = SqlGetBootParams([]string{ref.mac}, nil, nil)
+	case ref.nid != 0:
+		results, err = SqlGetBootParams(nil, nil, []int32{ref.nid})
+	default:
+		results, err = SqlGetBootParams(nil, []string{ref.name}, nil)
+	}
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+// configLocks serializes the boot parameter requests of this instance that
+// are watched for changes, so that the before and after of a change are not
+// mixed up with those of another request.
+var configLocks keyLocks
+
+// configWatch holds the boot parameters a request is about to change, so
+// the changes can be sent to webhook subscriptions and the event stream once
+// it has.
+type configWatch struct {
+	subs   []bssTypes.WebhookSubscription
+	stream bool
+	refs   []configRef
+	before []*bssTypes.BootParams
+	unlock func()
+}
+
+// watchConfigChanges records the boot parameters a request for bp, which
+// can only make changes of the given types, touches.  It returns nil if no
+// webhook subscription wants those changes and no event stream is following
+// them, as they cost a read of every touched node and group before and after
+// the request.  Otherwise the nodes and groups are locked until release.
+func watchConfigChanges(bp bssTypes.BootParams, types ...bssTypes.ConfigEventType) *configWatch {
+	w := &configWatch{stream: events != nil && events.following(eventTypeBootParameters)}
+	if webhooks != nil {
+		subs, err := webhooks.list()
+		if err != nil {
+			log.Printf("WARNING: failed to read webhook subscriptions, boot parameter changes will not be sent: %v", err)
+		}
+		for _, s := range subs {
+			for _, t := range types {
+				if wantsEvent(s, t) {
+					w.subs = append(w.subs, s)
+					break
+				}
+			}
+		}
+	}
+	if len(w.subs) == 0 && !w.stream {
+		return nil
+	}
+	refs := configRefs(bp)
+	keys := make([]string, len(refs))
+	for i, ref := range refs {
+		keys[i] = ref.kind + "/" + ref.name
+	}
+	w.unlock = configLocks.lockAll(keys)
+	for _, ref := range refs {
+		before, err := readConfig(ref)
+		if err != nil {
+			log.Printf("WARNING: failed to read boot parameters of %s, changes to them will not be sent: %v", ref.name, err)
+			continue
+		}
+		w.refs = append(w.refs, ref)
+		w.before = append(w.before, before)
+	}
+	return w
+}
+
+// release unlocks the nodes and groups of the request, whether or not it
+// succeeded.
+func (w *configWatch) release() {
+	if w != nil && w.unlock != nil {
+		w.unlock()
+		w.unlock = nil
+	}
+}
+
+// publish sends an event for each node and group whose boot parameters
+// changed to the event stream and every subscription that wants it.  It is
+// called once the request succeeded, before release.
+func (w *configWatch) publish() {
+	if w == nil {
+		return
+	}
+	for i, ref := range w.refs {
+		after, err := readConfig(ref)
+		if err != nil {
+			log.Printf("WARNING: failed to read boot parameters of %s, changes to them will not be sent: %v", ref.name, err)
+			continue
+		}
+		ev := bssTypes.ConfigEvent{
+			Kind:   ref.kind,
+			Name:   ref.name,
+			Before: w.before[i],
+			After:  after,
+		}
+		switch {
+		case ev.Before == nil && ev.After == nil:
+			continue
+		case ev.Before == nil:
+			ev.Type = bssTypes.ConfigCreated
+		case ev.After == nil:
+			ev.Type = bssTypes.ConfigDeleted
+		case reflect.DeepEqual(ev.Before, ev.After):
+			continue
+		default:
+			ev.Type = bssTypes.ConfigUpdated
+		}
+		ev.ID = newEventID()
+		ev.Time = time.Now().Unix()
+		data, err := json.Marshal(ev)
+		if err != nil {
+			log.Printf("WARNING: failed to encode boot parameter change of %s: %v", ref.name, err)
+			continue
+		}
+		switch {
+		case !w.stream:
+		case ref.kind == "group":
+			publishEvent(eventTypeBootParameters, nil, []string{ref.name}, json.RawMessage(data))
+		default:
+			publishEvent(eventTypeBootParameters, []string{ref.name}, compGroups(ref.name), json.RawMessage(data))
+		}
+		for _, s := range w.subs {
+			if !wantsEvent(s, ev.Type) {
+				continue
+			}
+			n := &notification{
+				ID:           ev.ID + "-" + s.ID,
+				EventID:      ev.ID,
+				Target:       s.URL,
+				Subscription: s.ID,
+				Event:        data,
+			}
+			webhookEvents.persist(n)
+			goBackground(func() { webhookEvents.deliver(n) })
+		}
+	}
+}
+
+func wantsEvent(s bssTypes.WebhookSubscription, t bssTypes.ConfigEventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+func validEventType(t bssTypes.ConfigEventType) bool {
+	for _, e := range bssTypes.ConfigEventTypes {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+func webhooksDisabled(w http.ResponseWriter) bool {
+	if webhooks == nil {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, "Webhooks are not enabled")
+		return true
+	}
+	return false
+}
+
+func webhooksGetAPI(w http.ResponseWriter, r *http.Request) {
+	if webhooksDisabled(w) {
+		return
+	}
+	subs, err := webhooks.list()
+	if err != nil {
+		log.Printf("Failed to list webhook subscriptions: %v", err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to list webhook subscriptions")
+		return
+	}
+	// Always make sure to give back at least an empty array instead of `null`.
+	results := []bssTypes.WebhookSubscription{}
+	for _, s := range subs {
+		s.Secret = ""
+		results = append(results, s)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
+
+func webhooksPostAPI(w http.ResponseWriter, r *http.Request) {
+	if webhooksDisabled(w) {
+		return
+	}
+	var s bssTypes.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Bad Request: %v", err))
+		return
+	}
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, fmt.Sprintf("Invalid webhook URL %q", s.URL))
+		return
+	}
+	for _, e := range s.Events {
+		if !validEventType(e) {
+			base.SendProblemDetailsGeneric(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid event type %q, expected created, updated, or deleted", e))
+			return
+		}
+	}
+	s.ID = newEventID()
+	s.Created = time.Now().Unix()
+	if s.Secret == "" {
+		s.Secret = newEventID()
+	}
+	if err := webhooks.add(s); err != nil {
+		log.Printf("Failed to add webhook subscription for %s: %v", s.URL, err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to add webhook subscription")
+		return
+	}
+	log.Printf("Added webhook subscription %s for %s", s.ID, s.URL)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
+
+func webhooksDeleteAPI(w http.ResponseWriter, r *http.Request) {
+	if webhooksDisabled(w) {
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "A subscription id is required")
+		return
+	}
+	found, err := webhooks.remove(id)
+	if err != nil {
+		log.Printf("Failed to remove webhook subscription %s: %v", id, err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to remove webhook subscription")
+		return
+	} else if !found {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, fmt.Sprintf("No webhook subscription %s", id))
+		return
+	}
+	log.Printf("Removed webhook subscription %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func webhookDeadLettersGetAPI(w http.ResponseWriter, r *http.Request) {
+	if webhooksDisabled(w) {
+		return
+	}
+	sub := r.URL.Query().Get("subscription")
+	letters, err := webhooks.deadLetters()
+	if err != nil {
+		log.Printf("Failed to list webhook dead letters: %v", err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to list webhook dead letters")
+		return
+	}
+	// Always make sure to give back at least an empty array instead of `null`.
+	results := []bssTypes.WebhookDeadLetter{}
+	for _, d := range letters {
+		if sub == "" || d.Subscription == sub {
+			results = append(results, d)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Yikes, I couldn't encode a JSON status response: %s\n", err)
+	}
+}
+
+func webhookDeadLettersDeleteAPI(w http.ResponseWriter, r *http.Request) {
+	if webhooksDisabled(w) {
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		base.SendProblemDetailsGeneric(w, http.StatusBadRequest, "A dead letter id is required")
+		return
+	}
+	found, err := webhooks.removeDeadLetter(id)
+	if err != nil {
+		log.Printf("Failed to remove webhook dead letter %s: %v", id, err)
+		base.SendProblemDetailsGeneric(w, http.StatusInternalServerError, "Failed to remove webhook dead letter")
+		return
+	} else if !found {
+		base.SendProblemDetailsGeneric(w, http.StatusNotFound, fmt.Sprintf("No webhook dead letter %s", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
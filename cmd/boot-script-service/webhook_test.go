@@ -0,0 +1,266 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+// useWebhooks enables webhooks in the in-memory datastore, with a queue in a
+// temporary directory.
+func useWebhooks(t *testing.T) {
+	savedStore, savedQueue, savedBase, savedMax := webhooks, webhookEvents, notifyRetryBase, webhookMaxAttempts
+	q, err := newWebhookQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unable to create webhook queue: %v", err)
+	}
+	webhooks, webhookEvents, notifyRetryBase = etcdWebhookStore{}, q, 10*time.Millisecond
+	t.Cleanup(func() {
+		backgroundTasks.Wait()
+		q.stop()
+		subs, _ := webhooks.list()
+		for _, s := range subs {
+			webhooks.remove(s.ID)
+		}
+		letters, _ := webhooks.deadLetters()
+		for _, d := range letters {
+			webhooks.removeDeadLetter(d.ID)
+		}
+		webhooks, webhookEvents, notifyRetryBase, webhookMaxAttempts = savedStore, savedQueue, savedBase, savedMax
+	})
+}
+
+func webhookRequest(t *testing.T, h http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+	return rr
+}
+
+func subscribe(t *testing.T, body string) bssTypes.WebhookSubscription {
+	t.Helper()
+	rr := webhookRequest(t, webhooksHandler, http.MethodPost, "/boot/v1/webhooks", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("POST /webhooks %s returned %d: %s", body, rr.Code, rr.Body.String())
+	}
+	var s bssTypes.WebhookSubscription
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil {
+		t.Fatalf("Unable to decode subscription: %v", err)
+	}
+	return s
+}
+
+func TestWebhooksDisabled(t *testing.T) {
+	saved := webhooks
+	defer func() { webhooks = saved }()
+	webhooks = nil
+
+	for _, h := range []http.HandlerFunc{webhooksHandler, webhookDeadLetters} {
+		if rr := webhookRequest(t, h, http.MethodGet, "/boot/v1/webhooks", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("GET returned %d with webhooks disabled", rr.Code)
+		}
+	}
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	useWebhooks(t)
+
+	for _, body := range []string{
+		`{"url":"not a url"}`,
+		`{"url":"ftp://example.com/hook"}`,
+		`{"url":"http://example.com/hook","events":["renamed"]}`,
+		`{"url":`,
+	} {
+		if rr := webhookRequest(t, webhooksHandler, http.MethodPost, "/boot/v1/webhooks", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("POST %s returned %d, expected %d", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	s := subscribe(t, `{"url":"http://example.com/hook","events":["deleted"]}`)
+	if s.ID == "" || s.Secret == "" || s.Created == 0 {
+		t.Errorf("Unexpected subscription: %+v", s)
+	}
+	if given := subscribe(t, `{"url":"https://example.com/other","secret":"s3cret"}`); given.Secret != "s3cret" {
+		t.Errorf("Given secret not kept: %+v", given)
+	}
+
+	rr := webhookRequest(t, webhooksHandler, http.MethodGet, "/boot/v1/webhooks", "")
+	var subs []bssTypes.WebhookSubscription
+	if err := json.Unmarshal(rr.Body.Bytes(), &subs); err != nil || len(subs) != 2 {
+		t.Fatalf("Expected 2 subscriptions, got %s (%v)", rr.Body.String(), err)
+	}
+	for _, sub := range subs {
+		if sub.Secret != "" {
+			t.Errorf("Secret of subscription %s listed", sub.ID)
+		}
+	}
+
+	if rr = webhookRequest(t, webhooksHandler, http.MethodDelete, "/boot/v1/webhooks?id="+s.ID, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d", rr.Code)
+	}
+	if rr = webhookRequest(t, webhooksHandler, http.MethodDelete, "/boot/v1/webhooks?id="+s.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Second DELETE returned %d", rr.Code)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	useWebhooks(t)
+	all, deletes := newNotifyTarget(0), newNotifyTarget(0)
+	defer all.Close()
+	defer deletes.Close()
+	s := subscribe(t, `{"url":"`+all.URL+`"}`)
+	subscribe(t, `{"url":"`+deletes.URL+`","events":["deleted"]}`)
+
+	const node = "x9001c0s0b0n0"
+	steps := []struct {
+		method string
+		body   string
+	}{
+		{http.MethodPost, `{"hosts":["` + node + `"],"kernel":"/webhook/vmlinuz","params":"a=1"}`},
+		{http.MethodPatch, `{"hosts":["` + node + `"],"params":"a=2"}`},
+		{http.MethodPatch, `{"hosts":["` + node + `"],"params":"a=2"}`},
+		{http.MethodPut, `{"hosts":["webhook-group"],"kernel":"/webhook/vmlinuz","params":"group=1"}`},
+		{http.MethodDelete, `{"hosts":["` + node + `"]}`},
+		{http.MethodDelete, `{"hosts":["webhook-group"]}`},
+	}
+	for _, step := range steps {
+		if rr := webhookRequest(t, bootParameters, step.method, "/boot/v1/bootparameters", step.body); rr.Code >= 300 {
+			t.Fatalf("%s %s returned %d: %s", step.method, step.body, rr.Code, rr.Body.String())
+		}
+		backgroundTasks.Wait()
+	}
+	defer Remove(bssTypes.BootParams{Kernel: "/webhook/vmlinuz"})
+
+	expected := []struct {
+		typ           bssTypes.ConfigEventType
+		kind, name    string
+		before, after string
+	}{
+		{bssTypes.ConfigCreated, "node", node, "", "a=1"},
+		{bssTypes.ConfigUpdated, "node", node, "a=1", "a=2"},
+		{bssTypes.ConfigCreated, "group", "webhook-group", "", "group=1"},
+		{bssTypes.ConfigDeleted, "node", node, "a=2", ""},
+		{bssTypes.ConfigDeleted, "group", "webhook-group", "group=1", ""},
+	}
+	if all.count() != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), all.count())
+	}
+	params := func(bp *bssTypes.BootParams) string {
+		if bp == nil {
+			return ""
+		}
+		return bp.Params
+	}
+	for i, exp := range expected {
+		var ev bssTypes.ConfigEvent
+		if err := json.Unmarshal(all.bodies[i], &ev); err != nil {
+			t.Fatalf("Unable to decode event %s: %v", all.bodies[i], err)
+		}
+		if ev.ID == "" || ev.Type != exp.typ || ev.Kind != exp.kind || ev.Name != exp.name ||
+			params(ev.Before) != exp.before || params(ev.After) != exp.after {
+			t.Errorf("Event %d: expected %+v, got %s", i, exp, all.bodies[i])
+		}
+		if sig := all.requests[i].Header.Get(notifySignatureHeader); sig != signNotification(s.Secret, all.bodies[i]) {
+			t.Errorf("Event %d has bad signature %q", i, sig)
+		}
+	}
+	if deletes.count() != 2 {
+		t.Errorf("Expected 2 events for the deleted subscription, got %d", deletes.count())
+	}
+}
+
+func TestWebhookWatchedTypes(t *testing.T) {
+	useWebhooks(t)
+	bp := bssTypes.BootParams{Hosts: []string{"x9001c0s0b0n0"}}
+	if w := watchConfigChanges(bp, bssTypes.ConfigCreated); w != nil {
+		t.Errorf("Changes were watched without subscriptions")
+	}
+	subscribe(t, `{"url":"http://localhost/","events":["deleted"]}`)
+	if w := watchConfigChanges(bp, bssTypes.ConfigCreated, bssTypes.ConfigUpdated); w != nil {
+		t.Errorf("Changes were watched for a subscription that does not want them")
+	}
+	w := watchConfigChanges(bp, bssTypes.ConfigDeleted)
+	if w == nil || len(w.refs) != 1 {
+		t.Fatalf("Changes were not watched for a subscription that wants them: %+v", w)
+	}
+
+	// The node stays locked until the watch is released.
+	locked := make(chan struct{})
+	go func() {
+		watchConfigChanges(bp, bssTypes.ConfigDeleted).release()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Errorf("Node was not locked by the first watch")
+	case <-time.After(50 * time.Millisecond):
+	}
+	w.release()
+	<-locked
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	useWebhooks(t)
+	webhookMaxAttempts = 2
+	nt := newNotifyTarget(1000)
+	defer nt.Close()
+	s := subscribe(t, `{"url":"`+nt.URL+`"}`)
+
+	const node = "x9002c0s0b0n0"
+	rr := webhookRequest(t, bootParameters, http.MethodPut, "/boot/v1/bootparameters", `{"hosts":["`+node+`"],"params":"dead=1"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body.String())
+	}
+	defer Remove(bssTypes.BootParams{Hosts: []string{node}})
+
+	// The in-memory datastore cannot be read while the failed delivery
+	// stores its dead letter, so wait for the delivery to finish first.
+	if !waitFor(func() bool { return nt.count() == 2 }) {
+		t.Fatalf("Expected 2 attempts, got %d", nt.count())
+	}
+	backgroundTasks.Wait()
+	rr = webhookRequest(t, webhookDeadLetters, http.MethodGet, "/boot/v1/webhooks/dead-letters?subscription="+s.ID, "")
+	var letters []bssTypes.WebhookDeadLetter
+	if err := json.Unmarshal(rr.Body.Bytes(), &letters); err != nil || len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %s (%v)", rr.Body.String(), err)
+	}
+	d := letters[0]
+	var ev bssTypes.ConfigEvent
+	if err := json.Unmarshal(d.Event, &ev); err != nil || ev.Name != node || d.Attempts != 2 || d.URL != nt.URL || d.Error == "" {
+		t.Errorf("Unexpected dead letter: %+v", d)
+	}
+
+	if rr = webhookRequest(t, webhookDeadLetters, http.MethodDelete, "/boot/v1/webhooks/dead-letters?id="+d.ID, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned %d", rr.Code)
+	}
+	if rr = webhookRequest(t, webhookDeadLetters, http.MethodDelete, "/boot/v1/webhooks/dead-letters?id="+d.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Second DELETE returned %d", rr.Code)
+	}
+}
@@ -50,7 +50,9 @@ import (
 const (
 	APP_VERSION    = "1"
 	SCHEMA_VERSION = 1
-	SCHEMA_STEPS   = 2
+	// The latest migration, which is applied by default. Every migration in
+	// the migrations directory must be counted.
+	SCHEMA_STEPS = 11
 )
 
 var (
@@ -62,6 +62,9 @@ type BootGroupAssignment struct {
 
 type BootDataDatabase struct {
 	DB *sqlx.DB
+	// connStr is kept so that LISTEN connections, which cannot come from the
+	// pool, can be opened to the same database.
+	connStr string
 	// TODO: Utilize cache.
 	//ImageCache map[string]Image
 }
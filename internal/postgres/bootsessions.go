@@ -0,0 +1,88 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+// GetBootSession returns the latest boot session of the node named name, with
+// exists false if the node has none.
+func (bddb BootDataDatabase) GetBootSession(name string) (bs bssTypes.BootSession, exists bool, err error) {
+	qstr := `SELECT name, started, bootscript, retries, attempts, user_data, phone_home FROM boot_sessions WHERE name = $1;`
+	err = bddb.DB.QueryRow(qstr, name).Scan(&bs.Name, &bs.Started, &bs.Bootscript, &bs.Retries, &bs.Attempts, &bs.UserData, &bs.PhoneHome)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("postgres.GetBootSession: %v", err)
+		return
+	}
+	exists = true
+
+	return
+}
+
+// SetBootSession stores bs as the latest boot session of its node.
+func (bddb BootDataDatabase) SetBootSession(bs bssTypes.BootSession) (err error) {
+	execStr := `INSERT INTO boot_sessions (name, started, bootscript, retries, attempts, user_data, phone_home)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (name) DO UPDATE SET started = EXCLUDED.started, bootscript = EXCLUDED.bootscript,
+			retries = EXCLUDED.retries, attempts = EXCLUDED.attempts, user_data = EXCLUDED.user_data,
+			phone_home = EXCLUDED.phone_home;`
+	_, err = bddb.DB.Exec(execStr, bs.Name, bs.Started, bs.Bootscript, bs.Retries, bs.Attempts, bs.UserData, bs.PhoneHome)
+	if err != nil {
+		err = fmt.Errorf("postgres.SetBootSession: Error storing session of %s: %v", bs.Name, err)
+	}
+
+	return
+}
+
+// GetBootSessions returns the latest boot session of every node, ordered by
+// node name.
+func (bddb BootDataDatabase) GetBootSessions() (sessions []bssTypes.BootSession, err error) {
+	qstr := `SELECT name, started, bootscript, retries, attempts, user_data, phone_home FROM boot_sessions ORDER BY name;`
+	rows, err := bddb.DB.Query(qstr)
+	if err != nil {
+		err = fmt.Errorf("postgres.GetBootSessions: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bs bssTypes.BootSession
+		err = rows.Scan(&bs.Name, &bs.Started, &bs.Bootscript, &bs.Retries, &bs.Attempts, &bs.UserData, &bs.PhoneHome)
+		if err != nil {
+			err = fmt.Errorf("postgres.GetBootSessions: could not scan SQL result: %v", err)
+			return
+		}
+		sessions = append(sessions, bs)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("postgres.GetBootSessions: could not parse query results: %v", err)
+	}
+
+	return
+}
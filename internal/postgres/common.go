@@ -143,6 +143,7 @@ func Connect(host string, port uint, dbName, user, password string, ssl bool, ex
 	// and ignore extra JSON config values, e.g. "omitempty".
 	db.Mapper = reflectx.NewMapperTagFunc("json", fieldNameToColName, tagToColName)
 	bddb.DB = db
+	bddb.connStr = connStr
 
 	return bddb, err
 }
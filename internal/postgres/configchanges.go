@@ -0,0 +1,84 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ConfigChangeChannel is the LISTEN/NOTIFY channel on which
+// BumpConfigRevision announces a new boot configuration revision.
+const ConfigChangeChannel = "bss_config_changed"
+
+// BumpConfigRevision increments the boot configuration revision, which
+// counts the changes of boot parameters made by any BSS instance, and
+// notifies every listener on ConfigChangeChannel of the new revision.
+func (bddb BootDataDatabase) BumpConfigRevision() (rev int64, err error) {
+	tx, err := bddb.DB.Beginx()
+	if err != nil {
+		err = fmt.Errorf("postgres.BumpConfigRevision: Unable to start transaction: %v", err)
+		return
+	}
+	execStr := `INSERT INTO config_changes (id, revision) VALUES (1, 1)
+		ON CONFLICT (id) DO UPDATE SET revision = config_changes.revision + 1 RETURNING revision;`
+	if err = tx.Get(&rev, execStr); err != nil {
+		tx.Rollback()
+		err = fmt.Errorf("postgres.BumpConfigRevision: Error recording configuration change: %v", err)
+		return
+	}
+	// Notifications are only delivered once the transaction commits.
+	if _, err = tx.Exec(`SELECT pg_notify($1, $2::text);`, ConfigChangeChannel, rev); err != nil {
+		tx.Rollback()
+		err = fmt.Errorf("postgres.BumpConfigRevision: Error sending notification: %v", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("postgres.BumpConfigRevision: Unable to commit transaction: %v", err)
+	}
+
+	return
+}
+
+// GetConfigRevision returns the boot configuration revision. exists is false
+// if no change has been recorded.
+func (bddb BootDataDatabase) GetConfigRevision() (rev int64, exists bool, err error) {
+	err = bddb.DB.Get(&rev, `SELECT revision FROM config_changes WHERE id = 1;`)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("postgres.GetConfigRevision: %v", err)
+		return
+	}
+	exists = true
+
+	return
+}
+
+// ListenConfigChanges opens a dedicated connection that listens on
+// ConfigChangeChannel and calls onChange with each revision received. As with
+// ListenStateChanges, the stored revision is re-read after every reconnect.
+func (bddb BootDataDatabase) ListenConfigChanges(onChange func(rev int64)) (*ChangeListener, error) {
+	return bddb.listenChanges("ListenConfigChanges", ConfigChangeChannel, bddb.GetConfigRevision, onChange)
+}
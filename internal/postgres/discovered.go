@@ -0,0 +1,135 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+// RecordDiscoveredNode adds dn to the discovered_nodes table, or, if its MAC
+// address is already there, updates its IP address and last seen time, as
+// well as its architecture if dn has one.
+func (bddb BootDataDatabase) RecordDiscoveredNode(dn bssTypes.DiscoveredNode) (err error) {
+	execStr := `INSERT INTO discovered_nodes (mac, arch, ip, first_seen, last_seen) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (mac) DO UPDATE SET
+			arch = COALESCE(NULLIF(EXCLUDED.arch, ''), discovered_nodes.arch),
+			ip = EXCLUDED.ip,
+			last_seen = GREATEST(discovered_nodes.last_seen, EXCLUDED.last_seen);`
+	_, err = bddb.DB.Exec(execStr, dn.Mac, dn.Arch, dn.IP, dn.FirstSeen, dn.LastSeen)
+	if err != nil {
+		err = fmt.Errorf("postgres.RecordDiscoveredNode: Error recording %s: %v", dn.Mac, err)
+	}
+
+	return
+}
+
+// GetDiscoveredNodes returns every node in the discovered_nodes table, ordered
+// by MAC address.
+func (bddb BootDataDatabase) GetDiscoveredNodes() (nodes []bssTypes.DiscoveredNode, err error) {
+	qstr := `SELECT mac, arch, ip, first_seen, last_seen, name, nid, role, subrole FROM discovered_nodes ORDER BY mac;`
+	rows, err := bddb.DB.Query(qstr)
+	if err != nil {
+		err = fmt.Errorf("postgres.GetDiscoveredNodes: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dn bssTypes.DiscoveredNode
+		err = rows.Scan(&dn.Mac, &dn.Arch, &dn.IP, &dn.FirstSeen, &dn.LastSeen, &dn.Name, &dn.Nid, &dn.Role, &dn.SubRole)
+		if err != nil {
+			err = fmt.Errorf("postgres.GetDiscoveredNodes: could not scan SQL result: %v", err)
+			return
+		}
+		nodes = append(nodes, dn)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("postgres.GetDiscoveredNodes: could not parse query results: %v", err)
+	}
+
+	return
+}
+
+// AdoptDiscoveredNode records the name, NID, role, and subrole that the
+// discovered node with MAC address a.Mac was adopted as. found is false if
+// there is no such node.
+func (bddb BootDataDatabase) AdoptDiscoveredNode(a bssTypes.DiscoveryAdoption) (found bool, err error) {
+	execStr := `UPDATE discovered_nodes SET name = $2, nid = $3, role = $4, subrole = $5 WHERE mac = $1;`
+	res, err := bddb.DB.Exec(execStr, a.Mac, a.Name, a.Nid, a.Role, a.SubRole)
+	if err != nil {
+		err = fmt.Errorf("postgres.AdoptDiscoveredNode: Error adopting %s: %v", a.Mac, err)
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("postgres.AdoptDiscoveredNode: %v", err)
+		return
+	}
+	found = n > 0
+
+	return
+}
+
+// DeleteDiscoveredNode removes the node with MAC address mac from the
+// discovered_nodes table, returning the name it was adopted as, if any. found
+// is false if there is no such node.
+func (bddb BootDataDatabase) DeleteDiscoveredNode(mac string) (name string, found bool, err error) {
+	err = bddb.DB.QueryRow(`DELETE FROM discovered_nodes WHERE mac = $1 RETURNING name;`, mac).Scan(&name)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("postgres.DeleteDiscoveredNode: Error deleting %s: %v", mac, err)
+		return
+	}
+	found = true
+
+	return
+}
+
+// GetBootParamsByGroup returns the boot configuration of the boot group
+// named name, with exists false if there is no such group.
+func (bddb BootDataDatabase) GetBootParamsByGroup(name string) (bp bssTypes.BootParams, exists bool, err error) {
+	qstr := `SELECT bc.kernel_uri, bc.initrd_uri, bc.cmdline FROM boot_groups AS bg
+		JOIN boot_configs AS bc ON bg.boot_config_id = bc.id WHERE bg.name = $1;`
+	rows, err := bddb.DB.Query(qstr, name)
+	if err != nil {
+		err = fmt.Errorf("postgres.GetBootParamsByGroup: %v", err)
+		return
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(&bp.Kernel, &bp.Initrd, &bp.Params); err != nil {
+			err = fmt.Errorf("postgres.GetBootParamsByGroup: %v", err)
+			return
+		}
+		exists = true
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("postgres.GetBootParamsByGroup: %v", err)
+	}
+
+	return
+}
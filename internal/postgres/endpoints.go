@@ -25,6 +25,7 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/OpenCHAMI/bss/pkg/bssTypes"
@@ -34,41 +35,66 @@ type EndpointAccess struct {
 	Name      string `json:"name"`
 	Endpoint  string `json:"endpoint"`
 	LastEpoch int64  `json:"last_epoch"`
+	ClientIP  string `json:"client_ip"`
+	Default   bool   `json:"default"`
 }
 
-// SearchEndpointAccesses takes the name of a node (xname) and a BSS endpoint as
-// arguments and returns a slice of EndpointAccess structs representing
-// timestamps of when the passed endpoint was accessed for the passed name. If
-// endpointType is empty, then all endpoint accesses for the given name are
-// returned. If name is empty, then all accesses for the given endpoint are
-// returned. If both arguments are empty, then all endpoint accesses for all
-// names are returned.
-func (bddb BootDataDatabase) SearchEndpointAccesses(name string, endpointType bssTypes.EndpointType) (accesses []bssTypes.EndpointAccess, err error) {
-	qstr := `SELECT * FROM endpoint_access`
-
-	// Only construct query with WHERE clause if both arguments are NOT
-	// empty.
-	if name != "" || endpointType != "" {
-		qstr += ` WHERE`
-		strs := []string{name, string(endpointType)}
-		for first, i := true, 0; i < len(strs); i++ {
-			if strs[i] != "" {
-				if !first {
-					qstr += ` AND`
-				}
-				switch i {
-				case 0:
-					qstr += fmt.Sprintf(` name = '%s'`, strs[0])
-				case 1:
-					qstr += fmt.Sprintf(` endpoint = '%s'`, strs[1])
-				}
-				first = false
-			}
+// EndpointAccessFilter selects the endpoint accesses returned by
+// SearchEndpointAccesses.  Zero values do not filter.
+type EndpointAccessFilter struct {
+	Name     string
+	Endpoint bssTypes.EndpointType
+	Since    int64 // Only accesses at or after this Unix time
+	Until    int64 // Only accesses at or before this Unix time
+	Limit    int   // Only the most recent accesses
+	LastOnly bool  // Only the latest access for each name and endpoint
+}
+
+// SearchEndpointAccesses returns the endpoint accesses selected by f, most
+// recent first. If f.Name is empty, accesses for all names are returned, and
+// if f.Endpoint is empty, accesses to all endpoints are returned.
+func (bddb BootDataDatabase) SearchEndpointAccesses(f EndpointAccessFilter) (accesses []bssTypes.EndpointAccess, err error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	where := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Name != "" {
+		where(`name = $%d`, f.Name)
+	}
+	if f.Endpoint != "" {
+		where(`endpoint = $%d`, string(f.Endpoint))
+	}
+	if f.Since > 0 {
+		where(`last_epoch >= $%d`, f.Since)
+	}
+	if f.Until > 0 {
+		where(`last_epoch <= $%d`, f.Until)
+	}
+
+	qstr := `SELECT name, endpoint, last_epoch, COALESCE(client_ip, ''), is_default FROM endpoint_access`
+	if len(conds) > 0 {
+		qstr += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+	if f.LastOnly {
+		qstr = `SELECT DISTINCT ON (name, endpoint) name, endpoint, last_epoch, COALESCE(client_ip, '') AS client_ip, is_default FROM endpoint_access`
+		if len(conds) > 0 {
+			qstr += ` WHERE ` + strings.Join(conds, ` AND `)
 		}
+		qstr = `SELECT * FROM (` + qstr + ` ORDER BY name, endpoint, last_epoch DESC) AS latest`
+	}
+	qstr += ` ORDER BY last_epoch DESC, name, endpoint`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		qstr += fmt.Sprintf(` LIMIT $%d`, len(args))
 	}
 	qstr += `;`
+
 	var rows *sql.Rows
-	rows, err = bddb.DB.Query(qstr)
+	rows, err = bddb.DB.Query(qstr, args...)
 	if err != nil {
 		err = fmt.Errorf("postgres.SearchEndpointAccesses: Could not query endpoint access table in boot database: %v", err)
 		return
@@ -79,7 +105,7 @@ func (bddb BootDataDatabase) SearchEndpointAccesses(name string, endpointType bs
 		var (
 			ea bssTypes.EndpointAccess
 		)
-		err = rows.Scan(&ea.Name, &ea.Endpoint, &ea.LastEpoch)
+		err = rows.Scan(&ea.Name, &ea.Endpoint, &ea.LastEpoch, &ea.ClientIP, &ea.Default)
 		if err != nil {
 			err = fmt.Errorf("postgres.SearchEndpointAccesses: Could not scan results into EndpointAccess: %v", err)
 			return
@@ -95,40 +121,90 @@ func (bddb BootDataDatabase) SearchEndpointAccesses(name string, endpointType bs
 	return
 }
 
-// LogEndpointAccess takes a name and an endpoint type and adds a table entry to
-// the endpoint_accesses table with the current timestamp.
-func (bddb BootDataDatabase) LogEndpointAccess(name string, endpointType bssTypes.EndpointType) (err error) {
-	if name == "" {
-		err = fmt.Errorf("postgres.LogEndpointAccess: Argument 'name' cannot be empty")
+// LogEndpointAccess adds ea to the endpoint_access table. If ea.LastEpoch is
+// 0, the current time is used.
+func (bddb BootDataDatabase) LogEndpointAccess(ea bssTypes.EndpointAccess) (err error) {
+	if err = checkEndpointAccess(&ea); err != nil {
+		err = fmt.Errorf("postgres.LogEndpointAccess: %v", err)
 		return
 	}
-	if endpointType == "" {
-		err = fmt.Errorf("postgres.LogEndpointAccess: Argument 'endpointType' cannot be empty")
+
+	err = bddb.addEndpointAccess(bddb.DB, EndpointAccess{
+		Name:      ea.Name,
+		Endpoint:  string(ea.Endpoint),
+		LastEpoch: ea.LastEpoch,
+		ClientIP:  ea.ClientIP,
+		Default:   ea.Default,
+	})
+	if err != nil {
+		err = fmt.Errorf("postgres.LogEndpointAccess: %v", err)
+	}
+
+	return
+}
+
+func checkEndpointAccess(ea *bssTypes.EndpointAccess) error {
+	if ea.Name == "" {
+		return fmt.Errorf("Argument 'name' cannot be empty")
+	}
+	if ea.Endpoint == "" {
+		return fmt.Errorf("Argument 'endpointType' cannot be empty")
+	}
+	if ea.LastEpoch == 0 {
+		ea.LastEpoch = time.Now().Unix()
+	}
+	return nil
+}
+
+// execer is satisfied by both *sqlx.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (bddb BootDataDatabase) addEndpointAccess(db execer, ea EndpointAccess) (err error) {
+	execStr := `INSERT INTO endpoint_access (name, endpoint, last_epoch, client_ip, is_default) VALUES ($1, $2, $3, $4, $5);`
+	_, err = db.Exec(execStr, ea.Name, ea.Endpoint, ea.LastEpoch, ea.ClientIP, ea.Default)
+	if err != nil {
+		err = fmt.Errorf("Error executing query to add endpoint access %v: %v", ea, err)
 		return
 	}
 
-	ts := time.Now()
-	ea := EndpointAccess{
-		Name:      name,
-		Endpoint:  string(endpointType),
-		LastEpoch: ts.Unix(),
+	return
+}
+
+// SetEndpointAccess is like LogEndpointAccess, but replaces the latest access
+// of the endpoint by the name that it set earlier, so only one is kept.
+// Accesses logged by LogEndpointAccess are not replaced.
+func (bddb BootDataDatabase) SetEndpointAccess(ea bssTypes.EndpointAccess) (err error) {
+	if err = checkEndpointAccess(&ea); err != nil {
+		err = fmt.Errorf("postgres.SetEndpointAccess: %v", err)
+		return
 	}
 
-	err = bddb.addEndpointAccess(ea)
+	execStr := `INSERT INTO endpoint_access (name, endpoint, last_epoch, client_ip, is_default, is_latest) VALUES ($1, $2, $3, $4, $5, true)
+		ON CONFLICT (name, endpoint) WHERE is_latest
+		DO UPDATE SET last_epoch = EXCLUDED.last_epoch, client_ip = EXCLUDED.client_ip, is_default = EXCLUDED.is_default;`
+	_, err = bddb.DB.Exec(execStr, ea.Name, string(ea.Endpoint), ea.LastEpoch, ea.ClientIP, ea.Default)
 	if err != nil {
-		err = fmt.Errorf("postgres.LogEndpointAccess: %v", err)
+		err = fmt.Errorf("postgres.SetEndpointAccess: Error executing query to set endpoint access %v: %v", ea, err)
 	}
 
 	return
 }
 
-func (bddb BootDataDatabase) addEndpointAccess(ea EndpointAccess) (err error) {
-	execStr := `INSERT INTO endpoint_access (name, endpoint, last_epoch) VALUES ($1, $2, $3);`
-	_, err = bddb.DB.Exec(execStr, ea.Name, ea.Endpoint, ea.LastEpoch)
+// PruneEndpointAccesses removes the endpoint accesses made before the Unix
+// time before and returns how many were removed.
+func (bddb BootDataDatabase) PruneEndpointAccesses(before int64) (removed int64, err error) {
+	execStr := `DELETE FROM endpoint_access WHERE last_epoch < $1;`
+	res, err := bddb.DB.Exec(execStr, before)
 	if err != nil {
-		err = fmt.Errorf("Error executing query to add endpoint access %v: %v", ea, err)
+		err = fmt.Errorf("postgres.PruneEndpointAccesses: %v", err)
 		return
 	}
+	removed, err = res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("postgres.PruneEndpointAccesses: %v", err)
+	}
 
 	return
 }
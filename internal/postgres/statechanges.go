@@ -0,0 +1,142 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StateChangeChannel is the LISTEN/NOTIFY channel on which SetStateChanged
+// announces a new state change timestamp.
+const StateChangeChannel = "bss_state_changed"
+
+// SetStateChanged records ts as the time of the most recent HSM state change
+// and notifies every listener on StateChangeChannel. The stored value never
+// moves backwards, so an older notification arriving late is harmless.
+func (bddb BootDataDatabase) SetStateChanged(ts int64) (err error) {
+	tx, err := bddb.DB.Beginx()
+	if err != nil {
+		err = fmt.Errorf("postgres.SetStateChanged: Unable to start transaction: %v", err)
+		return
+	}
+	execStr := `INSERT INTO state_changes (id, last_epoch) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET last_epoch = GREATEST(state_changes.last_epoch, EXCLUDED.last_epoch);`
+	if _, err = tx.Exec(execStr, ts); err != nil {
+		tx.Rollback()
+		err = fmt.Errorf("postgres.SetStateChanged: Error recording state change %d: %v", ts, err)
+		return
+	}
+	// Notifications are only delivered once the transaction commits.
+	if _, err = tx.Exec(`SELECT pg_notify($1, $2);`, StateChangeChannel, strconv.FormatInt(ts, 10)); err != nil {
+		tx.Rollback()
+		err = fmt.Errorf("postgres.SetStateChanged: Error sending notification: %v", err)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("postgres.SetStateChanged: Unable to commit transaction: %v", err)
+	}
+
+	return
+}
+
+// GetStateChanged returns the time of the most recent HSM state change
+// recorded by SetStateChanged. exists is false if no change has been recorded.
+func (bddb BootDataDatabase) GetStateChanged() (ts int64, exists bool, err error) {
+	err = bddb.DB.Get(&ts, `SELECT last_epoch FROM state_changes WHERE id = 1;`)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("postgres.GetStateChanged: %v", err)
+		return
+	}
+	exists = true
+
+	return
+}
+
+// ChangeListener receives the values published on a LISTEN/NOTIFY channel
+// by any BSS instance sharing the database.
+type ChangeListener struct {
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+// ListenStateChanges opens a dedicated connection that listens on
+// StateChangeChannel and calls onChange with each timestamp received. The
+// connection is re-established automatically if lost; since notifications
+// sent while disconnected are not queued, the stored timestamp is re-read and
+// passed to onChange after every reconnect.
+func (bddb BootDataDatabase) ListenStateChanges(onChange func(ts int64)) (*ChangeListener, error) {
+	return bddb.listenChanges("ListenStateChanges", StateChangeChannel, bddb.GetStateChanged, onChange)
+}
+
+// listenChanges listens on channel for the numbers sent by notifications,
+// re-reading the stored number with get after every reconnect.
+func (bddb BootDataDatabase) listenChanges(fn, channel string, get func() (int64, bool, error), onChange func(int64)) (*ChangeListener, error) {
+	l := pq.NewListener(bddb.connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("postgres.%s: %v", fn, err)
+		}
+	})
+	if err := l.Listen(channel); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("postgres.%s: %v", fn, err)
+	}
+	cl := &ChangeListener{listener: l, done: make(chan struct{})}
+	go func() {
+		defer close(cl.done)
+		for n := range l.Notify {
+			if n == nil {
+				// The connection was re-established.
+				if v, exists, err := get(); err != nil {
+					log.Printf("postgres.%s: %v", fn, err)
+				} else if exists {
+					onChange(v)
+				}
+				continue
+			}
+			v, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				log.Printf("postgres.%s: Ignoring malformed notification %q: %v", fn, n.Extra, err)
+				continue
+			}
+			onChange(v)
+		}
+	}()
+
+	return cl, nil
+}
+
+// Close stops listening and waits for any onChange call in progress to return.
+func (cl *ChangeListener) Close() error {
+	err := cl.listener.Close()
+	<-cl.done
+	return err
+}
@@ -0,0 +1,174 @@
+// MIT License
+//
+// Copyright © 2026 Contributors to the OpenCHAMI Project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/OpenCHAMI/bss/pkg/bssTypes"
+)
+
+func joinEvents(events []bssTypes.ConfigEventType) string {
+	s := make([]string, len(events))
+	for i, e := range events {
+		s[i] = string(e)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitEvents(s string) (events []bssTypes.ConfigEventType) {
+	for _, e := range strings.Split(s, ",") {
+		if e != "" {
+			events = append(events, bssTypes.ConfigEventType(e))
+		}
+	}
+	return
+}
+
+// AddWebhookSubscription adds s to the webhook_subscriptions table.
+func (bddb BootDataDatabase) AddWebhookSubscription(s bssTypes.WebhookSubscription) (err error) {
+	execStr := `INSERT INTO webhook_subscriptions (id, url, secret, events, created) VALUES ($1, $2, $3, $4, $5);`
+	_, err = bddb.DB.Exec(execStr, s.ID, s.URL, s.Secret, joinEvents(s.Events), s.Created)
+	if err != nil {
+		err = fmt.Errorf("postgres.AddWebhookSubscription: Error adding %s: %v", s.ID, err)
+	}
+
+	return
+}
+
+// GetWebhookSubscriptions returns every webhook subscription, secrets
+// included, ordered by creation time.
+func (bddb BootDataDatabase) GetWebhookSubscriptions() (subs []bssTypes.WebhookSubscription, err error) {
+	qstr := `SELECT id, url, secret, events, created FROM webhook_subscriptions ORDER BY created, id;`
+	rows, err := bddb.DB.Query(qstr)
+	if err != nil {
+		err = fmt.Errorf("postgres.GetWebhookSubscriptions: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s bssTypes.WebhookSubscription
+		var events string
+		err = rows.Scan(&s.ID, &s.URL, &s.Secret, &events, &s.Created)
+		if err != nil {
+			err = fmt.Errorf("postgres.GetWebhookSubscriptions: could not scan SQL result: %v", err)
+			return
+		}
+		s.Events = splitEvents(events)
+		subs = append(subs, s)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("postgres.GetWebhookSubscriptions: could not parse query results: %v", err)
+	}
+
+	return
+}
+
+// GetWebhookSubscription returns the webhook subscription with the given ID,
+// with exists false if there is none.
+func (bddb BootDataDatabase) GetWebhookSubscription(id string) (s bssTypes.WebhookSubscription, exists bool, err error) {
+	var events string
+	qstr := `SELECT id, url, secret, events, created FROM webhook_subscriptions WHERE id = $1;`
+	err = bddb.DB.QueryRow(qstr, id).Scan(&s.ID, &s.URL, &s.Secret, &events, &s.Created)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("postgres.GetWebhookSubscription: %v", err)
+		return
+	}
+	s.Events = splitEvents(events)
+	exists = true
+
+	return
+}
+
+// DeleteWebhookSubscription removes the webhook subscription with the given
+// ID. found is false if there is no such subscription.
+func (bddb BootDataDatabase) DeleteWebhookSubscription(id string) (found bool, err error) {
+	return bddb.deleteByID("webhook_subscriptions", id)
+}
+
+// AddWebhookDeadLetter adds d to the webhook_dead_letters table.
+func (bddb BootDataDatabase) AddWebhookDeadLetter(d bssTypes.WebhookDeadLetter) (err error) {
+	execStr := `INSERT INTO webhook_dead_letters (id, subscription, url, event, attempts, error, time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING;`
+	_, err = bddb.DB.Exec(execStr, d.ID, d.Subscription, d.URL, string(d.Event), d.Attempts, d.Error, d.Time)
+	if err != nil {
+		err = fmt.Errorf("postgres.AddWebhookDeadLetter: Error adding %s: %v", d.ID, err)
+	}
+
+	return
+}
+
+// GetWebhookDeadLetters returns every undelivered webhook event, oldest
+// first.
+func (bddb BootDataDatabase) GetWebhookDeadLetters() (letters []bssTypes.WebhookDeadLetter, err error) {
+	qstr := `SELECT id, subscription, url, event, attempts, error, time FROM webhook_dead_letters ORDER BY time, id;`
+	rows, err := bddb.DB.Query(qstr)
+	if err != nil {
+		err = fmt.Errorf("postgres.GetWebhookDeadLetters: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d bssTypes.WebhookDeadLetter
+		var event string
+		err = rows.Scan(&d.ID, &d.Subscription, &d.URL, &event, &d.Attempts, &d.Error, &d.Time)
+		if err != nil {
+			err = fmt.Errorf("postgres.GetWebhookDeadLetters: could not scan SQL result: %v", err)
+			return
+		}
+		d.Event = []byte(event)
+		letters = append(letters, d)
+	}
+	if err = rows.Err(); err != nil {
+		err = fmt.Errorf("postgres.GetWebhookDeadLetters: could not parse query results: %v", err)
+	}
+
+	return
+}
+
+// DeleteWebhookDeadLetter removes the undelivered webhook event with the
+// given ID. found is false if there is no such event.
+func (bddb BootDataDatabase) DeleteWebhookDeadLetter(id string) (found bool, err error) {
+	return bddb.deleteByID("webhook_dead_letters", id)
+}
+
+func (bddb BootDataDatabase) deleteByID(table, id string) (found bool, err error) {
+	res, err := bddb.DB.Exec(`DELETE FROM `+table+` WHERE id = $1;`, id)
+	if err != nil {
+		err = fmt.Errorf("postgres: Error deleting %s from %s: %v", id, table, err)
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("postgres: %v", err)
+		return
+	}
+	found = n > 0
+
+	return
+}
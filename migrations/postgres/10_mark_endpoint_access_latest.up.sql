@@ -0,0 +1,33 @@
+-- MIT License
+--
+-- Copyright © 2026 Contributors to the OpenCHAMI Project
+--
+-- Permission is hereby granted, free of charge, to any person obtaining a copy
+-- of this software and associated documentation files (the "Software"), to deal
+-- in the Software without restriction, including without limitation the rights
+-- to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+-- copies of the Software, and to permit persons to whom the Software is
+-- furnished to do so, subject to the following conditions:
+--
+-- The above copyright notice and this permission notice shall be included in all
+-- copies or substantial portions of the Software.
+--
+-- THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+-- IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+-- FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+-- AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+-- LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+-- OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+-- SOFTWARE.
+
+BEGIN;
+
+--
+-- endpoint_access - Mark the row holding the latest access of an endpoint by
+-- a node when only the latest access is kept, so that it can be replaced in
+-- place.  Rows recorded before, or with the full history, are left alone.
+--
+ALTER TABLE endpoint_access ADD COLUMN IF NOT EXISTS is_latest boolean NOT NULL DEFAULT false;
+CREATE UNIQUE INDEX IF NOT EXISTS endpoint_access_latest_idx ON endpoint_access (name, endpoint) WHERE is_latest;
+
+COMMIT;
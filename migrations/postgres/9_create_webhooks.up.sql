@@ -0,0 +1,49 @@
+-- MIT License
+--
+-- Copyright © 2026 Contributors to the OpenCHAMI Project
+--
+-- Permission is hereby granted, free of charge, to any person obtaining a copy
+-- of this software and associated documentation files (the "Software"), to deal
+-- in the Software without restriction, including without limitation the rights
+-- to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+-- copies of the Software, and to permit persons to whom the Software is
+-- furnished to do so, subject to the following conditions:
+--
+-- The above copyright notice and this permission notice shall be included in all
+-- copies or substantial portions of the Software.
+--
+-- THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+-- IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+-- FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+-- AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+-- LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+-- OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+-- SOFTWARE.
+
+BEGIN;
+
+--
+-- webhook_subscriptions - Targets of boot parameter change events
+--
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id varchar PRIMARY KEY,
+	url varchar NOT NULL,
+	secret varchar NOT NULL,
+	events varchar NOT NULL DEFAULT '',
+	created bigint NOT NULL
+);
+
+--
+-- webhook_dead_letters - Change events that could not be delivered
+--
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+	id varchar PRIMARY KEY,
+	subscription varchar NOT NULL,
+	url varchar NOT NULL,
+	event text NOT NULL,
+	attempts int NOT NULL,
+	error varchar NOT NULL DEFAULT '',
+	time bigint NOT NULL
+);
+
+COMMIT;
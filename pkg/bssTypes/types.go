@@ -23,8 +23,11 @@
 package bssTypes
 
 import (
+	"encoding/json"
 	"fmt"
+	"mime"
 	"regexp"
+	"strings"
 
 	"github.com/Cray-HPE/hms-xname/xnames"
 )
@@ -39,13 +42,25 @@ type PhoneHome struct {
 	FQDN             string `form:"fqdn" json:"fqdn" binding:"omitempty"`
 }
 
-// The main cloud-init struct. Leave the meta-data, user-data, and phone home
-// info as generic interfaces as the user defines how much info exists in it
+// The main cloud-init struct. Leave the meta-data, user-data, vendor-data,
+// network-config overrides, and phone home info as generic interfaces as the user defines how much info exists in it
 type CloudDataType map[string]interface{}
 type CloudInit struct {
-	MetaData  CloudDataType `json:"meta-data"`
-	UserData  CloudDataType `json:"user-data"`
-	PhoneHome PhoneHome     `json:"phone-home,omitempty"`
+	MetaData      CloudDataType  `json:"meta-data"`
+	UserData      CloudDataType  `json:"user-data"`
+	VendorData    CloudDataType  `json:"vendor-data,omitempty"`
+	NetworkConfig CloudDataType  `json:"network-config,omitempty"`
+	UserDataParts []UserDataPart `json:"user-data-parts,omitempty"`
+	PhoneHome     PhoneHome      `json:"phone-home,omitempty"`
+}
+
+// UserDataPart is an additional part of the user-data, such as a shell
+// script or an #include list, sent along with the cloud-config as a MIME
+// multi-part message.
+type UserDataPart struct {
+	ContentType string `json:"content-type"`
+	Filename    string `json:"filename,omitempty"`
+	Content     string `json:"content"`
 }
 
 // This is the main data structure used to communicate with the client.  It
@@ -83,6 +98,57 @@ func (bp BootParams) CheckMacs() (err error) {
 	return
 }
 
+// NetworkConfigKeys are the keys allowed in network-config overrides: the
+// device types of cloud-init network config version 2, and the settings of
+// HSM networks.
+var NetworkConfigKeys = []string{"ethernets", "bonds", "bridges", "vlans", "networks"}
+
+// Validate the network-config overrides in the boot parameters.  Each key
+// must be one of NetworkConfigKeys and map names to settings.
+func (bp BootParams) CheckNetworkConfig() (err error) {
+	for k, v := range bp.CloudInit.NetworkConfig {
+		known := false
+		for _, nk := range NetworkConfigKeys {
+			known = known || k == nk
+		}
+		if !known {
+			return fmt.Errorf("unsupported network-config key %q, expected one of %s", k, strings.Join(NetworkConfigKeys, ", "))
+		}
+		if v == nil {
+			// Removed by a PATCH
+			continue
+		}
+		entries, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("network-config %s must be an object", k)
+		}
+		for name, e := range entries {
+			if _, ok := e.(map[string]interface{}); !ok && e != nil {
+				return fmt.Errorf("network-config %s.%s must be an object", k, name)
+			}
+		}
+	}
+	return
+}
+
+// Validate the additional user-data parts in the boot parameters.  Each
+// needs a content type other than multipart, which BSS builds itself.
+func (bp BootParams) CheckUserDataParts() (err error) {
+	for i, part := range bp.CloudInit.UserDataParts {
+		mediaType, _, err := mime.ParseMediaType(part.ContentType)
+		if err != nil {
+			return fmt.Errorf("user-data part %d: invalid content type %q: %v", i+1, part.ContentType, err)
+		}
+		if strings.HasPrefix(mediaType, "multipart/") {
+			return fmt.Errorf("user-data part %d: content type %s is not allowed", i+1, mediaType)
+		}
+		if strings.ContainsAny(part.Filename, "\"\r\n") {
+			return fmt.Errorf("user-data part %d: invalid filename %q", i+1, part.Filename)
+		}
+	}
+	return
+}
+
 // Validate the xnames in the boot parameters.  They must be of type "Node"
 func (bp BootParams) CheckXnames() (err error) {
 	for _, xname := range bp.Hosts {
@@ -104,15 +170,187 @@ type EndpointType string
 const (
 	EndpointTypeBootscript EndpointType = "bootscript"
 	EndpointTypeUserData   EndpointType = "user-data"
+	EndpointTypeRescue     EndpointType = "rescue"
+	EndpointTypeMetaData   EndpointType = "meta-data"
+	EndpointTypePhoneHome  EndpointType = "phone-home"
+
+	EndpointTypeVendorData    EndpointType = "vendor-data"
+	EndpointTypeNetworkConfig EndpointType = "network-config"
 )
 
 var EndpointTypes = []EndpointType{
 	EndpointTypeBootscript,
 	EndpointTypeUserData,
+	EndpointTypeRescue,
+	EndpointTypeMetaData,
+	EndpointTypePhoneHome,
+	EndpointTypeVendorData,
+	EndpointTypeNetworkConfig,
 }
 
+// EndpointAccess records a request to one of the endpoints above. Name is
+// the xname of the node the request resolved to, or the client IP address if
+// it did not resolve to a node.  Default is true if the client got default
+// data rather than data of its own, which is always the case for a client
+// that did not resolve to a node.
 type EndpointAccess struct {
 	Name      string       `json:"name"`
 	Endpoint  EndpointType `json:"endpoint"`
 	LastEpoch int64        `json:"last_epoch"`
+	ClientIP  string       `json:"client_ip,omitempty"`
+	Default   bool         `json:"default"`
+}
+
+// The following structures relate to the registry of nodes discovered through
+// boot script requests.
+
+// DiscoveredNode is a node that requested a boot script with a MAC address
+// that BSS did not recognize. Name, Nid, Role, and SubRole are set once an
+// operator adopts the node.
+type DiscoveredNode struct {
+	Mac       string `json:"mac"`
+	Arch      string `json:"arch,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	FirstSeen int64  `json:"first_seen"`
+	LastSeen  int64  `json:"last_seen"`
+	Name      string `json:"name,omitempty"`
+	Nid       int32  `json:"nid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	SubRole   string `json:"subrole,omitempty"`
+}
+
+// DiscoveryAdoption turns a discovered node into a named node. If Group is
+// set, the node is also given a copy of that group's boot parameters.
+type DiscoveryAdoption struct {
+	Mac     string `json:"mac"`
+	Name    string `json:"name"`
+	Nid     int32  `json:"nid,omitempty"`
+	Role    string `json:"role,omitempty"`
+	SubRole string `json:"subrole,omitempty"`
+	Group   string `json:"group,omitempty"`
+}
+
+// The following structures relate to tracking the progress of node boots.
+
+type BootSessionState string
+
+const (
+	BootSessionBooting  BootSessionState = "booting"   // Boot script fetched, waiting for user-data
+	BootSessionUserData BootSessionState = "user-data" // User-data fetched, waiting for phone-home
+	BootSessionComplete BootSessionState = "complete"  // Phone-home received
+	BootSessionStuck    BootSessionState = "stuck"     // No user-data or phone-home within the deadline
+	BootSessionLooping  BootSessionState = "looping"   // Retrying or restarting without progress
+)
+
+var BootSessionStates = []BootSessionState{
+	BootSessionBooting,
+	BootSessionUserData,
+	BootSessionComplete,
+	BootSessionStuck,
+	BootSessionLooping,
+}
+
+// BootSession links the boot script, user-data, and phone-home requests of
+// one boot attempt of a node. A boot script request without a retry counter
+// starts a new session. Timestamps are Unix epoch times, 0 if the event has
+// not happened in this session.
+type BootSession struct {
+	Name       string           `json:"name"`
+	Started    int64            `json:"started"`
+	Bootscript int64            `json:"bootscript"`
+	Retries    int32            `json:"retries"`
+	Attempts   int32            `json:"attempts"`
+	UserData   int64            `json:"user_data,omitempty"`
+	PhoneHome  int64            `json:"phone_home,omitempty"`
+	State      BootSessionState `json:"state,omitempty"`
+}
+
+// BootscriptEvent is POSTed to the boot script notification targets for each
+// boot script request. ConfigRevision identifies the boot configuration the
+// node was given, and Outcome is one of the outcomes of the
+// bss_bootscript_requests_total metric, e.g. served or unknown.
+type BootscriptEvent struct {
+	ID             string `json:"id"`
+	Time           int64  `json:"time"`
+	Xname          string `json:"xname,omitempty"`
+	Mac            string `json:"mac,omitempty"`
+	Nid            int32  `json:"nid,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	Arch           string `json:"arch,omitempty"`
+	Retry          int    `json:"retry,omitempty"`
+	ConfigRevision string `json:"config_revision,omitempty"`
+	Outcome        string `json:"outcome"`
+}
+
+// PhoneHomeEvent is sent on the event stream when a node phones home.
+type PhoneHomeEvent struct {
+	Time       int64  `json:"time"`
+	Xname      string `json:"xname"`
+	IP         string `json:"ip,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
+	FQDN       string `json:"fqdn,omitempty"`
+	InstanceID string `json:"instance_id,omitempty"`
+}
+
+// StateRefreshEvent is sent on the event stream when the node inventory is
+// refreshed. Full is false when a state change notification was applied to
+// the cached inventory, in which case Changed lists the nodes it changed.
+type StateRefreshEvent struct {
+	Time       int64    `json:"time"`
+	Full       bool     `json:"full"`
+	Components int      `json:"components"`
+	Changed    []string `json:"changed,omitempty"`
+}
+
+// The following structures relate to webhook subscriptions to changes of
+// boot parameters.
+
+type ConfigEventType string
+
+const (
+	ConfigCreated ConfigEventType = "created"
+	ConfigUpdated ConfigEventType = "updated"
+	ConfigDeleted ConfigEventType = "deleted"
+)
+
+var ConfigEventTypes = []ConfigEventType{
+	ConfigCreated,
+	ConfigUpdated,
+	ConfigDeleted,
+}
+
+// ConfigEvent is POSTed to webhook subscriptions when the boot parameters of
+// a node or a group are created, updated, or deleted. Kind is node or group.
+// Before is nil for a new configuration and After for a deleted one.
+type ConfigEvent struct {
+	ID     string          `json:"id"`
+	Time   int64           `json:"time"`
+	Type   ConfigEventType `json:"type"`
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	Before *BootParams     `json:"before"`
+	After  *BootParams     `json:"after"`
+}
+
+// WebhookSubscription is a target for ConfigEvents. Events limits it to the
+// given event types, all if empty. Secret is the key events are signed with,
+// and is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID      string            `json:"id"`
+	URL     string            `json:"url"`
+	Secret  string            `json:"secret,omitempty"`
+	Events  []ConfigEventType `json:"events,omitempty"`
+	Created int64             `json:"created"`
+}
+
+// WebhookDeadLetter is an event that could not be delivered to a
+// subscription after the maximum number of attempts.
+type WebhookDeadLetter struct {
+	ID           string          `json:"id"`
+	Subscription string          `json:"subscription"`
+	URL          string          `json:"url"`
+	Event        json.RawMessage `json:"event"`
+	Attempts     uint            `json:"attempts"`
+	Error        string          `json:"error"`
+	Time         int64           `json:"time"`
 }